@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"coreos"
+)
+
+// convertCommand translates a cloud-config file, or stdin, to Ignition on
+// stdout. Warnings about untranslated parts go to stderr.
+func convertCommand(args []string) int {
+	var (
+		buf []byte
+		err error
+	)
+	switch len(args) {
+	case 0:
+		buf, err = ioutil.ReadAll(os.Stdin)
+	case 1:
+		buf, err = ioutil.ReadFile(args[0])
+	default:
+		return usage("convert [cloud-config]")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ign, warnings, err := coreos.ConvertCloudConfig(string(buf))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+	fmt.Println(ign)
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"coreos"
+)
+
+// discoveryCommand serves the etcd discovery protocol, for clusters that
+// can't reach discovery.etcd.io.
+func discoveryCommand(args []string) int {
+	fs := flag.NewFlagSet("discovery-server", flag.ContinueOnError)
+	listen := fs.String("listen", ":8087", "address to listen on")
+	data := fs.String("data", "discovery.json", "file to keep tokens and registrations in")
+	url := fs.String("url", "", "base URL of token URLs, defaults to http://<request host>")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return usage("discovery-server [-listen addr] [-data file] [-url base]")
+	}
+
+	s, err := coreos.NewDiscoveryServer(*data, *url)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	log.Printf("serving etcd discovery on %s", *listen)
+	if err := http.ListenAndServe(*listen, s); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
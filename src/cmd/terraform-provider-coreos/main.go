@@ -1,13 +1,35 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"coreos"
 
 	"github.com/hashicorp/terraform/plugin"
 )
 
+// commands are run instead of serving the plugin when the binary is
+// invoked with their name as the first argument.
+var commands = map[string]func(args []string) int{
+	"convert":          convertCommand,
+	"discovery-server": discoveryCommand,
+	"update-server":    updateCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: coreos.Provider,
 	})
 }
+
+func usage(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "usage: terraform-provider-coreos "+format+"\n", args...)
+	return 2
+}
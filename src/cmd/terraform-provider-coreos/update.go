@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"coreos"
+)
+
+// updateCommand serves Omaha update checks from a directory of payloads,
+// for fleets that can't reach CoreUpdate.
+func updateCommand(args []string) int {
+	fs := flag.NewFlagSet("update-server", flag.ContinueOnError)
+	listen := fs.String("listen", ":8088", "address to listen on")
+	payloads := fs.String("payloads", "payloads", "directory of <version>/update.gz payloads")
+	data := fs.String("data", "update-groups.json", "file to keep group settings in")
+	url := fs.String("url", "", "base URL of payload URLs, defaults to http://<request host>")
+	token := fs.String("admin-token", os.Getenv("UPDATE_SERVER_ADMIN_TOKEN"), "bearer token required by the admin API")
+	insecure := fs.Bool("insecure-admin", false, "serve the admin API without a token on a non-loopback address")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return usage("update-server [-listen addr] [-payloads dir] [-data file] [-url base] [-admin-token token] [-insecure-admin]")
+	}
+	// without a token anyone who can reach the server can repin groups
+	if *token == "" && !*insecure && !isLoopback(*listen) {
+		fmt.Fprintf(os.Stderr, "refusing to serve the admin API on %s without -admin-token, pass -insecure-admin to do it anyway\n", *listen)
+		return 1
+	}
+
+	s, err := coreos.NewUpdateServer(*payloads, *data, *url, *token)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	log.Printf("serving updates from %s on %s", *payloads, *listen)
+	if err := http.ListenAndServe(*listen, s); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// isLoopback reports whether addr only listens on a loopback interface.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,394 @@
+package coreos
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// butaneVersions maps the supported fcos Butane versions to the Ignition
+// spec they produce.
+var butaneVersions = map[string]string{
+	"1.0.0": "3.0.0",
+	"1.1.0": "3.1.0",
+	"1.2.0": "3.2.0",
+	"1.3.0": "3.2.0",
+	"1.4.0": "3.3.0",
+}
+
+type (
+	butaneConfig struct {
+		Variant  string         `yaml:"variant"`
+		Version  string         `yaml:"version"`
+		Ignition butaneIgnition `yaml:"ignition"`
+		Storage  butaneStorage  `yaml:"storage"`
+		Systemd  butaneSystemd  `yaml:"systemd"`
+		Passwd   butanePasswd   `yaml:"passwd"`
+	}
+
+	butaneIgnition struct {
+		Config butaneConfigRefs `yaml:"config"`
+	}
+
+	butaneConfigRefs struct {
+		Merge   []butaneResource `yaml:"merge"`
+		Replace *butaneResource  `yaml:"replace"`
+	}
+
+	butaneResource struct {
+		Source       string             `yaml:"source"`
+		Inline       *string            `yaml:"inline"`
+		Local        string             `yaml:"local"`
+		Verification butaneVerification `yaml:"verification"`
+	}
+
+	butaneVerification struct {
+		Hash string `yaml:"hash"`
+	}
+
+	butaneStorage struct {
+		Files       []butaneFile      `yaml:"files"`
+		Directories []butaneDirectory `yaml:"directories"`
+		Links       []butaneLink      `yaml:"links"`
+	}
+
+	butaneFile struct {
+		Path      string           `yaml:"path"`
+		Overwrite *bool            `yaml:"overwrite"`
+		Contents  *butaneResource  `yaml:"contents"`
+		Append    []butaneResource `yaml:"append"`
+		Mode      *int             `yaml:"mode"`
+		User      *butaneNode      `yaml:"user"`
+		Group     *butaneNode      `yaml:"group"`
+	}
+
+	butaneDirectory struct {
+		Path      string      `yaml:"path"`
+		Overwrite *bool       `yaml:"overwrite"`
+		Mode      *int        `yaml:"mode"`
+		User      *butaneNode `yaml:"user"`
+		Group     *butaneNode `yaml:"group"`
+	}
+
+	butaneLink struct {
+		Path      string      `yaml:"path"`
+		Overwrite *bool       `yaml:"overwrite"`
+		Target    string      `yaml:"target"`
+		Hard      bool        `yaml:"hard"`
+		User      *butaneNode `yaml:"user"`
+		Group     *butaneNode `yaml:"group"`
+	}
+
+	butaneNode struct {
+		ID   *int   `yaml:"id"`
+		Name string `yaml:"name"`
+	}
+
+	butaneSystemd struct {
+		Units []butaneUnit `yaml:"units"`
+	}
+
+	butaneUnit struct {
+		Name     string         `yaml:"name"`
+		Enabled  *bool          `yaml:"enabled"`
+		Mask     bool           `yaml:"mask"`
+		Contents string         `yaml:"contents"`
+		Dropins  []butaneDropin `yaml:"dropins"`
+	}
+
+	butaneDropin struct {
+		Name     string `yaml:"name"`
+		Contents string `yaml:"contents"`
+	}
+
+	butanePasswd struct {
+		Users  []butaneUser  `yaml:"users"`
+		Groups []butaneGroup `yaml:"groups"`
+	}
+
+	butaneUser struct {
+		Name              string   `yaml:"name"`
+		PasswordHash      string   `yaml:"password_hash"`
+		SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys"`
+		UID               *int     `yaml:"uid"`
+		Gecos             string   `yaml:"gecos"`
+		HomeDir           string   `yaml:"home_dir"`
+		NoCreateHome      bool     `yaml:"no_create_home"`
+		PrimaryGroup      string   `yaml:"primary_group"`
+		Groups            []string `yaml:"groups"`
+		NoUserGroup       bool     `yaml:"no_user_group"`
+		System            bool     `yaml:"system"`
+		NoLogInit         bool     `yaml:"no_log_init"`
+		Shell             string   `yaml:"shell"`
+	}
+
+	butaneGroup struct {
+		Name         string `yaml:"name"`
+		Gid          *int   `yaml:"gid"`
+		PasswordHash string `yaml:"password_hash"`
+		System       bool   `yaml:"system"`
+	}
+)
+
+var butaneUnknownFieldRe = regexp.MustCompile(`^line (\d+): field (\S+) not found in type`)
+
+// TranspileButaneConfig converts an fcos Butane config into an Ignition
+// spec 3 config. Local file references are resolved relative to filesDir.
+// Keys Butane does not know are returned as warnings, or fail the
+// conversion when strict is set.
+func TranspileButaneConfig(src, filesDir string, strict bool) (string, []string, error) {
+	ign, warnings, err := transpileButaneConfig(src, filesDir, strict)
+	if err != nil {
+		return "", nil, err
+	}
+	return ign.String(), warnings, nil
+}
+
+func transpileButaneConfig(src, filesDir string, strict bool) (*ign3Config, []string, error) {
+	idx := newYAMLIndex(src)
+
+	var (
+		cfg      butaneConfig
+		warnings []string
+	)
+	if err := yaml.UnmarshalStrict([]byte(src), &cfg); err != nil {
+		te, ok := err.(*yaml.TypeError)
+		if !ok {
+			return nil, nil, idx.wrapError(err)
+		}
+		for _, msg := range te.Errors {
+			m := butaneUnknownFieldRe.FindStringSubmatch(msg)
+			if m == nil {
+				return nil, nil, idx.wrapError(err)
+			}
+			line, _ := strconv.Atoi(m[1])
+			warnings = append(warnings, idx.errorAt(line, "unused key "+m[2]).Error())
+		}
+		if strict {
+			return nil, nil, fmt.Errorf("%s", strings.Join(warnings, "\n"))
+		}
+		cfg = butaneConfig{}
+		if err := yaml.Unmarshal([]byte(src), &cfg); err != nil {
+			return nil, nil, idx.wrapError(err)
+		}
+	}
+
+	if cfg.Variant != "fcos" {
+		return nil, nil, idx.errorf("variant", "unsupported variant %q, must be fcos", cfg.Variant)
+	}
+	spec, ok := butaneVersions[cfg.Version]
+	if !ok {
+		return nil, nil, idx.errorf("version", "unsupported fcos version %q", cfg.Version)
+	}
+
+	t := &butaneTranspiler{idx: idx, filesDir: filesDir, spec: spec}
+	ign, err := t.transpile(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ign, warnings, nil
+}
+
+type butaneTranspiler struct {
+	idx      *yamlIndex
+	filesDir string
+	spec     string
+}
+
+func (t *butaneTranspiler) transpile(cfg *butaneConfig) (*ign3Config, error) {
+	ign := &ign3Config{Ignition: ign3Header{Version: t.spec}}
+
+	refs := cfg.Ignition.Config
+	if len(refs.Merge) > 0 || refs.Replace != nil {
+		if len(refs.Merge) > 0 && refs.Replace != nil {
+			return nil, t.idx.errorf("ignition.config", "merge and replace are mutually exclusive")
+		}
+		ign.Ignition.Config = &ign3ConfigRefs{}
+		for i, r := range refs.Merge {
+			res, err := t.resource(fmt.Sprintf("ignition.config.merge[%d]", i), r)
+			if err != nil {
+				return nil, err
+			}
+			ign.Ignition.Config.Merge = append(ign.Ignition.Config.Merge, *res)
+		}
+		if refs.Replace != nil {
+			res, err := t.resource("ignition.config.replace", *refs.Replace)
+			if err != nil {
+				return nil, err
+			}
+			ign.Ignition.Config.Replace = res
+		}
+	}
+
+	for i, f := range cfg.Storage.Files {
+		path := fmt.Sprintf("storage.files[%d]", i)
+		if f.Path == "" {
+			return nil, t.idx.errorf(path, "file is missing a path")
+		}
+		file := ign3File{
+			Path:      f.Path,
+			Overwrite: f.Overwrite,
+			Mode:      f.Mode,
+			User:      f.User.ignition(),
+			Group:     f.Group.ignition(),
+		}
+		if f.Contents != nil {
+			res, err := t.resource(path+".contents", *f.Contents)
+			if err != nil {
+				return nil, err
+			}
+			file.Contents = res
+		}
+		for j, a := range f.Append {
+			res, err := t.resource(fmt.Sprintf("%s.append[%d]", path, j), a)
+			if err != nil {
+				return nil, err
+			}
+			file.Append = append(file.Append, *res)
+		}
+		ign.Storage.Files = append(ign.Storage.Files, file)
+	}
+
+	for i, d := range cfg.Storage.Directories {
+		if d.Path == "" {
+			return nil, t.idx.errorf(fmt.Sprintf("storage.directories[%d]", i), "directory is missing a path")
+		}
+		ign.Storage.Directories = append(ign.Storage.Directories, ign3Directory{
+			Path:      d.Path,
+			Overwrite: d.Overwrite,
+			Mode:      d.Mode,
+			User:      d.User.ignition(),
+			Group:     d.Group.ignition(),
+		})
+	}
+
+	for i, l := range cfg.Storage.Links {
+		if l.Path == "" || l.Target == "" {
+			return nil, t.idx.errorf(fmt.Sprintf("storage.links[%d]", i), "link needs both a path and a target")
+		}
+		ign.Storage.Links = append(ign.Storage.Links, ign3Link{
+			Path:      l.Path,
+			Overwrite: l.Overwrite,
+			Target:    l.Target,
+			Hard:      l.Hard,
+			User:      l.User.ignition(),
+			Group:     l.Group.ignition(),
+		})
+	}
+
+	for i, u := range cfg.Systemd.Units {
+		if u.Name == "" {
+			return nil, t.idx.errorf(fmt.Sprintf("systemd.units[%d]", i), "unit is missing a name")
+		}
+		unit := ign3Unit{Name: u.Name, Enabled: u.Enabled, Mask: u.Mask, Contents: u.Contents}
+		for _, d := range u.Dropins {
+			unit.Dropins = append(unit.Dropins, ign3Dropin{Name: d.Name, Contents: d.Contents})
+		}
+		ign.Systemd.Units = append(ign.Systemd.Units, unit)
+	}
+
+	for i, u := range cfg.Passwd.Users {
+		if u.Name == "" {
+			return nil, t.idx.errorf(fmt.Sprintf("passwd.users[%d]", i), "user is missing a name")
+		}
+		ign.Passwd.Users = append(ign.Passwd.Users, ign3User{
+			Name:              u.Name,
+			PasswordHash:      u.PasswordHash,
+			SSHAuthorizedKeys: u.SSHAuthorizedKeys,
+			UID:               u.UID,
+			Gecos:             u.Gecos,
+			HomeDir:           u.HomeDir,
+			NoCreateHome:      u.NoCreateHome,
+			PrimaryGroup:      u.PrimaryGroup,
+			Groups:            u.Groups,
+			NoUserGroup:       u.NoUserGroup,
+			System:            u.System,
+			NoLogInit:         u.NoLogInit,
+			Shell:             u.Shell,
+		})
+	}
+
+	for i, g := range cfg.Passwd.Groups {
+		if g.Name == "" {
+			return nil, t.idx.errorf(fmt.Sprintf("passwd.groups[%d]", i), "group is missing a name")
+		}
+		ign.Passwd.Groups = append(ign.Passwd.Groups, ign3Group{
+			Name:         g.Name,
+			Gid:          g.Gid,
+			PasswordHash: g.PasswordHash,
+			System:       g.System,
+		})
+	}
+
+	return ign, nil
+}
+
+// resource resolves exactly one of source, inline or local into an Ignition
+// resource, embedding inline and local contents as data URLs.
+func (t *butaneTranspiler) resource(path string, r butaneResource) (*ign3Resource, error) {
+	set := 0
+	for _, ok := range []bool{r.Source != "", r.Inline != nil, r.Local != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, t.idx.errorf(path, "only one of source, inline and local may be set")
+	}
+
+	res := &ign3Resource{Source: r.Source}
+	switch {
+	case r.Inline != nil:
+		res.Source = dataURL(*r.Inline)
+	case r.Local != "":
+		if t.filesDir == "" {
+			return nil, t.idx.errorf(path+".local", "local file %q requires a files directory", r.Local)
+		}
+		p := filepath.Join(t.filesDir, filepath.FromSlash(r.Local))
+		if rel, err := filepath.Rel(t.filesDir, p); err != nil || strings.HasPrefix(rel, "..") {
+			return nil, t.idx.errorf(path+".local", "local file %q is outside the files directory", r.Local)
+		}
+		buf, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, t.idx.errorf(path+".local", "%s", err)
+		}
+		res.Source = dataURL(string(buf))
+	}
+
+	if h := r.Verification.Hash; h != "" {
+		if err := t.checkHash(path+".verification.hash", h); err != nil {
+			return nil, err
+		}
+		res.Verification = &ign3Verification{Hash: h}
+	}
+	return res, nil
+}
+
+func (t *butaneTranspiler) checkHash(path, h string) error {
+	parts := strings.SplitN(h, "-", 2)
+	size := map[string]int{"sha512": 64}
+	if t.spec != "3.0.0" {
+		size["sha256"] = 32
+	}
+	n, ok := size[parts[0]]
+	if len(parts) != 2 || !ok {
+		return t.idx.errorf(path, "unsupported hash %q for Ignition %s", h, t.spec)
+	}
+	if sum, err := hex.DecodeString(parts[1]); err != nil || len(sum) != n {
+		return t.idx.errorf(path, "malformed %s digest", parts[0])
+	}
+	return nil
+}
+
+func (n *butaneNode) ignition() *ign3Node {
+	if n == nil {
+		return nil
+	}
+	return &ign3Node{ID: n.ID, Name: n.Name}
+}
@@ -0,0 +1,118 @@
+package coreos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranspileButaneConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "butane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "motd"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := `variant: fcos
+version: 1.4.0
+ignition:
+  config:
+    merge:
+      - source: https://example.com/base.ign
+        verification:
+          hash: sha512-` + strings.Repeat("ab", 64) + `
+storage:
+  files:
+    - path: /etc/motd
+      mode: 0644
+      contents:
+        local: motd
+    - path: /etc/issue
+      contents:
+        inline: welcome
+systemd:
+  units:
+    - name: app.service
+      enabled: true
+      contents: |
+        [Service]
+        ExecStart=/usr/bin/sleep infinity
+`
+
+	ign, warnings, err := transpileButaneConfig(src, dir, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("warnings: %q", warnings)
+	}
+	if ign.Ignition.Version != "3.3.0" {
+		t.Fatalf("version: %s", ign.Ignition.Version)
+	}
+	merge := ign.Ignition.Config.Merge
+	if len(merge) != 1 || merge[0].Verification == nil || !strings.HasPrefix(merge[0].Verification.Hash, "sha512-") {
+		t.Fatalf("merge: %#v", merge)
+	}
+
+	files := ign.Storage.Files
+	if len(files) != 2 || *files[0].Mode != 0644 {
+		t.Fatalf("files: %#v", files)
+	}
+	if files[0].Contents.Source != dataURL("hello\n") || files[1].Contents.Source != dataURL("welcome") {
+		t.Fatalf("contents: %#v %#v", files[0].Contents, files[1].Contents)
+	}
+
+	if u := ign.Systemd.Units; len(u) != 1 || u[0].Enabled == nil || !*u[0].Enabled {
+		t.Fatalf("units: %#v", u)
+	}
+}
+
+func TestTranspileButaneConfigUnusedKeys(t *testing.T) {
+	src := "variant: fcos\nversion: 1.4.0\nsystemd:\n  units:\n    - name: a.service\n      enable: true\n"
+	want := []string{"6:7: unused key enable"}
+
+	_, warnings, err := transpileButaneConfig(src, "", false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Fatalf("warnings: %q, want %q", warnings, want)
+	}
+
+	if _, _, err := transpileButaneConfig(src, "", true); err == nil || err.Error() != want[0] {
+		t.Fatalf("strict: got %v, want %s", err, want[0])
+	}
+}
+
+func TestTranspileButaneConfigErrors(t *testing.T) {
+	cases := []struct {
+		src  string
+		want string
+	}{
+		{
+			"variant: rhcos\nversion: 1.4.0\n",
+			`1:1: unsupported variant "rhcos", must be fcos`,
+		},
+		{
+			"variant: fcos\nversion: 1.0.0\nignition:\n  config:\n    replace:\n      source: http://x\n      verification:\n        hash: sha256-00\n",
+			`8:9: unsupported hash "sha256-00" for Ignition 3.0.0`,
+		},
+		{
+			"variant: fcos\nversion: 1.4.0\nstorage:\n  files:\n    - path: /a\n      contents:\n        local: ../etc/passwd\n",
+			`7:9: local file "../etc/passwd" requires a files directory`,
+		},
+	}
+
+	for _, tc := range cases {
+		_, _, err := transpileButaneConfig(tc.src, "", false)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+}
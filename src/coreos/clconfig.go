@@ -0,0 +1,515 @@
+package coreos
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	clConfig struct {
+		Storage   clStorage              `yaml:"storage"`
+		Systemd   clSystemd              `yaml:"systemd"`
+		Networkd  clSystemd              `yaml:"networkd"`
+		Passwd    clPasswd               `yaml:"passwd"`
+		Etcd      map[string]interface{} `yaml:"etcd"`
+		Flannel   map[string]interface{} `yaml:"flannel"`
+		Locksmith clLocksmith            `yaml:"locksmith"`
+		Update    clUpdate               `yaml:"update"`
+		Docker    clDocker               `yaml:"docker"`
+	}
+
+	clStorage struct {
+		Files       []clFile      `yaml:"files"`
+		Directories []clDirectory `yaml:"directories"`
+		Links       []clLink      `yaml:"links"`
+	}
+
+	clFile struct {
+		Filesystem string         `yaml:"filesystem"`
+		Path       string         `yaml:"path"`
+		Contents   clFileContents `yaml:"contents"`
+		Mode       int            `yaml:"mode"`
+		User       *clNode        `yaml:"user"`
+		Group      *clNode        `yaml:"group"`
+	}
+
+	clFileContents struct {
+		Inline string   `yaml:"inline"`
+		Remote clRemote `yaml:"remote"`
+	}
+
+	clRemote struct {
+		URL          string         `yaml:"url"`
+		Verification clVerification `yaml:"verification"`
+	}
+
+	clVerification struct {
+		Hash clHash `yaml:"hash"`
+	}
+
+	clHash struct {
+		Function string `yaml:"function"`
+		Sum      string `yaml:"sum"`
+	}
+
+	clNode struct {
+		ID   *int   `yaml:"id"`
+		Name string `yaml:"name"`
+	}
+
+	clDirectory struct {
+		Filesystem string  `yaml:"filesystem"`
+		Path       string  `yaml:"path"`
+		Mode       int     `yaml:"mode"`
+		User       *clNode `yaml:"user"`
+		Group      *clNode `yaml:"group"`
+	}
+
+	clLink struct {
+		Filesystem string `yaml:"filesystem"`
+		Path       string `yaml:"path"`
+		Target     string `yaml:"target"`
+		Hard       bool   `yaml:"hard"`
+	}
+
+	clSystemd struct {
+		Units []clUnit `yaml:"units"`
+	}
+
+	clUnit struct {
+		Name     string     `yaml:"name"`
+		Enable   bool       `yaml:"enable"`
+		Mask     bool       `yaml:"mask"`
+		Contents string     `yaml:"contents"`
+		Dropins  []clDropin `yaml:"dropins"`
+	}
+
+	clDropin struct {
+		Name     string `yaml:"name"`
+		Contents string `yaml:"contents"`
+	}
+
+	clPasswd struct {
+		Users []clUser `yaml:"users"`
+	}
+
+	clUser struct {
+		Name              string   `yaml:"name"`
+		PasswordHash      string   `yaml:"password_hash"`
+		SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys"`
+		UID               *int     `yaml:"uid"`
+		Gecos             string   `yaml:"gecos"`
+		HomeDir           string   `yaml:"home_dir"`
+		NoCreateHome      bool     `yaml:"no_create_home"`
+		PrimaryGroup      string   `yaml:"primary_group"`
+		Groups            []string `yaml:"groups"`
+		NoUserGroup       bool     `yaml:"no_user_group"`
+		System            bool     `yaml:"system"`
+		NoLogInit         bool     `yaml:"no_log_init"`
+		Shell             string   `yaml:"shell"`
+	}
+
+	clLocksmith struct {
+		RebootStrategy string `yaml:"reboot_strategy"`
+		WindowStart    string `yaml:"window_start"`
+		WindowLength   string `yaml:"window_length"`
+		Group          string `yaml:"group"`
+		EtcdEndpoints  string `yaml:"etcd_endpoints"`
+		EtcdCAFile     string `yaml:"etcd_cafile"`
+		EtcdCertFile   string `yaml:"etcd_certfile"`
+		EtcdKeyFile    string `yaml:"etcd_keyfile"`
+	}
+
+	clUpdate struct {
+		Group  string `yaml:"group"`
+		Server string `yaml:"server"`
+	}
+
+	clDocker struct {
+		Flags []string `yaml:"flags"`
+	}
+
+	// clPlatform describes how coreos-metadata exposes dynamic values on a
+	// platform.
+	clPlatform struct {
+		provider string
+		vars     map[string]string
+	}
+)
+
+// clPlatforms are the platforms dynamic values can be resolved on, keyed
+// by the name accepted in the platform attribute.
+var clPlatforms = map[string]clPlatform{
+	"ec2": {"ec2", map[string]string{
+		"HOSTNAME":     "COREOS_EC2_HOSTNAME",
+		"PRIVATE_IPV4": "COREOS_EC2_IPV4_LOCAL",
+		"PUBLIC_IPV4":  "COREOS_EC2_IPV4_PUBLIC",
+	}},
+	"gce": {"gce", map[string]string{
+		"HOSTNAME":     "COREOS_GCE_HOSTNAME",
+		"PRIVATE_IPV4": "COREOS_GCE_IP_LOCAL_0",
+		"PUBLIC_IPV4":  "COREOS_GCE_IP_EXTERNAL_0",
+	}},
+	"azure": {"azure", map[string]string{
+		"PRIVATE_IPV4": "COREOS_AZURE_IPV4_DYNAMIC",
+		"PUBLIC_IPV4":  "COREOS_AZURE_IPV4_VIRTUAL",
+	}},
+	"digitalocean": {"digitalocean", map[string]string{
+		"HOSTNAME":     "COREOS_DIGITALOCEAN_HOSTNAME",
+		"PRIVATE_IPV4": "COREOS_DIGITALOCEAN_IPV4_PRIVATE_0",
+		"PUBLIC_IPV4":  "COREOS_DIGITALOCEAN_IPV4_PUBLIC_0",
+		"PRIVATE_IPV6": "COREOS_DIGITALOCEAN_IPV6_PRIVATE_0",
+		"PUBLIC_IPV6":  "COREOS_DIGITALOCEAN_IPV6_PUBLIC_0",
+	}},
+	"packet": {"packet", map[string]string{
+		"HOSTNAME":     "COREOS_PACKET_HOSTNAME",
+		"PRIVATE_IPV4": "COREOS_PACKET_IPV4_PRIVATE_0",
+		"PUBLIC_IPV4":  "COREOS_PACKET_IPV4_PUBLIC_0",
+		"PUBLIC_IPV6":  "COREOS_PACKET_IPV6_PUBLIC_0",
+	}},
+	"vagrant": {"vagrant-virtualbox", map[string]string{
+		"HOSTNAME":     "COREOS_VAGRANT_VIRTUALBOX_HOSTNAME",
+		"PRIVATE_IPV4": "COREOS_VAGRANT_VIRTUALBOX_PRIVATE_IPV4",
+	}},
+}
+
+var clDynamicRe = regexp.MustCompile(`\{(HOSTNAME|PRIVATE_IPV4|PUBLIC_IPV4|PRIVATE_IPV6|PUBLIC_IPV6)\}`)
+
+// clDropIn is the drop-in name used for everything the transpiler derives
+// from the etcd, flannel, locksmith and docker sections.
+func clDropIn(unit string) string {
+	return "20-clct-" + strings.TrimSuffix(unit, ".service") + ".conf"
+}
+
+// TranspileContainerLinuxConfig converts a Container Linux Config into an
+// Ignition config. Dynamic values such as {PRIVATE_IPV4} are resolved
+// through coreos-metadata on the given platform. Errors point at the
+// offending line and column of the YAML.
+func TranspileContainerLinuxConfig(src, platform string) (string, error) {
+	ign, err := transpileContainerLinuxConfig(src, platform)
+	if err != nil {
+		return "", err
+	}
+	return ign.String(), nil
+}
+
+func transpileContainerLinuxConfig(src, platform string) (*ignitionConfig, error) {
+	if _, ok := clPlatforms[platform]; platform != "" && !ok {
+		return nil, fmt.Errorf("unknown platform %q", platform)
+	}
+
+	idx := newYAMLIndex(src)
+	var cfg clConfig
+	if err := yaml.UnmarshalStrict([]byte(src), &cfg); err != nil {
+		return nil, idx.wrapError(err)
+	}
+
+	t := &clTranspiler{idx: idx, platform: platform, ign: newIgnitionConfig()}
+	steps := []func(*clConfig) error{
+		t.storage,
+		t.systemd,
+		t.passwd,
+		t.etcd,
+		t.flannel,
+		t.update,
+		t.docker,
+	}
+	for _, step := range steps {
+		if err := step(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	return t.ign, nil
+}
+
+type clTranspiler struct {
+	idx      *yamlIndex
+	platform string
+	ign      *ignitionConfig
+	metadata bool
+}
+
+func (t *clTranspiler) storage(cfg *clConfig) error {
+	for i, f := range cfg.Storage.Files {
+		path := fmt.Sprintf("storage.files[%d]", i)
+		if f.Path == "" {
+			return t.idx.errorf(path, "file is missing a path")
+		}
+
+		contents := ignitionFileContents{Source: dataURL(f.Contents.Inline)}
+		if r := f.Contents.Remote; r.URL != "" {
+			if f.Contents.Inline != "" {
+				return t.idx.errorf(path+".contents", "inline and remote contents are mutually exclusive")
+			}
+			contents.Source = r.URL
+			if h := r.Verification.Hash; h.Sum != "" {
+				if h.Function != "sha512" {
+					return t.idx.errorf(path+".contents.remote.verification.hash.function", "unsupported hash function %q, must be sha512", h.Function)
+				}
+				contents.Verification = &ignitionVerification{Hash: h.Function + "-" + h.Sum}
+			}
+		}
+
+		t.ign.Storage.Files = append(t.ign.Storage.Files, ignitionFile{
+			Filesystem: clFilesystem(f.Filesystem),
+			Path:       f.Path,
+			Contents:   contents,
+			Mode:       f.Mode,
+			User:       f.User.ignition(),
+			Group:      f.Group.ignition(),
+		})
+	}
+
+	for i, d := range cfg.Storage.Directories {
+		if d.Path == "" {
+			return t.idx.errorf(fmt.Sprintf("storage.directories[%d]", i), "directory is missing a path")
+		}
+		t.ign.Storage.Directories = append(t.ign.Storage.Directories, ignitionDirectory{
+			Filesystem: clFilesystem(d.Filesystem),
+			Path:       d.Path,
+			Mode:       d.Mode,
+			User:       d.User.ignition(),
+			Group:      d.Group.ignition(),
+		})
+	}
+
+	for i, l := range cfg.Storage.Links {
+		if l.Path == "" || l.Target == "" {
+			return t.idx.errorf(fmt.Sprintf("storage.links[%d]", i), "link needs both a path and a target")
+		}
+		t.ign.Storage.Links = append(t.ign.Storage.Links, ignitionLink{
+			Filesystem: clFilesystem(l.Filesystem),
+			Path:       l.Path,
+			Target:     l.Target,
+			Hard:       l.Hard,
+		})
+	}
+	return nil
+}
+
+func clFilesystem(fs string) string {
+	if fs == "" {
+		return "root"
+	}
+	return fs
+}
+
+func (n *clNode) ignition() *ignitionNode {
+	if n == nil {
+		return nil
+	}
+	return &ignitionNode{ID: n.ID, Name: n.Name}
+}
+
+func (t *clTranspiler) systemd(cfg *clConfig) error {
+	for i, u := range cfg.Systemd.Units {
+		if u.Name == "" {
+			return t.idx.errorf(fmt.Sprintf("systemd.units[%d]", i), "unit is missing a name")
+		}
+		unit := t.ign.unit(u.Name)
+		unit.Enable = u.Enable
+		unit.Mask = u.Mask
+		unit.Contents = u.Contents
+		for _, d := range u.Dropins {
+			unit.Dropins = append(unit.Dropins, ignitionDropin{Name: d.Name, Contents: d.Contents})
+		}
+	}
+
+	for i, u := range cfg.Networkd.Units {
+		if u.Name == "" {
+			return t.idx.errorf(fmt.Sprintf("networkd.units[%d]", i), "unit is missing a name")
+		}
+		t.ign.Networkd.Units = append(t.ign.Networkd.Units, ignitionUnit{Name: u.Name, Contents: u.Contents})
+	}
+	return nil
+}
+
+func (t *clTranspiler) passwd(cfg *clConfig) error {
+	for i, u := range cfg.Passwd.Users {
+		if u.Name == "" {
+			return t.idx.errorf(fmt.Sprintf("passwd.users[%d]", i), "user is missing a name")
+		}
+		user := t.ign.user(u.Name)
+		user.PasswordHash = u.PasswordHash
+		user.SSHAuthorizedKeys = u.SSHAuthorizedKeys
+		user.UID = u.UID
+		user.Gecos = u.Gecos
+		user.HomeDir = u.HomeDir
+		user.NoCreateHome = u.NoCreateHome
+		user.PrimaryGroup = u.PrimaryGroup
+		user.Groups = u.Groups
+		user.NoUserGroup = u.NoUserGroup
+		user.System = u.System
+		user.NoLogInit = u.NoLogInit
+		user.Shell = u.Shell
+	}
+	return nil
+}
+
+func (t *clTranspiler) etcd(cfg *clConfig) error {
+	if len(cfg.Etcd) == 0 {
+		return nil
+	}
+	return t.wrapper("etcd", "etcd-member.service", "/usr/lib/coreos/etcd-wrapper $ETCD_OPTS", "ETCD_IMAGE_TAG", cfg.Etcd, nil)
+}
+
+func (t *clTranspiler) flannel(cfg *clConfig) error {
+	if len(cfg.Flannel) == 0 {
+		return nil
+	}
+	var pre []string
+	if nc, ok := cfg.Flannel["network_config"]; ok {
+		delete(cfg.Flannel, "network_config")
+		pre = append(pre, fmt.Sprintf("ExecStartPre=/usr/bin/etcdctl set /coreos.com/network/config '%v'", nc))
+	}
+	return t.wrapper("flannel", "flanneld.service", "/usr/lib/coreos/flannel-wrapper $FLANNEL_OPTS", "FLANNEL_IMAGE_TAG", cfg.Flannel, pre)
+}
+
+// wrapper renders the options of the etcd or flannel section as flags to
+// the service's wrapper script, resolving dynamic values on the way.
+func (t *clTranspiler) wrapper(section, unit, command, tagVar string, opts map[string]interface{}, pre []string) error {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var (
+		version string
+		flags   []string
+		dynamic bool
+	)
+	for _, k := range keys {
+		v := fmt.Sprint(opts[k])
+		if k == "version" {
+			version = v
+			continue
+		}
+		resolved, err := t.resolve(fmt.Sprintf("%s.%s", section, k), v)
+		if err != nil {
+			return err
+		}
+		dynamic = dynamic || resolved != v
+		flags = append(flags, fmt.Sprintf("--%s=\"%s\"", strings.Replace(k, "_", "-", -1), resolved))
+	}
+
+	var buf bytes.Buffer
+	if dynamic {
+		buf.WriteString("[Unit]\nRequires=coreos-metadata.service\nAfter=coreos-metadata.service\n\n")
+	}
+	buf.WriteString("[Service]\n")
+	if dynamic {
+		buf.WriteString("EnvironmentFile=/run/metadata/coreos\n")
+	}
+	if version != "" {
+		fmt.Fprintf(&buf, "Environment=\"%s=v%s\"\n", tagVar, strings.TrimPrefix(version, "v"))
+	}
+	for _, p := range pre {
+		buf.WriteString(p + "\n")
+	}
+	if len(flags) > 0 {
+		fmt.Fprintf(&buf, "ExecStart=\nExecStart=%s \\\n  %s\n", command, strings.Join(flags, " \\\n  "))
+	}
+
+	u := t.ign.unit(unit)
+	u.Enable = true
+	u.Dropins = append(u.Dropins, ignitionDropin{Name: clDropIn(unit), Contents: buf.String()})
+
+	if dynamic && !t.metadata {
+		t.metadata = true
+		md := t.ign.unit("coreos-metadata.service")
+		md.Dropins = append(md.Dropins, ignitionDropin{
+			Name:     "20-clct-provider-override.conf",
+			Contents: fmt.Sprintf("[Service]\nEnvironment=COREOS_METADATA_OPT_PROVIDER=--provider=%s\n", clPlatforms[t.platform].provider),
+		})
+	}
+	return nil
+}
+
+// resolve replaces dynamic values in v with the coreos-metadata variable
+// for the configured platform.
+func (t *clTranspiler) resolve(path, v string) (string, error) {
+	var err error
+	out := clDynamicRe.ReplaceAllStringFunc(v, func(m string) string {
+		if err != nil {
+			return m
+		}
+		name := m[1 : len(m)-1]
+		p, ok := clPlatforms[t.platform]
+		if !ok {
+			err = t.dynamicError(path, m, "dynamic value %s requires a platform", m)
+			return m
+		}
+		env, ok := p.vars[name]
+		if !ok {
+			err = t.dynamicError(path, m, "dynamic value %s is not available on platform %q", m, t.platform)
+			return m
+		}
+		return "${" + env + "}"
+	})
+	return out, err
+}
+
+func (t *clTranspiler) dynamicError(path, token, format string, args ...interface{}) error {
+	if pos, ok := t.idx.find(token); ok {
+		return &yamlError{pos.line, pos.column, fmt.Sprintf(format, args...)}
+	}
+	return t.idx.errorf(path, format, args...)
+}
+
+func (t *clTranspiler) update(cfg *clConfig) error {
+	var conf bytes.Buffer
+	if cfg.Update.Group != "" {
+		fmt.Fprintf(&conf, "GROUP=%s\n", cfg.Update.Group)
+	}
+	if cfg.Update.Server != "" {
+		fmt.Fprintf(&conf, "SERVER=%s\n", cfg.Update.Server)
+	}
+
+	l := cfg.Locksmith
+	switch l.RebootStrategy {
+	case "":
+	case "reboot", "etcd-lock", "best-effort", "off":
+		fmt.Fprintf(&conf, "REBOOT_STRATEGY=%s\n", l.RebootStrategy)
+	default:
+		return t.idx.errorf("locksmith.reboot_strategy", "invalid reboot strategy %q", l.RebootStrategy)
+	}
+	if conf.Len() > 0 {
+		t.ign.addFile("/etc/coreos/update.conf", conf.String(), 0644)
+	}
+
+	lc := &locksmithConfig{
+		windowStart:  l.WindowStart,
+		windowLength: l.WindowLength,
+		group:        l.Group,
+		endpoints:    l.EtcdEndpoints,
+		caFile:       l.EtcdCAFile,
+		certFile:     l.EtcdCertFile,
+		keyFile:      l.EtcdKeyFile,
+	}
+	if dropin := lc.dropIn(); dropin != "" {
+		u := t.ign.unit("locksmithd.service")
+		u.Dropins = append(u.Dropins, ignitionDropin{
+			Name:     clDropIn("locksmithd.service"),
+			Contents: dropin,
+		})
+	}
+	return nil
+}
+
+func (t *clTranspiler) docker(cfg *clConfig) error {
+	if len(cfg.Docker.Flags) == 0 {
+		return nil
+	}
+	u := t.ign.unit("docker.service")
+	u.Dropins = append(u.Dropins, ignitionDropin{
+		Name:     clDropIn("docker.service"),
+		Contents: dockerOptsDropIn(cfg.Docker.Flags),
+	})
+	return nil
+}
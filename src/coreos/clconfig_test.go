@@ -0,0 +1,116 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranspileContainerLinuxConfig(t *testing.T) {
+	src := `storage:
+  files:
+    - path: /etc/motd
+      filesystem: root
+      mode: 0644
+      contents:
+        inline: |
+          hello
+systemd:
+  units:
+    - name: app.service
+      enable: true
+      contents: |
+        [Service]
+        ExecStart=/usr/bin/sleep infinity
+etcd:
+  version: 3.0.15
+  name: "{HOSTNAME}"
+  advertise_client_urls: "http://{PRIVATE_IPV4}:2379"
+locksmith:
+  reboot_strategy: etcd-lock
+  window_start: Thu 04:00
+`
+
+	ign, err := transpileContainerLinuxConfig(src, "ec2")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(ign.Storage.Files) != 2 {
+		t.Fatalf("files: %#v", ign.Storage.Files)
+	}
+	motd := ign.Storage.Files[0]
+	if motd.Mode != 0644 || motd.Contents.Source != dataURL("hello\n") {
+		t.Fatalf("motd: %#v", motd)
+	}
+
+	etcd := ign.unit("etcd-member.service")
+	if !etcd.Enable || len(etcd.Dropins) != 1 {
+		t.Fatalf("etcd-member.service: %#v", etcd)
+	}
+	want := `[Unit]
+Requires=coreos-metadata.service
+After=coreos-metadata.service
+
+[Service]
+EnvironmentFile=/run/metadata/coreos
+Environment="ETCD_IMAGE_TAG=v3.0.15"
+ExecStart=
+ExecStart=/usr/lib/coreos/etcd-wrapper $ETCD_OPTS \
+  --advertise-client-urls="http://${COREOS_EC2_IPV4_LOCAL}:2379" \
+  --name="${COREOS_EC2_HOSTNAME}"
+`
+	if got := etcd.Dropins[0].Contents; got != want {
+		t.Fatalf("etcd drop-in:\n%s\nwant:\n%s", got, want)
+	}
+
+	md := ign.unit("coreos-metadata.service")
+	if len(md.Dropins) != 1 || !strings.Contains(md.Dropins[0].Contents, "--provider=ec2") {
+		t.Fatalf("coreos-metadata.service: %#v", md)
+	}
+
+	lock := ign.unit("locksmithd.service")
+	if len(lock.Dropins) != 1 || !strings.Contains(lock.Dropins[0].Contents, `LOCKSMITHD_REBOOT_WINDOW_START=Thu 04:00`) {
+		t.Fatalf("locksmithd.service: %#v", lock)
+	}
+}
+
+func TestTranspileContainerLinuxConfigErrors(t *testing.T) {
+	cases := []struct {
+		platform string
+		src      string
+		want     string
+	}{
+		{
+			"",
+			"etcd:\n  name: \"{HOSTNAME}\"\n",
+			"2:10: dynamic value {HOSTNAME} requires a platform",
+		},
+		{
+			"azure",
+			"etcd:\n  name: \"{HOSTNAME}\"\n",
+			`2:10: dynamic value {HOSTNAME} is not available on platform "azure"`,
+		},
+		{
+			"",
+			"systemd:\n  units:\n    - name: a.service\n      bogus: true\n",
+			"4:7: field bogus not found in type coreos.clUnit",
+		},
+		{
+			"",
+			"storage:\n  files:\n    - mode: 0644\n",
+			"3:5: file is missing a path",
+		},
+		{
+			"",
+			"locksmith:\n  reboot_strategy: sometimes\n",
+			`2:3: invalid reboot strategy "sometimes"`,
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := transpileContainerLinuxConfig(tc.src, tc.platform)
+		if err == nil || err.Error() != tc.want {
+			t.Errorf("%q: got %v, want %s", tc.src, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package coreos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// cloudConfigHeader is the first line coreos-cloudinit requires of a
+// cloud-config document.
+const cloudConfigHeader = "#cloud-config"
+
+type (
+	cloudConfig struct {
+		CoreOS            cloudConfigCoreOS `yaml:"coreos,omitempty"`
+		WriteFiles        []cloudConfigFile `yaml:"write_files,omitempty"`
+		Users             []cloudConfigUser `yaml:"users,omitempty"`
+		SSHAuthorizedKeys []string          `yaml:"ssh_authorized_keys,omitempty"`
+		Hostname          string            `yaml:"hostname,omitempty"`
+		ManageEtcHosts    string            `yaml:"manage_etc_hosts,omitempty"`
+	}
+
+	cloudConfigCoreOS struct {
+		Etcd      map[string]interface{} `yaml:"etcd,omitempty"`
+		Etcd2     map[string]interface{} `yaml:"etcd2,omitempty"`
+		Fleet     map[string]interface{} `yaml:"fleet,omitempty"`
+		Flannel   map[string]interface{} `yaml:"flannel,omitempty"`
+		Locksmith map[string]interface{} `yaml:"locksmith,omitempty"`
+		Update    map[string]interface{} `yaml:"update,omitempty"`
+		Units     []cloudConfigUnit      `yaml:"units,omitempty"`
+	}
+
+	cloudConfigUnit struct {
+		Name    string              `yaml:"name"`
+		Mask    bool                `yaml:"mask,omitempty"`
+		Enable  bool                `yaml:"enable,omitempty"`
+		Runtime bool                `yaml:"runtime,omitempty"`
+		Content string              `yaml:"content,omitempty"`
+		Command string              `yaml:"command,omitempty"`
+		DropIns []cloudConfigDropIn `yaml:"drop_ins,omitempty"`
+	}
+
+	cloudConfigDropIn struct {
+		Name    string `yaml:"name"`
+		Content string `yaml:"content"`
+	}
+
+	cloudConfigFile struct {
+		Path               string `yaml:"path"`
+		Content            string `yaml:"content,omitempty"`
+		Encoding           string `yaml:"encoding,omitempty"`
+		Owner              string `yaml:"owner,omitempty"`
+		RawFilePermissions string `yaml:"permissions,omitempty"`
+	}
+
+	cloudConfigUser struct {
+		Name                 string   `yaml:"name"`
+		PasswordHash         string   `yaml:"passwd,omitempty"`
+		SSHAuthorizedKeys    []string `yaml:"ssh_authorized_keys,omitempty"`
+		SSHImportGithubUser  string   `yaml:"coreos_ssh_import_github,omitempty"`
+		SSHImportGithubUsers []string `yaml:"coreos_ssh_import_github_users,omitempty"`
+		SSHImportURL         string   `yaml:"coreos_ssh_import_url,omitempty"`
+		GECOS                string   `yaml:"gecos,omitempty"`
+		Homedir              string   `yaml:"homedir,omitempty"`
+		NoCreateHome         bool     `yaml:"no_create_home,omitempty"`
+		PrimaryGroup         string   `yaml:"primary_group,omitempty"`
+		Groups               []string `yaml:"groups,omitempty"`
+		NoUserGroup          bool     `yaml:"no_user_group,omitempty"`
+		System               bool     `yaml:"system,omitempty"`
+		NoLogInit            bool     `yaml:"no_log_init,omitempty"`
+		Shell                string   `yaml:"shell,omitempty"`
+	}
+)
+
+// parseCloudConfig decodes a cloud-config document. Keys that
+// coreos-cloudinit would ignore are returned alongside the config so
+// callers can report them.
+func parseCloudConfig(data string) (*cloudConfig, []string, error) {
+	if !strings.HasPrefix(data, cloudConfigHeader) {
+		if strings.HasPrefix(data, "#!") {
+			return nil, nil, fmt.Errorf("scripts cannot be converted, only %s documents", cloudConfigHeader)
+		}
+		return nil, nil, fmt.Errorf("cloud-config must start with %q", cloudConfigHeader)
+	}
+
+	var cfg cloudConfig
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, nil, err
+	}
+
+	var unknown []string
+	for k, v := range raw {
+		switch k {
+		case "coreos":
+			m, ok := v.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			for ck := range m {
+				switch ck {
+				case "etcd", "etcd2", "fleet", "flannel", "locksmith", "update", "units":
+				default:
+					unknown = append(unknown, fmt.Sprintf("coreos.%v", ck))
+				}
+			}
+		case "write_files", "users", "ssh_authorized_keys", "hostname", "manage_etc_hosts":
+		default:
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	return &cfg, unknown, nil
+}
+
+// String renders the config as a cloud-config document.
+func (c *cloudConfig) String() string {
+	buf, err := yaml.Marshal(c)
+	if err != nil {
+		// every field is a plain string, list or map
+		panic(err)
+	}
+	return cloudConfigHeader + "\n" + string(buf)
+}
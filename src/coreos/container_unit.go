@@ -0,0 +1,192 @@
+package coreos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// containerUnit describes a container run forever by a systemd service.
+type containerUnit struct {
+	name            string
+	image           string
+	tag             string
+	digest          string
+	args            []string
+	ports           []string
+	volumes         []string
+	env             map[string]string
+	restart         string
+	restartSec      string
+	timeoutStartSec string
+	requires        []string
+	after           []string
+	runtime         string
+
+	fleetConflicts       []string
+	fleetMachineMetadata []string
+	fleetMachineOf       string
+	fleetGlobal          bool
+}
+
+// imageRef returns the image pinned by digest when one is given.
+func (c *containerUnit) imageRef() string {
+	if c.digest != "" {
+		return c.image + "@" + c.digest
+	}
+	return c.image + ":" + c.tag
+}
+
+// serviceName returns the container name, which is the unit name without
+// its .service suffix.
+func (c *containerUnit) serviceName() string {
+	return strings.TrimSuffix(c.name, ".service")
+}
+
+func (c *containerUnit) unit() (*systemdUnit, error) {
+	f := &unitFile{}
+	f.add("Unit", "Description", fmt.Sprintf("%s container", c.serviceName()))
+
+	var err error
+	switch c.runtime {
+	case "docker":
+		err = c.docker(f)
+	case "rkt":
+		err = c.rkt(f)
+	default:
+		err = fmt.Errorf("unknown runtime %q, must be docker or rkt", c.runtime)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.add("Service", "TimeoutStartSec", c.timeoutStartSec)
+	f.add("Service", "Restart", c.restart)
+	if c.restart != "no" {
+		f.add("Service", "RestartSec", c.restartSec)
+	}
+	f.add("Install", "WantedBy", "multi-user.target")
+
+	for _, v := range c.fleetConflicts {
+		f.add("X-Fleet", "Conflicts", v)
+	}
+	for _, v := range c.fleetMachineMetadata {
+		f.add("X-Fleet", "MachineMetadata", v)
+	}
+	if c.fleetMachineOf != "" {
+		f.add("X-Fleet", "MachineOf", c.fleetMachineOf)
+	}
+	if c.fleetGlobal {
+		f.add("X-Fleet", "Global", "true")
+	}
+
+	return &systemdUnit{name: c.serviceName() + ".service", enable: true, file: f}, nil
+}
+
+func (c *containerUnit) dependencies(f *unitFile, runtime string) {
+	for _, r := range append([]string{runtime}, c.requires...) {
+		f.add("Unit", "Requires", r)
+	}
+	for _, a := range append([]string{runtime}, c.after...) {
+		f.add("Unit", "After", a)
+	}
+}
+
+func (c *containerUnit) docker(f *unitFile) error {
+	c.dependencies(f, "docker.service")
+
+	name := c.serviceName()
+	ref := c.imageRef()
+
+	run := []string{"/usr/bin/docker", "run", "--rm", "--name", name}
+	for _, p := range c.ports {
+		run = append(run, "-p", p)
+	}
+	for _, v := range c.volumes {
+		run = append(run, "-v", v)
+	}
+	for _, k := range sortedKeys(c.env) {
+		run = append(run, "-e", k+"="+c.env[k])
+	}
+	run = append(run, ref)
+	run = append(run, c.args...)
+
+	f.add("Service", "ExecStartPre", "-/usr/bin/docker kill "+name)
+	f.add("Service", "ExecStartPre", "-/usr/bin/docker rm "+name)
+	f.add("Service", "ExecStartPre", "/usr/bin/docker pull "+ref)
+	f.add("Service", "ExecStart", unitCommand(run))
+	f.add("Service", "ExecStop", "/usr/bin/docker stop "+name)
+	return nil
+}
+
+func (c *containerUnit) rkt(f *unitFile) error {
+	c.dependencies(f, "network-online.target")
+
+	uuidFile := fmt.Sprintf("/var/run/%s.uuid", c.serviceName())
+	image := "docker://" + c.imageRef()
+
+	run := []string{"/usr/bin/rkt", "run", "--insecure-options=image", "--uuid-file-save=" + uuidFile}
+	for _, p := range c.ports {
+		// docker2aci names ports <port>-<protocol>
+		parts := strings.SplitN(p, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("rkt ports must be host:container, got %q", p)
+		}
+		container, proto := parts[1], "tcp"
+		if i := strings.Index(container, "/"); i >= 0 {
+			container, proto = container[:i], container[i+1:]
+		}
+		run = append(run, fmt.Sprintf("--port=%s-%s:%s", container, proto, parts[0]))
+	}
+	for i, v := range c.volumes {
+		parts := strings.SplitN(v, ":", 3)
+		if len(parts) < 2 {
+			return fmt.Errorf("volumes must be host:container, got %q", v)
+		}
+		vol := fmt.Sprintf("volume-%d", i)
+		spec := fmt.Sprintf("--volume=%s,kind=host,source=%s", vol, parts[0])
+		if len(parts) == 3 && parts[2] == "ro" {
+			spec += ",readOnly=true"
+		}
+		run = append(run, spec, fmt.Sprintf("--mount=volume=%s,target=%s", vol, parts[1]))
+	}
+	for _, k := range sortedKeys(c.env) {
+		run = append(run, "--set-env="+k+"="+c.env[k])
+	}
+	run = append(run, image)
+	if len(c.args) > 0 {
+		run = append(run, "--")
+		run = append(run, c.args...)
+	}
+
+	f.add("Service", "ExecStartPre", "-/usr/bin/rkt rm --uuid-file="+uuidFile)
+	f.add("Service", "ExecStartPre", "/usr/bin/rkt fetch --insecure-options=image "+image)
+	f.add("Service", "ExecStart", unitCommand(run))
+	f.add("Service", "ExecStop", "-/usr/bin/rkt stop --uuid-file="+uuidFile)
+	f.add("Service", "ExecStopPost", "-/usr/bin/rkt gc --mark-only")
+	return nil
+}
+
+// unitCommand joins args into an Exec line, quoting the ones systemd would
+// otherwise split and escaping specifiers and variables so they are passed
+// on as they are.
+func unitCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		a = strings.Replace(escapeSpecifiers(a), "$", "$$", -1)
+		if a == "" || strings.ContainsAny(a, " \t\"'\\") {
+			a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
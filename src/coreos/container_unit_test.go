@@ -0,0 +1,99 @@
+package coreos
+
+import "testing"
+
+func TestContainerUnitDocker(t *testing.T) {
+	c := &containerUnit{
+		name:            "web",
+		image:           "nginx",
+		tag:             "1.9",
+		ports:           []string{"80:80"},
+		volumes:         []string{"/srv/www:/usr/share/nginx/html:ro"},
+		env:             map[string]string{"GREETING": "hello world"},
+		restart:         "always",
+		restartSec:      "10",
+		timeoutStartSec: "5min",
+		after:           []string{"etcd2.service"},
+		runtime:         "docker",
+		fleetConflicts:  []string{"web*.service"},
+	}
+	u, err := c.unit()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := `[Unit]
+Description=web container
+Requires=docker.service
+After=docker.service
+After=etcd2.service
+
+[Service]
+ExecStartPre=-/usr/bin/docker kill web
+ExecStartPre=-/usr/bin/docker rm web
+ExecStartPre=/usr/bin/docker pull nginx:1.9
+ExecStart=/usr/bin/docker run --rm --name web -p 80:80 -v /srv/www:/usr/share/nginx/html:ro -e "GREETING=hello world" nginx:1.9
+ExecStop=/usr/bin/docker stop web
+TimeoutStartSec=5min
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=multi-user.target
+
+[X-Fleet]
+Conflicts=web*.service
+`
+	if got := u.content(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if w := u.lint(); len(w) != 0 {
+		t.Fatalf("lint: %q", w)
+	}
+}
+
+func TestContainerUnitRkt(t *testing.T) {
+	c := &containerUnit{
+		name:            "web.service",
+		image:           "nginx",
+		digest:          "sha256:abcd",
+		ports:           []string{"8080:80"},
+		volumes:         []string{"/srv/www:/usr/share/nginx/html"},
+		restart:         "no",
+		timeoutStartSec: "5min",
+		runtime:         "rkt",
+	}
+	u, err := c.unit()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := `[Unit]
+Description=web container
+Requires=network-online.target
+After=network-online.target
+
+[Service]
+ExecStartPre=-/usr/bin/rkt rm --uuid-file=/var/run/web.uuid
+ExecStartPre=/usr/bin/rkt fetch --insecure-options=image docker://nginx@sha256:abcd
+ExecStart=/usr/bin/rkt run --insecure-options=image --uuid-file-save=/var/run/web.uuid --port=80-tcp:8080 --volume=volume-0,kind=host,source=/srv/www --mount=volume=volume-0,target=/usr/share/nginx/html docker://nginx@sha256:abcd
+ExecStop=-/usr/bin/rkt stop --uuid-file=/var/run/web.uuid
+ExecStopPost=-/usr/bin/rkt gc --mark-only
+TimeoutStartSec=5min
+Restart=no
+
+[Install]
+WantedBy=multi-user.target
+`
+	if got := u.content(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUnitCommand(t *testing.T) {
+	got := unitCommand([]string{"/usr/bin/docker", "run", "-e", "PASSWORD=50%$off", "-e", "GREETING=hello world", "sh", "-c", "echo $HOME"})
+	want := `/usr/bin/docker run -e PASSWORD=50%%$$off -e "GREETING=hello world" sh -c "echo $$HOME"`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
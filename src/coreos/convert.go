@@ -0,0 +1,312 @@
+package coreos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cloudinitDropIn is the drop-in name coreos-cloudinit uses for the
+// environment it derives from the coreos.* sections.
+const cloudinitDropIn = "20-cloudinit.conf"
+
+// serviceSections maps the coreos.* cloud-config sections that configure a
+// service through its environment to the unit and variable prefix used.
+// names lists the keys whose variable isn't the prefix followed by the
+// upper-cased key.
+var serviceSections = []struct {
+	section string
+	unit    string
+	prefix  string
+	names   map[string]string
+	get     func(*cloudConfigCoreOS) map[string]interface{}
+}{
+	{"etcd", "etcd.service", "ETCD_", nil, func(c *cloudConfigCoreOS) map[string]interface{} { return c.Etcd }},
+	{"etcd2", "etcd2.service", "ETCD_", nil, func(c *cloudConfigCoreOS) map[string]interface{} { return c.Etcd2 }},
+	{"fleet", "fleet.service", "FLEET_", nil, func(c *cloudConfigCoreOS) map[string]interface{} { return c.Fleet }},
+	{"flannel", "flanneld.service", "FLANNELD_", map[string]string{
+		"interface": "FLANNELD_IFACE",
+	}, func(c *cloudConfigCoreOS) map[string]interface{} { return c.Flannel }},
+	{"locksmith", "locksmithd.service", "LOCKSMITHD_", map[string]string{
+		"window_start":  "LOCKSMITHD_REBOOT_WINDOW_START",
+		"window_length": "LOCKSMITHD_REBOOT_WINDOW_LENGTH",
+	}, func(c *cloudConfigCoreOS) map[string]interface{} { return c.Locksmith }},
+}
+
+// cloudinitSubstitutions are the variables coreos-cloudinit replaces with
+// the machine's addresses anywhere in the cloud-config.
+var cloudinitSubstitutions = []string{"$private_ipv4", "$public_ipv4", "$private_ipv6", "$public_ipv6"}
+
+// ConvertCloudConfig translates a cloud-config document into an Ignition
+// config. Anything that has no Ignition equivalent is skipped and
+// described in the returned warnings.
+func ConvertCloudConfig(data string) (string, []string, error) {
+	ign, warnings, err := convertCloudConfig(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return ign.String(), warnings, nil
+}
+
+func convertCloudConfig(data string) (*ignitionConfig, []string, error) {
+	cc, unknown, err := parseCloudConfig(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	for _, k := range unknown {
+		warnings = append(warnings, fmt.Sprintf("%s: unsupported key ignored", k))
+	}
+
+	ign := newIgnitionConfig()
+
+	for _, s := range serviceSections {
+		env := s.get(&cc.CoreOS)
+		if len(env) == 0 {
+			continue
+		}
+		u := ign.unit(s.unit)
+		u.Enable = true
+		u.Dropins = append(u.Dropins, ignitionDropin{
+			Name:     cloudinitDropIn,
+			Contents: environmentDropIn(s.prefix, s.names, env),
+		})
+		for _, k := range sortedEnvKeys(env) {
+			warnings = append(warnings, substitutionWarnings("coreos."+s.section+"."+k, fmt.Sprint(env[k]))...)
+		}
+	}
+
+	if len(cc.CoreOS.Update) > 0 {
+		warnings = append(warnings, convertUpdate(ign, cc.CoreOS.Update)...)
+	}
+
+	for _, cu := range cc.CoreOS.Units {
+		warnings = append(warnings, convertUnit(ign, cu)...)
+	}
+
+	for _, f := range cc.WriteFiles {
+		w, err := convertFile(ign, f)
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, w...)
+	}
+
+	for _, cu := range cc.Users {
+		warnings = append(warnings, convertUser(ign, cu)...)
+	}
+
+	if len(cc.SSHAuthorizedKeys) > 0 {
+		u := ign.user("core")
+		u.SSHAuthorizedKeys = append(u.SSHAuthorizedKeys, cc.SSHAuthorizedKeys...)
+	}
+
+	if cc.Hostname != "" {
+		warnings = append(warnings, substitutionWarnings("hostname", cc.Hostname)...)
+		ign.addFile("/etc/hostname", cc.Hostname+"\n", 0644)
+	}
+
+	if cc.ManageEtcHosts != "" {
+		warnings = append(warnings, "manage_etc_hosts: not supported by Ignition; write /etc/hosts with write_files instead")
+	}
+
+	return ign, warnings, nil
+}
+
+// environmentDropIn renders a [Service] drop-in setting one Environment
+// line per key, named the way coreos-cloudinit names them.
+func environmentDropIn(prefix string, names map[string]string, env map[string]interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteString("[Service]\n")
+	for _, k := range sortedEnvKeys(env) {
+		key := strings.Replace(k, "-", "_", -1)
+		name, ok := names[key]
+		if !ok {
+			name = prefix + strings.ToUpper(key)
+		}
+		value := strings.Replace(fmt.Sprint(env[k]), `"`, `\"`, -1)
+		fmt.Fprintf(&buf, "Environment=\"%s=%s\"\n", name, value)
+	}
+	return buf.String()
+}
+
+func sortedEnvKeys(env map[string]interface{}) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// substitutionWarnings reports the coreos-cloudinit address variables in
+// s, which Ignition leaves as they are.
+func substitutionWarnings(where, s string) []string {
+	var warnings []string
+	for _, v := range cloudinitSubstitutions {
+		if strings.Contains(s, v) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s is not substituted by Ignition; use the COREOS_* variables coreos-metadata writes instead", where, v))
+		}
+	}
+	return warnings
+}
+
+func convertUpdate(ign *ignitionConfig, update map[string]interface{}) []string {
+	var warnings []string
+	var buf bytes.Buffer
+
+	keys := make([]string, 0, len(update))
+	for k := range update {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := fmt.Sprint(update[k])
+		switch k {
+		case "reboot-strategy":
+			fmt.Fprintf(&buf, "REBOOT_STRATEGY=%s\n", v)
+			if v == "off" {
+				ign.unit("locksmithd.service").Mask = true
+			}
+		case "group":
+			fmt.Fprintf(&buf, "GROUP=%s\n", v)
+		case "server":
+			fmt.Fprintf(&buf, "SERVER=%s\n", v)
+		default:
+			warnings = append(warnings, fmt.Sprintf("coreos.update.%s: unsupported key ignored", k))
+		}
+	}
+
+	if buf.Len() > 0 {
+		ign.addFile("/etc/coreos/update.conf", buf.String(), 0644)
+	}
+	return warnings
+}
+
+func convertUnit(ign *ignitionConfig, cu cloudConfigUnit) []string {
+	var warnings []string
+
+	warnings = append(warnings, substitutionWarnings("coreos.units."+cu.Name, cu.Content)...)
+	for _, d := range cu.DropIns {
+		warnings = append(warnings, substitutionWarnings("coreos.units."+cu.Name+"."+d.Name, d.Content)...)
+	}
+
+	u := ign.unit(cu.Name)
+	if cu.Content != "" {
+		u.Contents = cu.Content
+	}
+	u.Enable = u.Enable || cu.Enable
+	u.Mask = u.Mask || cu.Mask
+	for _, d := range cu.DropIns {
+		u.Dropins = append(u.Dropins, ignitionDropin{Name: d.Name, Contents: d.Content})
+	}
+
+	if cu.Runtime {
+		warnings = append(warnings, fmt.Sprintf("coreos.units.%s: runtime units are written to /etc by Ignition", cu.Name))
+	}
+
+	switch cu.Command {
+	case "":
+	case "start", "restart", "reload-or-restart", "try-restart":
+		u.Enable = true
+		warnings = append(warnings, fmt.Sprintf("coreos.units.%s: command %q approximated by enabling the unit", cu.Name, cu.Command))
+	default:
+		warnings = append(warnings, fmt.Sprintf("coreos.units.%s: command %q has no Ignition equivalent", cu.Name, cu.Command))
+	}
+
+	return warnings
+}
+
+func convertFile(ign *ignitionConfig, f cloudConfigFile) ([]string, error) {
+	contents, err := decodeFileContent(f.Encoding, f.Content)
+	if err != nil {
+		return nil, fmt.Errorf("write_files %s: %s", f.Path, err)
+	}
+
+	mode := 0644
+	if f.RawFilePermissions != "" {
+		m, err := strconv.ParseInt(f.RawFilePermissions, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("write_files %s: invalid permissions %q", f.Path, f.RawFilePermissions)
+		}
+		mode = int(m)
+	}
+
+	file := ign.addFile(f.Path, contents, mode)
+	if f.Owner != "" {
+		parts := strings.SplitN(f.Owner, ":", 2)
+		file.User = ignitionNodeFor(parts[0])
+		if len(parts) == 2 {
+			file.Group = ignitionNodeFor(parts[1])
+		}
+	}
+	return substitutionWarnings("write_files "+f.Path, contents), nil
+}
+
+// decodeFileContent undoes the write_files encodings understood by
+// coreos-cloudinit.
+func decodeFileContent(encoding, content string) (string, error) {
+	switch encoding {
+	case "":
+		return content, nil
+	case "b64", "base64":
+		buf, err := base64.StdEncoding.DecodeString(content)
+		return string(buf), err
+	case "gz", "gzip":
+		return gunzip([]byte(content))
+	case "gz+base64", "gzip+base64", "gz+b64", "gzip+b64":
+		buf, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return "", err
+		}
+		return gunzip(buf)
+	default:
+		return "", fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+func gunzip(buf []byte) (string, error) {
+	r, err := gzip.NewReader(bytes.NewReader(buf))
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	return string(out), err
+}
+
+func ignitionNodeFor(s string) *ignitionNode {
+	if id, err := strconv.Atoi(s); err == nil {
+		return &ignitionNode{ID: &id}
+	}
+	return &ignitionNode{Name: s}
+}
+
+func convertUser(ign *ignitionConfig, cu cloudConfigUser) []string {
+	var warnings []string
+
+	u := ign.user(cu.Name)
+	u.PasswordHash = cu.PasswordHash
+	u.SSHAuthorizedKeys = append(u.SSHAuthorizedKeys, cu.SSHAuthorizedKeys...)
+	u.Gecos = cu.GECOS
+	u.HomeDir = cu.Homedir
+	u.NoCreateHome = cu.NoCreateHome
+	u.PrimaryGroup = cu.PrimaryGroup
+	u.Groups = cu.Groups
+	u.NoUserGroup = cu.NoUserGroup
+	u.System = cu.System
+	u.NoLogInit = cu.NoLogInit
+	u.Shell = cu.Shell
+
+	if cu.SSHImportGithubUser != "" || len(cu.SSHImportGithubUsers) > 0 || cu.SSHImportURL != "" {
+		warnings = append(warnings, fmt.Sprintf("users.%s: ssh key imports are not supported by Ignition; list the keys in ssh_authorized_keys", cu.Name))
+	}
+	return warnings
+}
@@ -0,0 +1,130 @@
+package coreos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConvertCloudConfig(t *testing.T) {
+	cc := `#cloud-config
+hostname: node1
+ssh_authorized_keys:
+  - ssh-rsa AAAA core@example
+coreos:
+  etcd2:
+    name: node1
+    advertise-client-urls: http://$private_ipv4:2379
+  locksmith:
+    group: reboot
+    window_start: Thu 04:00
+    window-length: 1h
+  update:
+    reboot-strategy: "off"
+    group: beta
+  units:
+    - name: etcd2.service
+      command: start
+    - name: app.service
+      enable: true
+      content: |
+        [Service]
+        ExecStart=/usr/bin/sleep infinity
+      drop_ins:
+        - name: 10-env.conf
+          content: |
+            [Service]
+            Environment=FOO=bar
+        - name: 20-addr.conf
+          content: |
+            [Service]
+            Environment=ADDR=$public_ipv4
+write_files:
+  - path: /etc/motd
+    permissions: "0600"
+    owner: root:500
+    encoding: b64
+    content: aGVsbG8K
+users:
+  - name: ops
+    groups: [sudo, docker]
+    coreos_ssh_import_github: ops
+manage_etc_hosts: localhost
+bogus: true
+`
+
+	ign, warnings, err := convertCloudConfig(cc)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	wantWarnings := []string{
+		"bogus: unsupported key ignored",
+		"coreos.etcd2.advertise-client-urls: $private_ipv4 is not substituted by Ignition; use the COREOS_* variables coreos-metadata writes instead",
+		`coreos.units.etcd2.service: command "start" approximated by enabling the unit`,
+		"coreos.units.app.service.20-addr.conf: $public_ipv4 is not substituted by Ignition; use the COREOS_* variables coreos-metadata writes instead",
+		"users.ops: ssh key imports are not supported by Ignition; list the keys in ssh_authorized_keys",
+		"manage_etc_hosts: not supported by Ignition; write /etc/hosts with write_files instead",
+	}
+	if !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Fatalf("warnings:\n%q\nwant:\n%q", warnings, wantWarnings)
+	}
+
+	etcd := ign.unit("etcd2.service")
+	if !etcd.Enable || len(etcd.Dropins) != 1 {
+		t.Fatalf("etcd2.service: %#v", etcd)
+	}
+	wantEnv := "[Service]\n" +
+		"Environment=\"ETCD_ADVERTISE_CLIENT_URLS=http://$private_ipv4:2379\"\n" +
+		"Environment=\"ETCD_NAME=node1\"\n"
+	if etcd.Dropins[0].Contents != wantEnv {
+		t.Fatalf("etcd2 drop-in:\n%s\nwant:\n%s", etcd.Dropins[0].Contents, wantEnv)
+	}
+
+	locksmithd := ign.unit("locksmithd.service")
+	wantEnv = "[Service]\n" +
+		"Environment=\"LOCKSMITHD_GROUP=reboot\"\n" +
+		"Environment=\"LOCKSMITHD_REBOOT_WINDOW_LENGTH=1h\"\n" +
+		"Environment=\"LOCKSMITHD_REBOOT_WINDOW_START=Thu 04:00\"\n"
+	if len(locksmithd.Dropins) != 1 || locksmithd.Dropins[0].Contents != wantEnv {
+		t.Fatalf("locksmithd drop-in: %#v\nwant:\n%s", locksmithd.Dropins, wantEnv)
+	}
+	if !locksmithd.Mask {
+		t.Fatal("locksmithd.service should be masked with reboot-strategy off")
+	}
+
+	app := ign.unit("app.service")
+	if !app.Enable || app.Contents == "" || len(app.Dropins) != 2 || app.Dropins[0].Name != "10-env.conf" {
+		t.Fatalf("app.service: %#v", app)
+	}
+
+	files := map[string]ignitionFile{}
+	for _, f := range ign.Storage.Files {
+		files[f.Path] = f
+	}
+	if f := files["/etc/coreos/update.conf"]; f.Contents.Source != dataURL("GROUP=beta\nREBOOT_STRATEGY=off\n") {
+		t.Fatalf("update.conf: %#v", f)
+	}
+	if f := files["/etc/hostname"]; f.Contents.Source != dataURL("node1\n") {
+		t.Fatalf("hostname: %#v", f)
+	}
+	motd := files["/etc/motd"]
+	if motd.Contents.Source != dataURL("hello\n") || motd.Mode != 0600 {
+		t.Fatalf("motd: %#v", motd)
+	}
+	if motd.User.Name != "root" || motd.Group.ID == nil || *motd.Group.ID != 500 {
+		t.Fatalf("motd owner: %#v %#v", motd.User, motd.Group)
+	}
+
+	if keys := ign.user("core").SSHAuthorizedKeys; len(keys) != 1 {
+		t.Fatalf("core keys: %#v", keys)
+	}
+	if groups := ign.user("ops").Groups; !reflect.DeepEqual(groups, []string{"sudo", "docker"}) {
+		t.Fatalf("ops groups: %#v", groups)
+	}
+}
+
+func TestConvertCloudConfigRejectsScripts(t *testing.T) {
+	if _, _, err := convertCloudConfig("#!/bin/bash\necho hi\n"); err == nil {
+		t.Fatal("expected error for a script")
+	}
+}
@@ -0,0 +1,213 @@
+package coreos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// coreUpdateAppID is Container Linux's application ID in CoreUpdate, the
+// Omaha app ID without braces.
+var coreUpdateAppID = strings.Trim(omahaAppID, "{}")
+
+type (
+	coreUpdateGroup struct {
+		ID                        string `json:"id,omitempty"`
+		Name                      string `json:"name"`
+		Description               string `json:"description"`
+		ApplicationID             string `json:"application_id"`
+		ChannelID                 string `json:"channel_id,omitempty"`
+		Track                     string `json:"track"`
+		PolicyUpdatesEnabled      bool   `json:"policy_updates_enabled"`
+		PolicySafeMode            bool   `json:"policy_safe_mode"`
+		PolicyOfficeHours         bool   `json:"policy_office_hours"`
+		PolicyTimezone            string `json:"policy_timezone"`
+		PolicyPeriodInterval      string `json:"policy_period_interval"`
+		PolicyMaxUpdatesPerPeriod int    `json:"policy_max_updates_per_period"`
+		PolicyUpdateTimeout       string `json:"policy_update_timeout"`
+	}
+
+	coreUpdateChannel struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+)
+
+// coreUpdateClient talks to the groups API of a CoreUpdate server, or of
+// Nebraska, which kept CoreUpdate's API.
+type coreUpdateClient struct {
+	base      string
+	user, key string
+	client    *http.Client
+}
+
+func newCoreUpdateClient(server, user, key string) (*coreUpdateClient, error) {
+	u, err := url.Parse(server)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("CoreUpdate server %q must be an http(s) URL", server)
+	}
+	return &coreUpdateClient{
+		base:   strings.TrimSuffix(server, "/") + "/api",
+		user:   user,
+		key:    key,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// do sends in as JSON and decodes the reply into out. It returns
+// found=false on a 404.
+func (c *coreUpdateClient) do(method, resource string, in, out interface{}) (bool, error) {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return false, err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, c.base+resource, body)
+	if err != nil {
+		return false, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.user != "" || c.key != "" {
+		req.SetBasicAuth(c.user, c.key)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 400 {
+		msg := strings.TrimSpace(string(buf))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return false, fmt.Errorf("CoreUpdate: %s %s: %s", method, resource, msg)
+	}
+	if out != nil && len(bytes.TrimSpace(buf)) > 0 {
+		return true, json.Unmarshal(buf, out)
+	}
+	return true, nil
+}
+
+func coreUpdateGroupPath(appID, groupID string) string {
+	p := "/apps/" + url.PathEscape(appID) + "/groups"
+	if groupID != "" {
+		p += "/" + url.PathEscape(groupID)
+	}
+	return p
+}
+
+// group returns nil when the group doesn't exist.
+func (c *coreUpdateClient) group(appID, id string) (*coreUpdateGroup, error) {
+	var g coreUpdateGroup
+	found, err := c.do("GET", coreUpdateGroupPath(appID, id), nil, &g)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (c *coreUpdateClient) createGroup(g *coreUpdateGroup) (*coreUpdateGroup, error) {
+	var out coreUpdateGroup
+	if _, err := c.do("POST", coreUpdateGroupPath(g.ApplicationID, ""), g, &out); err != nil {
+		return nil, err
+	}
+	if out.ID == "" {
+		return nil, fmt.Errorf("CoreUpdate did not return the new group's ID")
+	}
+	return &out, nil
+}
+
+func (c *coreUpdateClient) updateGroup(g *coreUpdateGroup) (*coreUpdateGroup, error) {
+	var out coreUpdateGroup
+	found, err := c.do("PUT", coreUpdateGroupPath(g.ApplicationID, g.ID), g, &out)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("CoreUpdate group %s no longer exists", g.ID)
+	}
+	return &out, nil
+}
+
+func (c *coreUpdateClient) deleteGroup(appID, id string) error {
+	_, err := c.do("DELETE", coreUpdateGroupPath(appID, id), nil, nil)
+	return err
+}
+
+// channels lists an application's channels. Newer servers page the list,
+// older ones return a bare array.
+func (c *coreUpdateClient) channels(appID string) ([]coreUpdateChannel, error) {
+	var raw json.RawMessage
+	if _, err := c.do("GET", "/apps/"+url.PathEscape(appID)+"/channels", nil, &raw); err != nil {
+		return nil, err
+	}
+	var channels []coreUpdateChannel
+	if err := json.Unmarshal(raw, &channels); err != nil {
+		var page struct {
+			Channels []coreUpdateChannel `json:"channels"`
+		}
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return nil, fmt.Errorf("CoreUpdate: listing channels: %s", err)
+		}
+		channels = page.Channels
+	}
+	return channels, nil
+}
+
+// channelID resolves a channel name, which is what the UI shows, to the
+// ID groups refer to it by.
+func (c *coreUpdateClient) channelID(appID, name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	channels, err := c.channels(appID)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, ch := range channels {
+		if ch.Name == name {
+			return ch.ID, nil
+		}
+		names = append(names, ch.Name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown CoreUpdate channel %q, must be one of: %s", name, strings.Join(names, ", "))
+}
+
+// channelName is the reverse of channelID, for reads.
+func (c *coreUpdateClient) channelName(appID, id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	channels, err := c.channels(appID)
+	if err != nil {
+		return "", err
+	}
+	for _, ch := range channels {
+		if ch.ID == id {
+			return ch.Name, nil
+		}
+	}
+	return "", fmt.Errorf("CoreUpdate group refers to unknown channel %s", id)
+}
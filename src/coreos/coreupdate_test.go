@@ -0,0 +1,140 @@
+package coreos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCoreUpdate serves the groups and channels of one application.
+type fakeCoreUpdate struct {
+	mu     sync.Mutex
+	next   int
+	groups map[string]*coreUpdateGroup
+}
+
+func (f *fakeCoreUpdate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if user, key, ok := r.BasicAuth(); !ok || user != "ops" || key != "k3y" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	prefix := "/api/apps/" + coreUpdateAppID
+	switch {
+	case r.URL.Path == prefix+"/channels":
+		fmt.Fprint(w, `{"channels":[{"id":"c-stable","name":"stable"},{"id":"c-beta","name":"beta"}],"totalCount":2}`)
+
+	case r.URL.Path == prefix+"/groups" && r.Method == "POST":
+		var g coreUpdateGroup
+		json.NewDecoder(r.Body).Decode(&g)
+		f.next++
+		g.ID = fmt.Sprintf("g-%d", f.next)
+		if g.Track == "" {
+			g.Track = g.ID
+		}
+		f.groups[g.ID] = &g
+		json.NewEncoder(w).Encode(&g)
+
+	case strings.HasPrefix(r.URL.Path, prefix+"/groups/"):
+		id := strings.TrimPrefix(r.URL.Path, prefix+"/groups/")
+		g, ok := f.groups[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(g)
+		case "PUT":
+			var in coreUpdateGroup
+			json.NewDecoder(r.Body).Decode(&in)
+			in.ID = id
+			f.groups[id] = &in
+			json.NewEncoder(w).Encode(&in)
+		case "DELETE":
+			delete(f.groups, id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestCoreUpdateClient(t *testing.T) {
+	f := &fakeCoreUpdate{groups: make(map[string]*coreUpdateGroup)}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	if _, err := newCoreUpdateClient("coreupdate.example.com", "", ""); err == nil {
+		t.Fatal("expected an error for a server that isn't a URL")
+	}
+
+	anon, err := newCoreUpdateClient(ts.URL, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := anon.channels(coreUpdateAppID); err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Fatalf("expected an auth error, got %v", err)
+	}
+
+	c, err := newCoreUpdateClient(ts.URL+"/", "ops", "k3y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.channelID(coreUpdateAppID, "alpha"); err == nil || !strings.Contains(err.Error(), "beta, stable") {
+		t.Fatalf("expected an unknown channel error, got %v", err)
+	}
+	channelID, err := c.channelID(coreUpdateAppID, "beta")
+	if err != nil || channelID != "c-beta" {
+		t.Fatalf("channel: %q, %v", channelID, err)
+	}
+
+	g, err := c.createGroup(&coreUpdateGroup{
+		Name:                      "canary",
+		ApplicationID:             coreUpdateAppID,
+		ChannelID:                 channelID,
+		PolicyUpdatesEnabled:      true,
+		PolicyMaxUpdatesPerPeriod: 2,
+	})
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if g.ID == "" || g.Track != g.ID {
+		t.Fatalf("created: %+v", g)
+	}
+
+	// an edit made in the UI is what the next read returns
+	f.mu.Lock()
+	f.groups[g.ID].PolicySafeMode = true
+	f.mu.Unlock()
+	got, err := c.group(coreUpdateAppID, g.ID)
+	if err != nil || got == nil || !got.PolicySafeMode {
+		t.Fatalf("read: %+v, %v", got, err)
+	}
+	if name, err := c.channelName(coreUpdateAppID, got.ChannelID); err != nil || name != "beta" {
+		t.Fatalf("channel name: %q, %v", name, err)
+	}
+
+	got.PolicySafeMode = false
+	got.Track = "canary"
+	if got, err = c.updateGroup(got); err != nil || got.Track != "canary" {
+		t.Fatalf("update: %+v, %v", got, err)
+	}
+
+	if err := c.deleteGroup(coreUpdateAppID, g.ID); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if got, err := c.group(coreUpdateAppID, g.ID); err != nil || got != nil {
+		t.Fatalf("deleted group: %+v, %v", got, err)
+	}
+	if _, err := c.updateGroup(g); err == nil {
+		t.Fatal("expected updating a deleted group to fail")
+	}
+}
@@ -0,0 +1,142 @@
+package coreos
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+)
+
+// cryptAlphabet is the base64 alphabet of crypt(3) hashes.
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// sha512CryptRounds is the glibc default, which leaves rounds= out of the
+// hash.
+const sha512CryptRounds = 5000
+
+// sha512CryptOrder is the order the final digest's bytes are encoded in,
+// three at a time.
+var sha512CryptOrder = [...][3]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41},
+}
+
+// newCryptSalt returns a random 16 character salt, the most SHA-512 crypt
+// uses.
+func newCryptSalt() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = cryptAlphabet[int(b[i])%len(cryptAlphabet)]
+	}
+	return string(b), nil
+}
+
+// sha512Crypt hashes password the way glibc's crypt(3) does for $6$
+// hashes, as described in https://www.akkadia.org/drepper/SHA-crypt.txt.
+func sha512Crypt(password, salt string, rounds int) string {
+	if len(salt) > 16 {
+		salt = salt[:16]
+	}
+	switch {
+	case rounds < 1000:
+		rounds = 1000
+	case rounds > 999999999:
+		rounds = 999999999
+	}
+	p, s := []byte(password), []byte(salt)
+
+	alt := sha512.New()
+	alt.Write(p)
+	alt.Write(s)
+	alt.Write(p)
+	b := alt.Sum(nil)
+
+	h := sha512.New()
+	h.Write(p)
+	h.Write(s)
+	for n := len(p); n > 0; n -= 64 {
+		if n > 64 {
+			h.Write(b)
+		} else {
+			h.Write(b[:n])
+		}
+	}
+	for n := len(p); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(p)
+		}
+	}
+	a := h.Sum(nil)
+
+	h.Reset()
+	for range p {
+		h.Write(p)
+	}
+	pSeq := cryptSequence(h.Sum(nil), len(p))
+
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(s)
+	}
+	sSeq := cryptSequence(h.Sum(nil), len(s))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(pSeq)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(sSeq)
+		}
+		if i%7 != 0 {
+			h.Write(pSeq)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(pSeq)
+		}
+		c = h.Sum(nil)
+	}
+
+	out := []byte("$6$")
+	if rounds != sha512CryptRounds {
+		out = append(out, fmt.Sprintf("rounds=%d$", rounds)...)
+	}
+	out = append(out, salt...)
+	out = append(out, '$')
+	for _, o := range sha512CryptOrder {
+		out = cryptEncode(out, uint(c[o[0]])<<16|uint(c[o[1]])<<8|uint(c[o[2]]), 4)
+	}
+	out = cryptEncode(out, uint(c[63]), 2)
+	return string(out)
+}
+
+// cryptSequence repeats digest until it is n bytes long.
+func cryptSequence(digest []byte, n int) []byte {
+	seq := make([]byte, 0, n)
+	for len(seq) < n {
+		seq = append(seq, digest...)
+	}
+	return seq[:n]
+}
+
+// cryptEncode appends the n lowest 6 bit groups of w to out, least
+// significant first.
+func cryptEncode(out []byte, w uint, n int) []byte {
+	for ; n > 0; n-- {
+		out = append(out, cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+	return out
+}
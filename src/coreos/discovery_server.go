@@ -0,0 +1,336 @@
+package coreos
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DiscoveryServer serves the etcd v2 discovery protocol: /new hands out
+// tokens, and each token is a directory in a small v2 keys API that etcd
+// members register themselves in. Deletes are not reported to watchers,
+// which discovery clients never rely on.
+type DiscoveryServer struct {
+	file    string
+	baseURL string
+
+	mu      sync.Mutex
+	data    discoveryData
+	changed chan struct{}
+}
+
+type discoveryData struct {
+	Index uint64               `json:"index"`
+	Nodes map[string]*etcdNode `json:"nodes"`
+}
+
+// NewDiscoveryServer loads the server's state from file, which is created
+// on the first write. Token URLs are built from baseURL, or from the
+// request's host when it is empty.
+func NewDiscoveryServer(file, baseURL string) (*DiscoveryServer, error) {
+	s := &DiscoveryServer{
+		file:    file,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		data:    discoveryData{Nodes: make(map[string]*etcdNode)},
+		changed: make(chan struct{}),
+	}
+	if file == "" {
+		return s, nil
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &s.data); err != nil {
+		return nil, fmt.Errorf("loading %s: %s", file, err)
+	}
+	if s.data.Nodes == nil {
+		s.data.Nodes = make(map[string]*etcdNode)
+	}
+	return s, nil
+}
+
+func (s *DiscoveryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/new" {
+		s.serveNew(w, r)
+		return
+	}
+
+	key := path.Clean("/" + r.URL.Path)
+	var (
+		resp *etcdResponse
+		err  error
+	)
+	switch r.Method {
+	case "GET":
+		if r.FormValue("wait") == "true" {
+			resp, err = s.watch(r, key)
+		} else {
+			resp, err = s.get(key, r.FormValue("recursive") == "true")
+		}
+	case "PUT":
+		resp, err = s.set(key, r.FormValue("value"), r.FormValue("dir") == "true", r.FormValue("prevExist"))
+	case "DELETE":
+		resp, err = s.delete(key, r.FormValue("recursive") == "true", r.FormValue("dir") == "true")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if resp == nil && err == nil {
+		// the client went away while watching
+		return
+	}
+
+	s.mu.Lock()
+	index := s.data.Index
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Etcd-Index", strconv.FormatUint(index, 10))
+
+	if e, ok := err.(*etcdError); ok {
+		w.WriteHeader(e.status())
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// like etcd, watches answer 200 even when they saw a create
+	if resp.Action == "create" && r.Method == "PUT" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *DiscoveryServer) serveNew(w http.ResponseWriter, r *http.Request) {
+	size := 3
+	if v := r.FormValue("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "size must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.data.Index++
+	for _, k := range []string{"/" + token, "/" + token + "/_config"} {
+		s.data.Nodes[k] = &etcdNode{Key: k, Dir: true, CreatedIndex: s.data.Index, ModifiedIndex: s.data.Index}
+	}
+	k := "/" + token + "/_config/size"
+	s.data.Nodes[k] = &etcdNode{Key: k, Value: strconv.Itoa(size), CreatedIndex: s.data.Index, ModifiedIndex: s.data.Index}
+	err := s.commit()
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := s.baseURL
+	if base == "" {
+		base = "http://" + r.Host
+	}
+	fmt.Fprintf(w, "%s/%s", base, token)
+}
+
+// commit saves the state and wakes up watchers. It is called with mu held.
+func (s *DiscoveryServer) commit() error {
+	close(s.changed)
+	s.changed = make(chan struct{})
+	if s.file == "" {
+		return nil
+	}
+
+	buf, err := json.Marshal(&s.data)
+	if err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// node returns a copy of the node at key with its children filled in,
+// recursively if asked to.
+func (s *DiscoveryServer) node(key string, recursive, children bool) *etcdNode {
+	n := *s.data.Nodes[key]
+	if !n.Dir || !children {
+		return &n
+	}
+	var keys []string
+	for k := range s.data.Nodes {
+		if path.Dir(k) == key && k != key {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		n.Nodes = append(n.Nodes, s.node(k, recursive, recursive))
+	}
+	return &n
+}
+
+func (s *DiscoveryServer) get(key string, recursive bool) (*etcdResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.Nodes[key]; !ok {
+		return nil, newEtcdError(etcdErrKeyNotFound, key, s.data.Index)
+	}
+	return &etcdResponse{Action: "get", Node: s.node(key, recursive, true)}, nil
+}
+
+// watch waits for key, or anything under it when recursive, to be
+// modified at or after waitIndex.
+func (s *DiscoveryServer) watch(r *http.Request, key string) (*etcdResponse, error) {
+	var waitIndex uint64
+	if v := r.FormValue("waitIndex"); v != "" {
+		var err error
+		if waitIndex, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid waitIndex %q", v)
+		}
+	}
+	recursive := r.FormValue("recursive") == "true"
+
+	for {
+		s.mu.Lock()
+		if waitIndex == 0 {
+			waitIndex = s.data.Index + 1
+		}
+		var found *etcdNode
+		for k, n := range s.data.Nodes {
+			if k != key && !(recursive && strings.HasPrefix(k, key+"/")) {
+				continue
+			}
+			if n.ModifiedIndex >= waitIndex && (found == nil || n.ModifiedIndex < found.ModifiedIndex) {
+				found = n
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if found != nil {
+			action := "set"
+			if found.CreatedIndex == found.ModifiedIndex {
+				action = "create"
+			}
+			n := *found
+			return &etcdResponse{Action: action, Node: &n}, nil
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return nil, nil
+		}
+	}
+}
+
+func (s *DiscoveryServer) set(key, value string, dir bool, prevExist string) (*etcdResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key == "/" {
+		return nil, newEtcdError(etcdErrRootReadOnly, key, s.data.Index)
+	}
+	// only tokens handed out by /new can be written to
+	token := "/" + strings.SplitN(key[1:], "/", 2)[0]
+	if _, ok := s.data.Nodes[token]; !ok || token == key {
+		return nil, newEtcdError(etcdErrKeyNotFound, token, s.data.Index)
+	}
+
+	prev, exists := s.data.Nodes[key]
+	switch {
+	case prevExist == "false" && exists:
+		return nil, newEtcdError(etcdErrNodeExist, key, s.data.Index)
+	case prevExist == "true" && !exists:
+		return nil, newEtcdError(etcdErrKeyNotFound, key, s.data.Index)
+	case exists && prev.Dir:
+		return nil, newEtcdError(etcdErrNotFile, key, s.data.Index)
+	}
+	for p := path.Dir(key); p != token; p = path.Dir(p) {
+		if n, ok := s.data.Nodes[p]; ok && !n.Dir {
+			return nil, newEtcdError(etcdErrNotDir, p, s.data.Index)
+		}
+	}
+
+	s.data.Index++
+	index := s.data.Index
+	for p := path.Dir(key); p != token; p = path.Dir(p) {
+		if _, ok := s.data.Nodes[p]; !ok {
+			s.data.Nodes[p] = &etcdNode{Key: p, Dir: true, CreatedIndex: index, ModifiedIndex: index}
+		}
+	}
+	n := &etcdNode{Key: key, Value: value, Dir: dir, CreatedIndex: index, ModifiedIndex: index}
+	resp := &etcdResponse{Action: "create", Node: n}
+	if exists {
+		n.CreatedIndex = prev.CreatedIndex
+		resp.Action = "set"
+		resp.PrevNode = prev
+	}
+	s.data.Nodes[key] = n
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	c := *n
+	resp.Node = &c
+	return resp, nil
+}
+
+func (s *DiscoveryServer) delete(key string, recursive, dir bool) (*etcdResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key == "/" {
+		return nil, newEtcdError(etcdErrRootReadOnly, key, s.data.Index)
+	}
+	prev, ok := s.data.Nodes[key]
+	if !ok {
+		return nil, newEtcdError(etcdErrKeyNotFound, key, s.data.Index)
+	}
+	if prev.Dir && !recursive {
+		if !dir {
+			return nil, newEtcdError(etcdErrNotFile, key, s.data.Index)
+		}
+		for k := range s.data.Nodes {
+			if strings.HasPrefix(k, key+"/") {
+				return nil, newEtcdError(etcdErrDirNotEmpty, key, s.data.Index)
+			}
+		}
+	}
+
+	s.data.Index++
+	for k := range s.data.Nodes {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(s.data.Nodes, k)
+		}
+	}
+	if err := s.commit(); err != nil {
+		return nil, err
+	}
+	n := &etcdNode{Key: key, Dir: prev.Dir, CreatedIndex: prev.CreatedIndex, ModifiedIndex: s.data.Index}
+	return &etcdResponse{Action: "delete", Node: n, PrevNode: prev}, nil
+}
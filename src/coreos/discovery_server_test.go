@@ -0,0 +1,148 @@
+package coreos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func discoveryRequest(t *testing.T, method, u string, form url.Values) (int, *etcdResponse) {
+	code, r, err := doDiscoveryRequest(context.Background(), method, u, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, r
+}
+
+// doDiscoveryRequest is discoveryRequest for goroutines other than the
+// test's, which must not call t.Fatal.
+func doDiscoveryRequest(ctx context.Context, method, u string, form url.Values) (int, *etcdResponse, error) {
+	req, err := http.NewRequest(method, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Etcd-Index") == "" {
+		return 0, nil, fmt.Errorf("%s %s: no X-Etcd-Index header", method, u)
+	}
+	var r etcdResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, &r, nil
+}
+
+func TestDiscoveryServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "discovery.json")
+
+	s, err := NewDiscoveryServer(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	token, err := newDiscoveryToken(ts.URL, 2)
+	if err != nil {
+		t.Fatalf("new: %s", err)
+	}
+	if !strings.HasPrefix(token, ts.URL+"/") {
+		t.Fatalf("got token %q", token)
+	}
+
+	code, r := discoveryRequest(t, "GET", token+"/_config/size", nil)
+	if code != http.StatusOK || r.Node.Value != "2" {
+		t.Fatalf("size: %d %+v", code, r.Node)
+	}
+
+	// a watch for anything after the token's creation sees the first
+	// registration
+	watch := fmt.Sprintf("%s?wait=true&recursive=true&waitIndex=%d", token, r.Node.ModifiedIndex+1)
+	type watchResult struct {
+		code int
+		resp *etcdResponse
+		err  error
+	}
+	// cancelled before ts closes, which would otherwise wait for the watch
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watched := make(chan watchResult, 1)
+	go func() {
+		code, r, err := doDiscoveryRequest(ctx, "GET", watch, nil)
+		watched <- watchResult{code, r, err}
+	}()
+
+	register := url.Values{"value": {"infra0=http://10.0.0.1:2380"}, "prevExist": {"false"}}
+	code, r = discoveryRequest(t, "PUT", token+"/abc1", register)
+	if code != http.StatusCreated || r.Action != "create" {
+		t.Fatalf("register: %d %+v", code, r)
+	}
+	var w watchResult
+	select {
+	case w = <-watched:
+	case <-time.After(10 * time.Second):
+		t.Fatal("watch: no response")
+	}
+	if w.err != nil {
+		t.Fatalf("watch: %s", w.err)
+	}
+	if w.code != http.StatusOK || w.resp.Node.Key != r.Node.Key || w.resp.Action != "create" {
+		t.Fatalf("watch: %d %+v", w.code, w.resp.Node)
+	}
+
+	code, _ = discoveryRequest(t, "PUT", token+"/abc1", register)
+	if code != http.StatusPreconditionFailed {
+		t.Fatalf("duplicate registration: %d", code)
+	}
+	code, _ = discoveryRequest(t, "PUT", ts.URL+"/unknown/abc1", register)
+	if code != http.StatusNotFound {
+		t.Fatalf("unknown token: %d", code)
+	}
+
+	// state survives a restart
+	s, err = NewDiscoveryServer(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2 := httptest.NewServer(s)
+	defer ts2.Close()
+	token2 := ts2.URL + strings.TrimPrefix(token, ts.URL)
+
+	code, r = discoveryRequest(t, "GET", token2, nil)
+	if code != http.StatusOK || len(r.Node.Nodes) != 2 {
+		t.Fatalf("list: %d %+v", code, r.Node)
+	}
+	if n := r.Node.Nodes[1]; !strings.HasSuffix(n.Key, "/abc1") || n.Value != "infra0=http://10.0.0.1:2380" {
+		t.Fatalf("member: %+v", n)
+	}
+	if r.Node.Nodes[0].Nodes != nil {
+		t.Fatalf("non-recursive get returned grandchildren")
+	}
+
+	if err := deleteDiscoveryToken(token2); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if ok, err := discoveryTokenExists(token2); ok || err != nil {
+		t.Fatalf("exists after delete: %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,246 @@
+package coreos
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	dockerStorageDrivers = []string{"overlay2", "overlay", "devicemapper", "btrfs", "aufs", "zfs", "vfs"}
+	dockerLogDrivers     = []string{"json-file", "journald", "local", "syslog", "fluentd", "gelf", "awslogs", "splunk", "gcplogs", "logentries", "none"}
+)
+
+// dockerTLSDir is where the daemon's TLS files are written.
+const dockerTLSDir = "/etc/docker/tls"
+
+// dockerOptsDropIn renders a docker.service drop-in passing flags through
+// DOCKER_OPTS, which the stock unit appends to the daemon's command line.
+// systemd expands specifiers in Environment, so % is escaped.
+func dockerOptsDropIn(flags []string) string {
+	return fmt.Sprintf("[Service]\nEnvironment=\"DOCKER_OPTS=%s\"\n", escapeSpecifiers(strings.Join(flags, " ")))
+}
+
+// dockerConfig is the daemon setup of a machine.
+type dockerConfig struct {
+	storageDriver      string
+	logDriver          string
+	logOpts            map[string]string
+	insecureRegistries []string
+	registryMirrors    []string
+	bridgeIP           string
+	tcpPort            int
+	insecureTCP        bool
+	tlsCACert          string
+	tlsCert            string
+	tlsKey             string
+	registryCAs        map[string]string
+	extraFlags         []string
+}
+
+func (c *dockerConfig) validate() error {
+	if c.storageDriver != "" && !oneOf(c.storageDriver, dockerStorageDrivers) {
+		return fmt.Errorf("storage_driver must be one of %s", strings.Join(dockerStorageDrivers, ", "))
+	}
+	if c.logDriver != "" && !oneOf(c.logDriver, dockerLogDrivers) {
+		return fmt.Errorf("log_driver must be one of %s", strings.Join(dockerLogDrivers, ", "))
+	}
+	if len(c.logOpts) > 0 && c.logDriver == "" {
+		return fmt.Errorf("log_opts need a log_driver")
+	}
+	for k, v := range c.logOpts {
+		if err := checkDockerOpt("log_opts "+k, k+"="+v); err != nil {
+			return err
+		}
+	}
+	for _, r := range c.insecureRegistries {
+		if _, _, err := net.ParseCIDR(r); err == nil {
+			continue
+		}
+		if err := checkRegistryHost("insecure_registries", r); err != nil {
+			return err
+		}
+	}
+	for _, m := range c.registryMirrors {
+		if u, err := url.Parse(m); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("registry_mirrors: %q must be an http(s) URL", m)
+		}
+	}
+	if c.bridgeIP != "" {
+		ip, network, err := net.ParseCIDR(c.bridgeIP)
+		if err != nil {
+			return fmt.Errorf("bridge_ip %q must be an address in CIDR notation, e.g. 172.18.0.1/16", c.bridgeIP)
+		}
+		if ip.Equal(network.IP) {
+			return fmt.Errorf("bridge_ip %s is the network address, docker needs the bridge's own address in it", c.bridgeIP)
+		}
+	}
+	if c.tcpPort < 0 || c.tcpPort > 65535 {
+		return fmt.Errorf("tcp_port %d is out of range", c.tcpPort)
+	}
+	tls := 0
+	for _, s := range []string{c.tlsCACert, c.tlsCert, c.tlsKey} {
+		if s != "" {
+			tls++
+		}
+	}
+	switch {
+	case tls != 0 && tls != 3:
+		return fmt.Errorf("tls_ca_cert, tls_cert and tls_key must be set together")
+	case tls == 3 && c.tcpPort == 0:
+		return fmt.Errorf("TLS only protects the remote API, set tcp_port")
+	case tls == 3 && c.insecureTCP:
+		return fmt.Errorf("insecure_tcp can't be set along with TLS")
+	case c.insecureTCP && c.tcpPort == 0:
+		return fmt.Errorf("insecure_tcp needs a tcp_port")
+	case c.tcpPort != 0 && tls == 0 && !c.insecureTCP:
+		// anyone who can reach the port has root on the machine
+		return fmt.Errorf("tcp_port needs tls_ca_cert, tls_cert and tls_key, or insecure_tcp to expose the API without authentication")
+	}
+	for r := range c.registryCAs {
+		if err := checkRegistryHost("registry_ca", r); err != nil {
+			return err
+		}
+	}
+	for _, f := range c.extraFlags {
+		if err := checkDockerOpt("extra_flags", f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDockerOpt rejects values that would break out of the quoted
+// DOCKER_OPTS environment variable.
+func checkDockerOpt(name, v string) error {
+	if strings.ContainsAny(v, " \t\n\"'\\") {
+		return fmt.Errorf("%s: %q can't contain spaces, quotes or backslashes", name, v)
+	}
+	return nil
+}
+
+func checkRegistryHost(name, r string) error {
+	if r == "" || strings.Contains(r, "/") {
+		return fmt.Errorf("%s: %q must be a registry host, optionally with a port", name, r)
+	}
+	return checkDockerOpt(name, r)
+}
+
+func (c *dockerConfig) tlsEnabled() bool {
+	return c.tlsCACert != ""
+}
+
+// flags are the daemon options passed in DOCKER_OPTS.
+func (c *dockerConfig) flags() []string {
+	var flags []string
+	if c.storageDriver != "" {
+		flags = append(flags, "--storage-driver="+c.storageDriver)
+	}
+	if c.logDriver != "" {
+		flags = append(flags, "--log-driver="+c.logDriver)
+	}
+	for _, k := range sortedKeys(c.logOpts) {
+		flags = append(flags, "--log-opt="+k+"="+c.logOpts[k])
+	}
+	for _, r := range c.insecureRegistries {
+		flags = append(flags, "--insecure-registry="+r)
+	}
+	for _, m := range c.registryMirrors {
+		flags = append(flags, "--registry-mirror="+m)
+	}
+	if c.bridgeIP != "" {
+		flags = append(flags, "--bip="+c.bridgeIP)
+	}
+	if c.tlsEnabled() {
+		flags = append(flags,
+			"--tlsverify",
+			"--tlscacert="+path.Join(dockerTLSDir, "ca.pem"),
+			"--tlscert="+path.Join(dockerTLSDir, "cert.pem"),
+			"--tlskey="+path.Join(dockerTLSDir, "key.pem"),
+		)
+	}
+	return append(flags, c.extraFlags...)
+}
+
+// dockerFile is a file the daemon reads.
+type dockerFile struct {
+	path     string
+	contents string
+	mode     int
+}
+
+func (c *dockerConfig) files() []dockerFile {
+	var files []dockerFile
+	if c.tlsEnabled() {
+		files = append(files,
+			dockerFile{path.Join(dockerTLSDir, "ca.pem"), c.tlsCACert, 0644},
+			dockerFile{path.Join(dockerTLSDir, "cert.pem"), c.tlsCert, 0644},
+			dockerFile{path.Join(dockerTLSDir, "key.pem"), c.tlsKey, 0600},
+		)
+	}
+	registries := make([]string, 0, len(c.registryCAs))
+	for r := range c.registryCAs {
+		registries = append(registries, r)
+	}
+	sort.Strings(registries)
+	for _, r := range registries {
+		files = append(files, dockerFile{path.Join("/etc/docker/certs.d", r, "ca.crt"), c.registryCAs[r], 0644})
+	}
+	return files
+}
+
+// tcpSocket is the socket unit exposing the remote API, as the CoreOS
+// docs set it up.
+func (c *dockerConfig) tcpSocket() string {
+	return fmt.Sprintf(`[Unit]
+Description=Docker Socket for the API
+
+[Socket]
+ListenStream=%d
+BindIPv6Only=both
+Service=docker.service
+
+[Install]
+WantedBy=sockets.target
+`, c.tcpPort)
+}
+
+// configs renders the drop-in, files and socket unit as cloud-config and
+// as Ignition.
+func (c *dockerConfig) configs() (string, string) {
+	cc := &cloudConfig{}
+	ign := newIgnitionConfig()
+
+	if flags := c.flags(); len(flags) > 0 {
+		dropIn := dockerOptsDropIn(flags)
+		cc.CoreOS.Units = append(cc.CoreOS.Units, cloudConfigUnit{
+			Name:    "docker.service",
+			DropIns: []cloudConfigDropIn{{Name: "20-docker-opts.conf", Content: dropIn}},
+		})
+		u := ign.unit("docker.service")
+		u.Dropins = append(u.Dropins, ignitionDropin{Name: "20-docker-opts.conf", Contents: dropIn})
+	}
+	if c.tcpPort != 0 {
+		cc.CoreOS.Units = append(cc.CoreOS.Units, cloudConfigUnit{
+			Name:    "docker-tcp.socket",
+			Enable:  true,
+			Command: "start",
+			Content: c.tcpSocket(),
+		})
+		u := ign.unit("docker-tcp.socket")
+		u.Enable = true
+		u.Contents = c.tcpSocket()
+	}
+	for _, f := range c.files() {
+		cc.WriteFiles = append(cc.WriteFiles, cloudConfigFile{
+			Path:               f.path,
+			Content:            f.contents,
+			RawFilePermissions: fmt.Sprintf("0%o", f.mode),
+		})
+		ign.addFile(f.path, f.contents, f.mode)
+	}
+	return cc.String(), ign.String()
+}
@@ -0,0 +1,96 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDockerConfig(t *testing.T) {
+	c := &dockerConfig{
+		storageDriver:      "overlay2",
+		logDriver:          "journald",
+		logOpts:            map[string]string{"tag": "{{.Name}}", "labels": "app"},
+		insecureRegistries: []string{"10.0.1.0/24", "registry.local:5000"},
+		registryMirrors:    []string{"https://mirror.example.com"},
+		bridgeIP:           "172.18.0.1/16",
+		tcpPort:            2376,
+		tlsCACert:          "ca",
+		tlsCert:            "cert",
+		tlsKey:             "key",
+		registryCAs:        map[string]string{"registry.example.com:5000": "registry ca"},
+	}
+	if err := c.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := "--storage-driver=overlay2 --log-driver=journald --log-opt=labels=app --log-opt=tag={{.Name}} " +
+		"--insecure-registry=10.0.1.0/24 --insecure-registry=registry.local:5000 " +
+		"--registry-mirror=https://mirror.example.com --bip=172.18.0.1/16 " +
+		"--tlsverify --tlscacert=/etc/docker/tls/ca.pem --tlscert=/etc/docker/tls/cert.pem --tlskey=/etc/docker/tls/key.pem"
+	if got := strings.Join(c.flags(), " "); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	cc, ign := c.configs()
+	for _, s := range []string{"20-docker-opts.conf", "DOCKER_OPTS=--storage-driver=overlay2", "docker-tcp.socket", "ListenStream=2376", "path: /etc/docker/certs.d/registry.example.com:5000/ca.crt", `permissions: "0600"`} {
+		if !strings.Contains(cc, s) {
+			t.Errorf("cloud-config is missing %q:\n%s", s, cc)
+		}
+	}
+	for _, s := range []string{`"name":"docker-tcp.socket","enable":true`, `"path":"/etc/docker/tls/key.pem"`, `"mode":384`} {
+		if !strings.Contains(ign, s) {
+			t.Errorf("ignition is missing %q:\n%s", s, ign)
+		}
+	}
+
+	cc, ign = (&dockerConfig{}).configs()
+	if strings.Contains(cc, "docker") || strings.Contains(ign, "docker") {
+		t.Errorf("empty config rendered something:\n%s\n%s", cc, ign)
+	}
+}
+
+func TestDockerConfigInsecureTCP(t *testing.T) {
+	c := &dockerConfig{tcpPort: 2375, insecureTCP: true}
+	if err := c.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if cc, _ := c.configs(); !strings.Contains(cc, "ListenStream=2375") {
+		t.Errorf("socket is missing:\n%s", cc)
+	}
+}
+
+func TestDockerConfigValidate(t *testing.T) {
+	cases := []struct {
+		c   dockerConfig
+		err string
+	}{
+		{dockerConfig{storageDriver: "overlay3"}, "storage_driver"},
+		{dockerConfig{logDriver: "stdout"}, "log_driver"},
+		{dockerConfig{logOpts: map[string]string{"tag": "x"}}, "need a log_driver"},
+		{dockerConfig{logDriver: "syslog", logOpts: map[string]string{"tag": "a b"}}, "can't contain spaces"},
+		{dockerConfig{insecureRegistries: []string{"http://registry.local"}}, "registry host"},
+		{dockerConfig{registryMirrors: []string{"mirror.example.com"}}, "http(s) URL"},
+		{dockerConfig{bridgeIP: "172.18.0.0/16"}, "network address"},
+		{dockerConfig{bridgeIP: "172.18.0.1"}, "CIDR notation"},
+		{dockerConfig{tcpPort: 2376, tlsCACert: "ca"}, "set together"},
+		{dockerConfig{tcpPort: 2375}, "or insecure_tcp"},
+		{dockerConfig{insecureTCP: true}, "needs a tcp_port"},
+		{dockerConfig{tcpPort: 2376, insecureTCP: true, tlsCACert: "ca", tlsCert: "cert", tlsKey: "key"}, "along with TLS"},
+		{dockerConfig{tlsCACert: "ca", tlsCert: "cert", tlsKey: "key"}, "set tcp_port"},
+		{dockerConfig{registryCAs: map[string]string{"registry/path": "ca"}}, "registry host"},
+		{dockerConfig{extraFlags: []string{"--label=a b"}}, "can't contain spaces"},
+	}
+	for i, tc := range cases {
+		if err := tc.c.validate(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: expected %q, got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestDockerOptsDropInEscapesSpecifiers(t *testing.T) {
+	got := dockerOptsDropIn([]string{"--registry-mirror=https://mirror.example.com/a%2Fb", "--log-opt=tag=%h"})
+	want := "[Service]\nEnvironment=\"DOCKER_OPTS=--registry-mirror=https://mirror.example.com/a%%2Fb --log-opt=tag=%%h\"\n"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
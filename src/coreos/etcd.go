@@ -0,0 +1,400 @@
+package coreos
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etcdDefaultEndpoint is used when neither the resource nor the provider
+// names an endpoint.
+const etcdDefaultEndpoint = "http://127.0.0.1:2379"
+
+// Wire types of the etcd v2 keys API.
+type (
+	etcdResponse struct {
+		Action   string    `json:"action"`
+		Node     *etcdNode `json:"node,omitempty"`
+		PrevNode *etcdNode `json:"prevNode,omitempty"`
+	}
+
+	etcdNode struct {
+		Key           string      `json:"key"`
+		Value         string      `json:"value,omitempty"`
+		Dir           bool        `json:"dir,omitempty"`
+		Nodes         []*etcdNode `json:"nodes,omitempty"`
+		Expiration    string      `json:"expiration,omitempty"`
+		TTL           int64       `json:"ttl,omitempty"`
+		CreatedIndex  uint64      `json:"createdIndex"`
+		ModifiedIndex uint64      `json:"modifiedIndex"`
+	}
+
+	etcdError struct {
+		ErrorCode int    `json:"errorCode"`
+		Message   string `json:"message"`
+		Cause     string `json:"cause,omitempty"`
+		Index     uint64 `json:"index"`
+	}
+)
+
+// etcd v2 error codes.
+const (
+	etcdErrKeyNotFound  = 100
+	etcdErrTestFailed   = 101
+	etcdErrNotFile      = 102
+	etcdErrNotDir       = 104
+	etcdErrNodeExist    = 105
+	etcdErrRootReadOnly = 107
+	etcdErrDirNotEmpty  = 108
+)
+
+var etcdErrMessages = map[int]string{
+	etcdErrKeyNotFound:  "Key not found",
+	etcdErrTestFailed:   "Compare failed",
+	etcdErrNotFile:      "Not a file",
+	etcdErrNotDir:       "Not a directory",
+	etcdErrNodeExist:    "Key already exists",
+	etcdErrRootReadOnly: "Root is read only",
+	etcdErrDirNotEmpty:  "Directory not empty",
+}
+
+func newEtcdError(code int, cause string, index uint64) *etcdError {
+	return &etcdError{ErrorCode: code, Message: etcdErrMessages[code], Cause: cause, Index: index}
+}
+
+func (e *etcdError) Error() string {
+	return fmt.Sprintf("%d: %s (%s) [%d]", e.ErrorCode, e.Message, e.Cause, e.Index)
+}
+
+// status is the HTTP status etcd answers the error with.
+func (e *etcdError) status() int {
+	switch e.ErrorCode {
+	case etcdErrKeyNotFound:
+		return http.StatusNotFound
+	case etcdErrTestFailed, etcdErrNodeExist:
+		return http.StatusPreconditionFailed
+	}
+	return http.StatusForbidden
+}
+
+// etcdClient talks to the etcd v2 HTTP API, trying each endpoint in turn
+// until one answers.
+type etcdClient struct {
+	endpoints []string
+	client    *http.Client
+}
+
+// newEtcdClient builds a client for endpoints. caCert, clientCert and
+// clientKey are PEM encoded and optional; a client certificate needs its
+// key.
+func newEtcdClient(endpoints []string, caCert, clientCert, clientKey string) (*etcdClient, error) {
+	if len(endpoints) == 0 {
+		endpoints = []string{etcdDefaultEndpoint}
+	}
+	c := &etcdClient{client: &http.Client{Timeout: 30 * time.Second}}
+	for _, e := range endpoints {
+		c.endpoints = append(c.endpoints, strings.TrimSuffix(e, "/"))
+	}
+	if caCert == "" && clientCert == "" && clientKey == "" {
+		return c, nil
+	}
+
+	config := &tls.Config{}
+	if caCert != "" {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, fmt.Errorf("ca_cert contains no PEM certificates")
+		}
+	}
+	if clientCert != "" || clientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	c.client.Transport = &http.Transport{TLSClientConfig: config}
+	return c, nil
+}
+
+// do sends params, in the query string or as a form, to the first
+// endpoint that answers and decodes the JSON reply into out.
+func (c *etcdClient) do(method, resource string, params url.Values, out interface{}) error {
+	if method == "GET" || method == "DELETE" {
+		if len(params) > 0 {
+			resource += "?" + params.Encode()
+		}
+		return c.send(method, resource, "", nil, out)
+	}
+	return c.send(method, resource, "application/x-www-form-urlencoded", []byte(params.Encode()), out)
+}
+
+// doJSON is do for the APIs that take a JSON body.
+func (c *etcdClient) doJSON(method, resource string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.send(method, resource, "application/json", body, out)
+}
+
+// send tries each endpoint until one answers. Replies in the keys API's
+// error format are returned as *etcdError.
+func (c *etcdClient) send(method, resource, contentType string, body []byte, out interface{}) error {
+	var lastErr error
+	for _, e := range c.endpoints {
+		u := e + resource
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, u, r)
+		if err != nil {
+			return err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		defer resp.Body.Close()
+
+		buf, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode >= 400 {
+			var e etcdError
+			if json.Unmarshal(buf, &e) == nil && e.ErrorCode != 0 {
+				return &e
+			}
+			if e.Message != "" {
+				return fmt.Errorf("%s %s: %s: %s", method, u, resp.Status, e.Message)
+			}
+			return fmt.Errorf("%s %s: %s", method, u, resp.Status)
+		}
+		if out == nil || len(bytes.TrimSpace(buf)) == 0 {
+			return nil
+		}
+		return json.Unmarshal(buf, out)
+	}
+	return lastErr
+}
+
+func (c *etcdClient) keys(method, key string, params url.Values) (*etcdResponse, error) {
+	var r etcdResponse
+	if err := c.do(method, "/v2/keys/"+strings.TrimPrefix(key, "/"), params, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (c *etcdClient) get(key string, recursive bool) (*etcdNode, error) {
+	params := url.Values{}
+	if recursive {
+		params.Set("recursive", "true")
+	}
+	r, err := c.keys("GET", key, params)
+	if err != nil {
+		return nil, err
+	}
+	return r.Node, nil
+}
+
+// set writes a value. A non-zero prevIndex makes it a compare-and-swap,
+// and prevExist is "true", "false" or empty to not care.
+func (c *etcdClient) set(key, value string, ttl int, prevIndex uint64, prevExist string) (*etcdNode, error) {
+	params := url.Values{"value": {value}}
+	if ttl > 0 {
+		params.Set("ttl", strconv.Itoa(ttl))
+	}
+	if prevIndex > 0 {
+		params.Set("prevIndex", strconv.FormatUint(prevIndex, 10))
+	}
+	if prevExist != "" {
+		params.Set("prevExist", prevExist)
+	}
+	r, err := c.keys("PUT", key, params)
+	if err != nil {
+		return nil, err
+	}
+	return r.Node, nil
+}
+
+// mkdir creates a directory, or refreshes its TTL when prevExist is "true".
+// A refresh with a zero ttl sends an empty one, which clears the TTL.
+func (c *etcdClient) mkdir(key string, ttl int, prevExist string) (*etcdNode, error) {
+	params := url.Values{"dir": {"true"}}
+	switch {
+	case ttl > 0:
+		params.Set("ttl", strconv.Itoa(ttl))
+	case prevExist == "true":
+		params.Set("ttl", "")
+	}
+	if prevExist != "" {
+		params.Set("prevExist", prevExist)
+	}
+	r, err := c.keys("PUT", key, params)
+	if err != nil {
+		return nil, err
+	}
+	return r.Node, nil
+}
+
+// delete removes a key, or a directory and its contents when recursive.
+// A non-zero prevIndex makes it a compare-and-delete.
+func (c *etcdClient) delete(key string, recursive bool, prevIndex uint64) error {
+	params := url.Values{}
+	if recursive {
+		params.Set("recursive", "true")
+	}
+	if prevIndex > 0 {
+		params.Set("prevIndex", strconv.FormatUint(prevIndex, 10))
+	}
+	_, err := c.keys("DELETE", key, params)
+	return err
+}
+
+// rmdir removes a directory if it is empty.
+func (c *etcdClient) rmdir(key string) error {
+	_, err := c.keys("DELETE", key, url.Values{"dir": {"true"}})
+	return err
+}
+
+func isEtcdError(err error, code int) bool {
+	e, ok := err.(*etcdError)
+	return ok && e.ErrorCode == code
+}
+
+// readDir returns the values under dir keyed by their path relative to
+// it. Subdirectories are flattened into their values.
+func (c *etcdClient) readDir(dir string) (map[string]*etcdNode, error) {
+	n, err := c.get(dir, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.Dir {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	prefix := "/" + strings.Trim(dir, "/") + "/"
+	values := make(map[string]*etcdNode)
+	var walk func(n *etcdNode)
+	walk = func(n *etcdNode) {
+		for _, child := range n.Nodes {
+			if child.Dir {
+				walk(child)
+			} else {
+				values[strings.TrimPrefix(child.Key, prefix)] = child
+			}
+		}
+	}
+	walk(n)
+	return values, nil
+}
+
+// syncDir makes the values under dir match want. known holds the index
+// each managed key was last read at; keys whose value changed since then
+// are not overwritten. Keys not in want are deleted if they were managed
+// before, or if prune is set and they sit directly in dir; keys in
+// subdirectories belong to whoever made them. It returns the index of
+// every key in want.
+func (c *etcdClient) syncDir(dir string, want map[string]string, known map[string]uint64, prune bool) (map[string]uint64, error) {
+	current, err := c.readDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range want {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	indexes := make(map[string]uint64)
+	for _, k := range keys {
+		key := path.Join(dir, k)
+		cur, ok := current[k]
+		var (
+			n   *etcdNode
+			err error
+		)
+		switch {
+		case ok && cur.Value == want[k]:
+			n = cur
+		case ok:
+			prev := known[k]
+			if prev == 0 {
+				prev = cur.ModifiedIndex
+			}
+			n, err = c.set(key, want[k], 0, prev, "")
+		default:
+			n, err = c.set(key, want[k], 0, 0, "false")
+		}
+		if isEtcdError(err, etcdErrTestFailed) || isEtcdError(err, etcdErrNodeExist) {
+			return nil, fmt.Errorf("%s was changed since it was last read, refresh and try again", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		indexes[k] = n.ModifiedIndex
+	}
+
+	for k, cur := range current {
+		if _, ok := want[k]; ok {
+			continue
+		}
+		prev, managed := known[k]
+		if !managed && (!prune || strings.Contains(k, "/")) {
+			continue
+		}
+		if prev == 0 {
+			prev = cur.ModifiedIndex
+		}
+		key := path.Join(dir, k)
+		err := c.delete(key, false, prev)
+		if isEtcdError(err, etcdErrTestFailed) {
+			return nil, fmt.Errorf("%s was changed since it was last read, refresh and try again", key)
+		}
+		if err != nil && !isEtcdError(err, etcdErrKeyNotFound) {
+			return nil, err
+		}
+	}
+	return indexes, nil
+}
+
+// removeDir deletes the managed keys under dir, then dir itself if it
+// was created by its resource or is left empty. Keys others put in an
+// adopted directory stay.
+func (c *etcdClient) removeDir(dir string, managed []string, created bool) error {
+	for _, k := range managed {
+		err := c.delete(path.Join(dir, k), false, 0)
+		if err != nil && !isEtcdError(err, etcdErrKeyNotFound) {
+			return err
+		}
+	}
+	var err error
+	if created {
+		err = c.delete(dir, true, 0)
+	} else {
+		err = c.rmdir(dir)
+	}
+	if err != nil && !isEtcdError(err, etcdErrKeyNotFound) && !isEtcdError(err, etcdErrDirNotEmpty) {
+		return err
+	}
+	return nil
+}
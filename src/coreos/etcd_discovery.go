@@ -0,0 +1,77 @@
+package coreos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// etcdDiscoveryURL is the public etcd discovery service.
+const etcdDiscoveryURL = "https://discovery.etcd.io"
+
+// newDiscoveryToken asks a discovery service for a fresh token for a
+// cluster of the given size and returns its URL.
+func newDiscoveryToken(endpoint string, size int) (string, error) {
+	u := fmt.Sprintf("%s/new?size=%d", strings.TrimSuffix(endpoint, "/"), size)
+	resp, err := http.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(body))
+	if p, err := url.Parse(token); err != nil || p.Scheme == "" || p.Host == "" {
+		return "", fmt.Errorf("%s returned %q, which is not a URL", u, token)
+	}
+	return token, nil
+}
+
+// discoveryTokenExists reports whether the discovery service still knows
+// the token.
+func discoveryTokenExists(token string) (bool, error) {
+	resp, err := http.Get(token)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("fetching %s: %s", token, resp.Status)
+}
+
+// deleteDiscoveryToken removes the token's key space. Services that don't
+// allow deletes, discovery.etcd.io among them, expire tokens on their own
+// so a refusal is not an error.
+func deleteDiscoveryToken(token string) error {
+	req, err := http.NewRequest("DELETE", token+"?recursive=true", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300,
+		resp.StatusCode == http.StatusForbidden,
+		resp.StatusCode == http.StatusNotFound,
+		resp.StatusCode == http.StatusMethodNotAllowed,
+		resp.StatusCode == http.StatusNotImplemented:
+		return nil
+	}
+	return fmt.Errorf("deleting %s: %s", token, resp.Status)
+}
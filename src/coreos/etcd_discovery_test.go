@@ -0,0 +1,54 @@
+package coreos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEtcdDiscoveryToken(t *testing.T) {
+	tokens := make(map[string]bool)
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/new" {
+			if r.URL.Query().Get("size") != "5" {
+				http.Error(w, "bad size", http.StatusBadRequest)
+				return
+			}
+			tokens["/abc"] = true
+			fmt.Fprintf(w, "%s/abc\n", ts.URL)
+			return
+		}
+		if !tokens[r.URL.Path] {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "DELETE" {
+			delete(tokens, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	token, err := newDiscoveryToken(ts.URL+"/", 5)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if token != ts.URL+"/abc" {
+		t.Fatalf("got token %q", token)
+	}
+
+	if ok, err := discoveryTokenExists(token); !ok || err != nil {
+		t.Fatalf("exists: %v, %v", ok, err)
+	}
+	if err := deleteDiscoveryToken(token); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if ok, err := discoveryTokenExists(token); ok || err != nil {
+		t.Fatalf("exists after delete: %v, %v", ok, err)
+	}
+	// deleting again hits a 404, which is fine
+	if err := deleteDiscoveryToken(token); err != nil {
+		t.Fatalf("second delete: %s", err)
+	}
+}
@@ -0,0 +1,185 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	etcdMember struct {
+		ID         string   `json:"id,omitempty"`
+		Name       string   `json:"name,omitempty"`
+		PeerURLs   []string `json:"peerURLs"`
+		ClientURLs []string `json:"clientURLs,omitempty"`
+	}
+
+	etcdMembers struct {
+		Members []etcdMember `json:"members"`
+	}
+)
+
+// etcdQuorum is the number of members a cluster of size n needs to agree.
+func etcdQuorum(n int) int {
+	return n/2 + 1
+}
+
+func (c *etcdClient) members() ([]etcdMember, error) {
+	var m etcdMembers
+	if err := c.do("GET", "/v2/members", nil, &m); err != nil {
+		return nil, err
+	}
+	sort.Slice(m.Members, func(i, j int) bool { return m.Members[i].ID < m.Members[j].ID })
+	return m.Members, nil
+}
+
+func (c *etcdClient) member(id string) (*etcdMember, error) {
+	members, err := c.members()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.ID == id {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *etcdClient) addMember(peerURLs []string) (*etcdMember, error) {
+	var m etcdMember
+	if err := c.doJSON("POST", "/v2/members", &etcdMember{PeerURLs: peerURLs}, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (c *etcdClient) updateMember(id string, peerURLs []string) error {
+	return c.doJSON("PUT", "/v2/members/"+id, &etcdMember{PeerURLs: peerURLs}, nil)
+}
+
+func (c *etcdClient) removeMember(id string) error {
+	return c.do("DELETE", "/v2/members/"+id, nil, nil)
+}
+
+// healthy reports whether the member behind one of urls answers /health
+// positively.
+func (c *etcdClient) healthy(urls []string) bool {
+	if len(urls) == 0 {
+		return false
+	}
+	var h struct {
+		Health string `json:"health"`
+	}
+	m := &etcdClient{client: c.client}
+	for _, u := range urls {
+		m.endpoints = append(m.endpoints, strings.TrimSuffix(u, "/"))
+	}
+	return m.do("GET", "/health", nil, &h) == nil && h.Health == "true"
+}
+
+// checkRemoval refuses to remove a member when the members left behind
+// would not have a healthy quorum.
+func (c *etcdClient) checkRemoval(members []etcdMember, id string) error {
+	var healthy, remaining int
+	for _, m := range members {
+		if m.ID == id {
+			continue
+		}
+		remaining++
+		if c.healthy(m.ClientURLs) {
+			healthy++
+		}
+	}
+	if remaining == 0 {
+		return fmt.Errorf("refusing to remove %s, the last member of the cluster", id)
+	}
+	if need := etcdQuorum(remaining); healthy < need {
+		return fmt.Errorf("refusing to remove %s: %d of the remaining %d members are healthy, %d are needed for quorum", id, healthy, remaining, need)
+	}
+	return nil
+}
+
+// initialCluster renders an etcd initial-cluster value from the members
+// that have started, plus the named member joining with peerURLs.
+func initialCluster(members []etcdMember, name string, peerURLs []string) string {
+	var entries []string
+	for _, m := range members {
+		if m.Name == "" || m.Name == name {
+			continue
+		}
+		for _, u := range m.PeerURLs {
+			entries = append(entries, m.Name+"="+u)
+		}
+	}
+	for _, u := range peerURLs {
+		entries = append(entries, name+"="+u)
+	}
+	return strings.Join(entries, ",")
+}
+
+// etcdClusterStatus is what one round of health checks found.
+type etcdClusterStatus struct {
+	healthy   int
+	leader    string
+	memberIDs []string
+	started   int
+}
+
+// clusterStatus checks /health on every endpoint and reads the member
+// list and leader from the first healthy one.
+func (c *etcdClient) clusterStatus(endpoints []string) *etcdClusterStatus {
+	s := &etcdClusterStatus{}
+	for _, e := range endpoints {
+		if !c.healthy([]string{e}) {
+			continue
+		}
+		s.healthy++
+		if s.leader != "" {
+			continue
+		}
+
+		ec := &etcdClient{endpoints: []string{e}, client: c.client}
+		var stats struct {
+			LeaderInfo struct {
+				Leader string `json:"leader"`
+			} `json:"leaderInfo"`
+		}
+		if err := ec.do("GET", "/v2/stats/self", nil, &stats); err != nil {
+			continue
+		}
+		members, err := ec.members()
+		if err != nil {
+			continue
+		}
+		s.leader = stats.LeaderInfo.Leader
+		s.memberIDs, s.started = nil, 0
+		for _, m := range members {
+			s.memberIDs = append(s.memberIDs, m.ID)
+			if m.Name != "" {
+				s.started++
+			}
+		}
+	}
+	return s
+}
+
+// waitHealthy polls endpoints until at least quorum of them are healthy,
+// a leader is elected and memberCount members have started.
+func (c *etcdClient) waitHealthy(endpoints []string, quorum, memberCount int, timeout, interval time.Duration) (*etcdClusterStatus, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s := c.clusterStatus(endpoints)
+		if s.healthy >= quorum && s.leader != "" && s.started >= memberCount {
+			return s, nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return nil, fmt.Errorf("etcd did not become healthy within %s: %d of %d endpoints healthy (need %d), %d members started (need %d), leader %q",
+				timeout, s.healthy, len(endpoints), quorum, s.started, memberCount, s.leader)
+		}
+		log.Printf("[INFO] waiting for etcd: %d of %d endpoints healthy, %d members started", s.healthy, len(endpoints), s.started)
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,163 @@
+package coreos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMembers serves the members API for a list of members.
+type fakeMembers struct {
+	mu      sync.Mutex
+	members []etcdMember
+	nextID  int
+}
+
+func (f *fakeMembers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v2/members":
+		json.NewEncoder(w).Encode(&etcdMembers{Members: f.members})
+	case r.Method == "POST" && r.URL.Path == "/v2/members":
+		var m etcdMember
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.nextID++
+		m.ID = fmt.Sprintf("new%d", f.nextID)
+		f.members = append(f.members, m)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(&m)
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/v2/members/"):
+		id := strings.TrimPrefix(r.URL.Path, "/v2/members/")
+		for i, m := range f.members {
+			if m.ID == id {
+				f.members = append(f.members[:i], f.members[i+1:]...)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"Member not found"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func healthServer(health string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"health": %q}`, health)
+	}))
+}
+
+func TestEtcdMembers(t *testing.T) {
+	a, b := healthServer("true"), healthServer("true")
+	defer a.Close()
+	defer b.Close()
+	sick := healthServer("false")
+	defer sick.Close()
+
+	f := &fakeMembers{members: []etcdMember{
+		{ID: "a", Name: "infra0", PeerURLs: []string{"http://10.0.0.1:2380"}, ClientURLs: []string{a.URL}},
+		{ID: "b", Name: "infra1", PeerURLs: []string{"http://10.0.0.2:2380"}, ClientURLs: []string{b.URL}},
+		{ID: "c", Name: "infra2", PeerURLs: []string{"http://10.0.0.3:2380"}, ClientURLs: []string{sick.URL}},
+	}}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	c, err := newEtcdClient([]string{ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	members, err := c.members()
+	if err != nil {
+		t.Fatalf("members: %s", err)
+	}
+
+	// one of the two left would be unhealthy
+	if err := c.checkRemoval(members, "a"); err == nil || !strings.Contains(err.Error(), "quorum") {
+		t.Fatalf("expected a quorum error, got %v", err)
+	}
+	// the unhealthy member itself can go
+	if err := c.checkRemoval(members, "c"); err != nil {
+		t.Fatalf("removing the unhealthy member: %s", err)
+	}
+	if err := c.removeMember("c"); err != nil {
+		t.Fatalf("remove: %s", err)
+	}
+	if err := c.removeMember("c"); err == nil || !strings.Contains(err.Error(), "Member not found") {
+		t.Fatalf("expected member not found, got %v", err)
+	}
+
+	m, err := c.addMember([]string{"http://10.0.0.4:2380"})
+	if err != nil {
+		t.Fatalf("add: %s", err)
+	}
+	if got, err := c.member(m.ID); err != nil || got == nil {
+		t.Fatalf("member %s: %v, %v", m.ID, got, err)
+	}
+	if err := c.checkRemoval([]etcdMember{*m}, m.ID); err == nil {
+		t.Fatalf("removing the last member was allowed")
+	}
+}
+
+func TestInitialCluster(t *testing.T) {
+	members := []etcdMember{
+		{ID: "a", Name: "infra0", PeerURLs: []string{"http://10.0.0.1:2380"}},
+		{ID: "b", Name: "infra1", PeerURLs: []string{"http://10.0.0.2:2380"}},
+		{ID: "c", PeerURLs: []string{"http://10.0.0.9:2380"}},
+	}
+	got := initialCluster(members, "infra2", []string{"http://10.0.0.3:2380"})
+	want := "infra0=http://10.0.0.1:2380,infra1=http://10.0.0.2:2380,infra2=http://10.0.0.3:2380"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEtcdWaitHealthy(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		checks int
+	)
+	// the member only reports healthy from its third check on
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/health":
+			checks++
+			fmt.Fprintf(w, `{"health": "%t"}`, checks >= 3)
+		case "/v2/stats/self":
+			fmt.Fprint(w, `{"id": "a", "state": "StateLeader", "leaderInfo": {"leader": "a"}}`)
+		case "/v2/members":
+			fmt.Fprint(w, `{"members": [{"id": "a", "name": "infra0", "peerURLs": ["http://10.0.0.1:2380"]}, {"id": "b", "peerURLs": ["http://10.0.0.2:2380"]}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := newEtcdClient([]string{ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.waitHealthy(c.endpoints, 1, 1, time.Second, time.Millisecond)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s.leader != "a" || strings.Join(s.memberIDs, ",") != "a,b" || s.started != 1 {
+		t.Fatalf("got %+v", s)
+	}
+
+	// b never starts
+	_, err = c.waitHealthy(c.endpoints, 1, 2, 20*time.Millisecond, time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "1 members started (need 2)") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package coreos
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// etcdStaticMember is a member of a cluster bootstrapped without
+// discovery.
+type etcdStaticMember struct {
+	name string
+	ip   string
+}
+
+// etcdStaticCluster is the bootstrap configuration shared by every member
+// of a statically configured cluster.
+type etcdStaticCluster struct {
+	members    []etcdStaticMember
+	token      string
+	tls        bool
+	clientPort int
+	peerPort   int
+	version    string
+	certFile   string
+	keyFile    string
+	caFile     string
+}
+
+func (c *etcdStaticCluster) validate() error {
+	if len(c.members) == 0 {
+		return fmt.Errorf("a cluster needs at least one member")
+	}
+	names := make(map[string]bool)
+	ips := make(map[string]bool)
+	for _, m := range c.members {
+		if m.name == "" || strings.ContainsAny(m.name, "=, ") {
+			return fmt.Errorf("member name %q must be non-empty and not contain '=', ',' or spaces", m.name)
+		}
+		if names[m.name] {
+			return fmt.Errorf("member name %q is used twice", m.name)
+		}
+		names[m.name] = true
+		if net.ParseIP(m.ip) == nil {
+			return fmt.Errorf("member %s: invalid IP address %q", m.name, m.ip)
+		}
+		if ips[m.ip] {
+			return fmt.Errorf("member %s: IP address %s is used twice", m.name, m.ip)
+		}
+		ips[m.ip] = true
+	}
+	for _, p := range []int{c.clientPort, c.peerPort} {
+		if p < 1 || p > 65535 {
+			return fmt.Errorf("port %d is out of range", p)
+		}
+	}
+	if c.clientPort == c.peerPort {
+		return fmt.Errorf("client_port and peer_port must differ")
+	}
+	if c.tls && (c.certFile == "" || c.keyFile == "" || c.caFile == "") {
+		return fmt.Errorf("tls needs cert_file, key_file and trusted_ca_file")
+	}
+	return nil
+}
+
+func (c *etcdStaticCluster) url(ip string, port int) string {
+	scheme := "http"
+	if c.tls {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(ip, strconv.Itoa(port))
+}
+
+func (c *etcdStaticCluster) clientURL(i int) string {
+	return c.url(c.members[i].ip, c.clientPort)
+}
+
+func (c *etcdStaticCluster) peerURL(i int) string {
+	return c.url(c.members[i].ip, c.peerPort)
+}
+
+// listenClientURL listens on every address, so etcdctl works over
+// loopback too.
+func (c *etcdStaticCluster) listenClientURL() string {
+	return c.url("0.0.0.0", c.clientPort)
+}
+
+func (c *etcdStaticCluster) initialCluster() string {
+	members := make([]etcdMember, len(c.members))
+	for i, m := range c.members {
+		members[i] = etcdMember{Name: m.name, PeerURLs: []string{c.peerURL(i)}}
+	}
+	return initialCluster(members, "", nil)
+}
+
+// initialClusterToken is the configured token, or one derived from the
+// initial cluster so that re-rendering gives the same result.
+func (c *etcdStaticCluster) initialClusterToken() string {
+	if c.token != "" {
+		return c.token
+	}
+	return "etcd-" + hash(c.initialCluster())[:12]
+}
+
+// flags are the settings of member i, in etcd's flag names.
+func (c *etcdStaticCluster) flags(i int) [][2]string {
+	flags := [][2]string{
+		{"name", c.members[i].name},
+		{"initial-advertise-peer-urls", c.peerURL(i)},
+		{"listen-peer-urls", c.peerURL(i)},
+		{"advertise-client-urls", c.clientURL(i)},
+		{"listen-client-urls", c.listenClientURL()},
+		{"initial-cluster", c.initialCluster()},
+		{"initial-cluster-token", c.initialClusterToken()},
+		{"initial-cluster-state", "new"},
+	}
+	if c.tls {
+		flags = append(flags, [][2]string{
+			{"cert-file", c.certFile},
+			{"key-file", c.keyFile},
+			{"trusted-ca-file", c.caFile},
+			{"client-cert-auth", "true"},
+			{"peer-cert-file", c.certFile},
+			{"peer-key-file", c.keyFile},
+			{"peer-trusted-ca-file", c.caFile},
+			{"peer-client-cert-auth", "true"},
+		}...)
+	}
+	return flags
+}
+
+// cloudConfig renders member i as a coreos.etcd2 section.
+func (c *etcdStaticCluster) cloudConfig(i int) string {
+	etcd2 := make(map[string]interface{})
+	for _, f := range c.flags(i) {
+		etcd2[f[0]] = f[1]
+	}
+	cc := &cloudConfig{}
+	cc.CoreOS.Etcd2 = etcd2
+	cc.CoreOS.Units = []cloudConfigUnit{{Name: "etcd2.service", Command: "start"}}
+	return cc.String()
+}
+
+// ignition renders member i as an etcd-member.service drop-in, in the
+// form the Container Linux Config transpiler uses.
+func (c *etcdStaticCluster) ignition(i int) string {
+	var buf bytes.Buffer
+	buf.WriteString("[Service]\n")
+	if c.version != "" {
+		fmt.Fprintf(&buf, "Environment=\"ETCD_IMAGE_TAG=v%s\"\n", strings.TrimPrefix(c.version, "v"))
+	}
+	var flags []string
+	for _, f := range c.flags(i) {
+		flags = append(flags, fmt.Sprintf("--%s=\"%s\"", f[0], f[1]))
+	}
+	fmt.Fprintf(&buf, "ExecStart=\nExecStart=/usr/lib/coreos/etcd-wrapper $ETCD_OPTS \\\n  %s\n", strings.Join(flags, " \\\n  "))
+
+	ign := newIgnitionConfig()
+	u := ign.unit("etcd-member.service")
+	u.Enable = true
+	u.Dropins = append(u.Dropins, ignitionDropin{Name: "20-static-cluster.conf", Contents: buf.String()})
+	return ign.String()
+}
@@ -0,0 +1,81 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEtcdStaticCluster(t *testing.T) {
+	c := &etcdStaticCluster{
+		members: []etcdStaticMember{
+			{"node0", "10.0.0.10"},
+			{"node1", "10.0.0.11"},
+			{"node2", "fd00::12"},
+		},
+		clientPort: 2379,
+		peerPort:   2380,
+	}
+	if err := c.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := "node0=http://10.0.0.10:2380,node1=http://10.0.0.11:2380,node2=http://[fd00::12]:2380"
+	if got := c.initialCluster(); got != want {
+		t.Fatalf("initial cluster:\n%s\nwant:\n%s", got, want)
+	}
+	token := c.initialClusterToken()
+	if !strings.HasPrefix(token, "etcd-") || token != c.initialClusterToken() {
+		t.Fatalf("token %q", token)
+	}
+	c.token = "prod"
+	if c.initialClusterToken() != "prod" {
+		t.Fatal("token ignored")
+	}
+
+	cc := c.cloudConfig(1)
+	for _, s := range []string{"etcd2:", "name: node1", "advertise-client-urls: http://10.0.0.11:2379", "listen-client-urls: http://0.0.0.0:2379", "initial-cluster-token: prod", "etcd2.service"} {
+		if !strings.Contains(cc, s) {
+			t.Errorf("cloud-config is missing %q:\n%s", s, cc)
+		}
+	}
+
+	c.tls = true
+	c.version = "3.2.11"
+	c.certFile, c.keyFile, c.caFile = "/etc/ssl/etcd/cert.pem", "/etc/ssl/etcd/key.pem", "/etc/ssl/etcd/ca.pem"
+	ign := c.ignition(2)
+	for _, s := range []string{"etcd-member.service", `"enable":true`, "ETCD_IMAGE_TAG=v3.2.11", `--initial-advertise-peer-urls=\"https://[fd00::12]:2380\"`, `--peer-client-cert-auth=\"true\"`} {
+		if !strings.Contains(ign, s) {
+			t.Errorf("ignition is missing %q:\n%s", s, ign)
+		}
+	}
+}
+
+func TestEtcdStaticClusterValidate(t *testing.T) {
+	base := func() *etcdStaticCluster {
+		return &etcdStaticCluster{
+			members:    []etcdStaticMember{{"a", "10.0.0.1"}, {"b", "10.0.0.2"}},
+			clientPort: 2379,
+			peerPort:   2380,
+		}
+	}
+	cases := []struct {
+		change func(c *etcdStaticCluster)
+		err    string
+	}{
+		{func(c *etcdStaticCluster) { c.members = nil }, "at least one member"},
+		{func(c *etcdStaticCluster) { c.members[1].name = "a" }, "used twice"},
+		{func(c *etcdStaticCluster) { c.members[1].name = "b=c" }, "must be non-empty"},
+		{func(c *etcdStaticCluster) { c.members[1].ip = "node-b" }, "invalid IP"},
+		{func(c *etcdStaticCluster) { c.members[1].ip = "10.0.0.1" }, "used twice"},
+		{func(c *etcdStaticCluster) { c.peerPort = 2379 }, "must differ"},
+		{func(c *etcdStaticCluster) { c.clientPort = 70000 }, "out of range"},
+		{func(c *etcdStaticCluster) { c.tls = true }, "needs cert_file"},
+	}
+	for i, tc := range cases {
+		c := base()
+		tc.change(c)
+		if err := c.validate(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: expected %q, got %v", i, tc.err, err)
+		}
+	}
+}
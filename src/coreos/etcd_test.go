@@ -0,0 +1,310 @@
+package coreos
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeEtcd is an in-memory etcd v2 keys API, just enough for the client.
+type fakeEtcd struct {
+	mu    sync.Mutex
+	index uint64
+	nodes map[string]*etcdNode
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{nodes: map[string]*etcdNode{"/": &etcdNode{Key: "/", Dir: true}}}
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := path.Clean("/" + strings.TrimPrefix(r.URL.Path, "/v2/keys"))
+	resp, err := f.handle(r, key)
+	w.Header().Set("X-Etcd-Index", strconv.FormatUint(f.index, 10))
+	if err != nil {
+		w.WriteHeader(err.status())
+		json.NewEncoder(w).Encode(err)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (f *fakeEtcd) tree(key string) *etcdNode {
+	n := *f.nodes[key]
+	if !n.Dir {
+		return &n
+	}
+	var keys []string
+	for k := range f.nodes {
+		if k != key && path.Dir(k) == key {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		n.Nodes = append(n.Nodes, f.tree(k))
+	}
+	return &n
+}
+
+func (f *fakeEtcd) handle(r *http.Request, key string) (*etcdResponse, *etcdError) {
+	cur, exists := f.nodes[key]
+	if v := r.FormValue("prevIndex"); v != "" && (!exists || strconv.FormatUint(cur.ModifiedIndex, 10) != v) {
+		if !exists {
+			return nil, newEtcdError(etcdErrKeyNotFound, key, f.index)
+		}
+		return nil, newEtcdError(etcdErrTestFailed, key, f.index)
+	}
+
+	switch r.Method {
+	case "GET":
+		if !exists {
+			return nil, newEtcdError(etcdErrKeyNotFound, key, f.index)
+		}
+		return &etcdResponse{Action: "get", Node: f.tree(key)}, nil
+
+	case "PUT":
+		switch r.FormValue("prevExist") {
+		case "false":
+			if exists {
+				return nil, newEtcdError(etcdErrNodeExist, key, f.index)
+			}
+		case "true":
+			if !exists {
+				return nil, newEtcdError(etcdErrKeyNotFound, key, f.index)
+			}
+		}
+		f.index++
+		for p := path.Dir(key); p != "/"; p = path.Dir(p) {
+			if _, ok := f.nodes[p]; !ok {
+				f.nodes[p] = &etcdNode{Key: p, Dir: true, CreatedIndex: f.index, ModifiedIndex: f.index}
+			}
+		}
+		ttl, _ := strconv.ParseInt(r.FormValue("ttl"), 10, 64)
+		n := &etcdNode{Key: key, Value: r.FormValue("value"), Dir: r.FormValue("dir") == "true", TTL: ttl, CreatedIndex: f.index, ModifiedIndex: f.index}
+		f.nodes[key] = n
+		return &etcdResponse{Action: "set", Node: n, PrevNode: cur}, nil
+
+	case "DELETE":
+		if !exists {
+			return nil, newEtcdError(etcdErrKeyNotFound, key, f.index)
+		}
+		if cur.Dir && r.FormValue("recursive") != "true" {
+			if r.FormValue("dir") != "true" {
+				return nil, newEtcdError(etcdErrNotFile, key, f.index)
+			}
+			if len(f.tree(key).Nodes) > 0 {
+				return nil, newEtcdError(etcdErrDirNotEmpty, key, f.index)
+			}
+		}
+		f.index++
+		for k := range f.nodes {
+			if k == key || strings.HasPrefix(k, key+"/") {
+				delete(f.nodes, k)
+			}
+		}
+		return &etcdResponse{Action: "delete", PrevNode: cur}, nil
+	}
+	return nil, newEtcdError(etcdErrNotFile, key, f.index)
+}
+
+func TestEtcdClientSyncDir(t *testing.T) {
+	f := newFakeEtcd()
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	// the first endpoint is down, the client moves on to the second
+	c, err := newEtcdClient([]string{"http://127.0.0.1:1", ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.mkdir("/config", 0, "false"); err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	for _, k := range []string{"/config/stray", "/config/subnets/10.1.2.0-24"} {
+		if _, err := c.set(k, "x", 0, 0, ""); err != nil {
+			t.Fatalf("set: %s", err)
+		}
+	}
+
+	want := map[string]string{"a": "1", "nested/b": "2"}
+	indexes, err := c.syncDir("/config", want, nil, false)
+	if err != nil {
+		t.Fatalf("sync: %s", err)
+	}
+	if len(indexes) != 2 || indexes["nested/b"] == 0 {
+		t.Fatalf("indexes: %v", indexes)
+	}
+	if _, err := c.get("/config/stray", false); err != nil {
+		t.Fatalf("unmanaged key was pruned without prune: %s", err)
+	}
+
+	// an out-of-band edit makes the next sync fail until it is re-read
+	if _, err := c.set("/config/a", "edited", 0, 0, ""); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	want["a"] = "3"
+	if _, err := c.syncDir("/config", want, indexes, true); err == nil || !strings.Contains(err.Error(), "changed since it was last read") {
+		t.Fatalf("expected a compare failure, got %v", err)
+	}
+
+	current, err := c.readDir("/config")
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	if current["a"].Value != "edited" || current["nested/b"].Value != "2" {
+		t.Fatalf("read: %v", current)
+	}
+	indexes["a"] = current["a"].ModifiedIndex
+
+	delete(want, "nested/b")
+	if _, err := c.syncDir("/config", want, indexes, true); err != nil {
+		t.Fatalf("sync: %s", err)
+	}
+	current, err = c.readDir("/config")
+	if err != nil {
+		t.Fatalf("read: %s", err)
+	}
+	// prune leaves keys in subdirectories it doesn't manage alone
+	if len(current) != 2 || current["a"].Value != "3" || current["subnets/10.1.2.0-24"] == nil {
+		t.Fatalf("after prune: %v", current)
+	}
+}
+
+func TestEtcdClientRemoveDir(t *testing.T) {
+	ts := httptest.NewServer(newFakeEtcd())
+	defer ts.Close()
+	c, err := newEtcdClient([]string{ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"/adopted/config", "/adopted/subnets/a", "/created/config", "/created/other", "/emptied/config"} {
+		if _, err := c.set(k, "x", 0, 0, ""); err != nil {
+			t.Fatalf("set: %s", err)
+		}
+	}
+
+	// an adopted directory keeps the keys others wrote
+	if err := c.removeDir("/adopted", []string{"config"}, false); err != nil {
+		t.Fatalf("remove adopted: %s", err)
+	}
+	current, err := c.readDir("/adopted")
+	if err != nil || len(current) != 1 || current["subnets/a"] == nil {
+		t.Fatalf("adopted: %v %v", current, err)
+	}
+
+	if err := c.removeDir("/emptied", []string{"config", "gone"}, false); err != nil {
+		t.Fatalf("remove emptied: %s", err)
+	}
+	if err := c.removeDir("/created", []string{"config"}, true); err != nil {
+		t.Fatalf("remove created: %s", err)
+	}
+	for _, k := range []string{"/emptied", "/created"} {
+		if _, err := c.get(k, false); !isEtcdError(err, etcdErrKeyNotFound) {
+			t.Errorf("%s: expected key not found, got %v", k, err)
+		}
+	}
+}
+
+func TestEtcdClientTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(newFakeEtcd())
+	defer ts.Close()
+
+	if _, err := newEtcdClient([]string{ts.URL}, "not a certificate", "", ""); err == nil {
+		t.Fatalf("expected an error for an invalid ca_cert")
+	}
+	if _, err := newEtcdClient([]string{ts.URL}, "", "cert", ""); err == nil {
+		t.Fatalf("expected an error for a client certificate without a key")
+	}
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	c, err := newEtcdClient([]string{ts.URL}, string(ca), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.set("/k", "v", 30, 0, "false"); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	n, err := c.get("/k", false)
+	if err != nil {
+		t.Fatalf("get: %s", err)
+	}
+	if n.Value != "v" || n.TTL != 30 {
+		t.Fatalf("got %+v", n)
+	}
+	if _, err := c.get("/missing", false); !isEtcdError(err, etcdErrKeyNotFound) {
+		t.Fatalf("expected key not found, got %v", err)
+	}
+}
+
+func TestCreateEtcdKeyAdopts(t *testing.T) {
+	ts := httptest.NewServer(newFakeEtcd())
+	defer ts.Close()
+	c, err := newEtcdClient([]string{ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := createEtcdKey(c, "/new", "a", 0)
+	if err != nil || n.Value != "a" {
+		t.Fatalf("create: %+v %v", n, err)
+	}
+	// set by cloud-config before the resource was applied
+	if _, err := c.set("/seeded", "old", 0, 0, ""); err != nil {
+		t.Fatalf("set: %s", err)
+	}
+	n, err = createEtcdKey(c, "/seeded", "new", 0)
+	if err != nil || n.Value != "new" {
+		t.Fatalf("adopt: %+v %v", n, err)
+	}
+	if _, err := c.mkdir("/dir", 0, "false"); err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	if _, err := createEtcdKey(c, "/dir", "x", 0); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Fatalf("expected a directory error, got %v", err)
+	}
+}
+
+func TestEtcdClientMkdirClearsTTL(t *testing.T) {
+	var forms []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		forms = append(forms, r.PostForm)
+		w.Header().Set("X-Etcd-Index", "1")
+		json.NewEncoder(w).Encode(&etcdResponse{Action: "update", Node: &etcdNode{Key: "/dir", Dir: true}})
+	}))
+	defer ts.Close()
+	c, err := newEtcdClient([]string{ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ttl := range []int{30, 0} {
+		if _, err := c.mkdir("/dir", ttl, "true"); err != nil {
+			t.Fatalf("mkdir: %s", err)
+		}
+	}
+	if _, err := c.mkdir("/other", 0, "false"); err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	if v, ok := forms[0]["ttl"]; !ok || v[0] != "30" {
+		t.Errorf("refresh: %v", forms[0])
+	}
+	if v, ok := forms[1]["ttl"]; !ok || v[0] != "" || forms[1].Get("prevExist") != "true" {
+		t.Errorf("clear: %v", forms[1])
+	}
+	if _, ok := forms[2]["ttl"]; ok {
+		t.Errorf("create: %v", forms[2])
+	}
+}
@@ -0,0 +1,156 @@
+package coreos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// fcosStreamURL is where Fedora CoreOS publishes stream metadata; the
+// stream name and ".json" are appended.
+const fcosStreamURL = "https://builds.coreos.fedoraproject.org/streams/"
+
+type (
+	fcosStream struct {
+		Stream        string                      `json:"stream"`
+		Metadata      fcosStreamMetadata          `json:"metadata"`
+		Architectures map[string]fcosArchitecture `json:"architectures"`
+	}
+
+	fcosStreamMetadata struct {
+		LastModified string `json:"last-modified"`
+	}
+
+	fcosArchitecture struct {
+		Artifacts map[string]fcosPlatformArtifacts `json:"artifacts"`
+		Images    fcosImages                       `json:"images"`
+	}
+
+	fcosPlatformArtifacts struct {
+		Release string                             `json:"release"`
+		Formats map[string]map[string]fcosArtifact `json:"formats"`
+	}
+
+	fcosArtifact struct {
+		Location  string `json:"location"`
+		Signature string `json:"signature"`
+		Sha256    string `json:"sha256"`
+	}
+
+	fcosImages struct {
+		AWS *fcosAWSImages `json:"aws"`
+		GCP *fcosGCPImage  `json:"gcp"`
+	}
+
+	fcosAWSImages struct {
+		Regions map[string]fcosRegionImage `json:"regions"`
+	}
+
+	fcosRegionImage struct {
+		Release string `json:"release"`
+		Image   string `json:"image"`
+	}
+
+	fcosGCPImage struct {
+		Release string `json:"release"`
+		Project string `json:"project"`
+		Family  string `json:"family"`
+		Name    string `json:"name"`
+	}
+
+	// fcosImage is what a stream resolves to for one platform.
+	fcosImage struct {
+		Release   string
+		Image     string
+		Project   string
+		Family    string
+		Location  string
+		Signature string
+		Sha256    string
+	}
+)
+
+// fcosDefaultFormats is the artifact format used for platforms that ship
+// disk images when no format is requested.
+var fcosDefaultFormats = map[string]string{
+	"azure":     "vhd.xz",
+	"metal":     "raw.xz",
+	"openstack": "qcow2.xz",
+	"qemu":      "qcow2.xz",
+	"vmware":    "ova",
+}
+
+func getFCOSStream(baseURL, stream string) (*fcosStream, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/" + stream + ".json"
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	var s fcosStream
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// resolve finds the image for a platform. aws needs a region; gcp returns
+// the image name and project; every other platform returns the artifact
+// of the given format, or the platform's usual one.
+func (s *fcosStream) resolve(arch, platform, region, format string) (*fcosImage, error) {
+	a, ok := s.Architectures[arch]
+	if !ok {
+		return nil, fmt.Errorf("stream %s has no architecture %s", s.Stream, arch)
+	}
+
+	switch platform {
+	case "aws":
+		if a.Images.AWS == nil {
+			return nil, fmt.Errorf("stream %s has no aws images for %s", s.Stream, arch)
+		}
+		r, ok := a.Images.AWS.Regions[region]
+		if !ok {
+			return nil, fmt.Errorf("stream %s has no aws image in region %q", s.Stream, region)
+		}
+		return &fcosImage{Release: r.Release, Image: r.Image}, nil
+	case "gcp":
+		if a.Images.GCP == nil {
+			return nil, fmt.Errorf("stream %s has no gcp image for %s", s.Stream, arch)
+		}
+		g := a.Images.GCP
+		return &fcosImage{Release: g.Release, Image: g.Name, Project: g.Project, Family: g.Family}, nil
+	}
+
+	p, ok := a.Artifacts[platform]
+	if !ok {
+		return nil, fmt.Errorf("stream %s has no %s artifacts for %s", s.Stream, platform, arch)
+	}
+	if format == "" {
+		format = fcosDefaultFormats[platform]
+	}
+	f, ok := p.Formats[format]
+	if !ok {
+		formats := make([]string, 0, len(p.Formats))
+		for k := range p.Formats {
+			formats = append(formats, k)
+		}
+		sort.Strings(formats)
+		return nil, fmt.Errorf("no %q format for %s, available: %s", format, platform, strings.Join(formats, ", "))
+	}
+	disk, ok := f["disk"]
+	if !ok {
+		return nil, fmt.Errorf("%s %s format has no disk artifact", platform, format)
+	}
+	return &fcosImage{
+		Release:   p.Release,
+		Location:  disk.Location,
+		Signature: disk.Signature,
+		Sha256:    disk.Sha256,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package coreos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testFCOSStream = `{
+  "stream": "stable",
+  "metadata": {"last-modified": "2023-06-01T12:00:00Z"},
+  "architectures": {
+    "x86_64": {
+      "artifacts": {
+        "qemu": {
+          "release": "38.20230514.3.0",
+          "formats": {
+            "qcow2.xz": {
+              "disk": {
+                "location": "https://example.com/fcos-qemu.qcow2.xz",
+                "signature": "https://example.com/fcos-qemu.qcow2.xz.sig",
+                "sha256": "abc123"
+              }
+            }
+          }
+        }
+      },
+      "images": {
+        "aws": {
+          "regions": {
+            "us-west-2": {"release": "38.20230514.3.0", "image": "ami-0123"}
+          }
+        },
+        "gcp": {
+          "release": "38.20230514.3.0",
+          "project": "fedora-coreos-cloud",
+          "family": "fedora-coreos-stable",
+          "name": "fedora-coreos-38-20230514-3-0-gcp-x86-64"
+        }
+      }
+    }
+  }
+}`
+
+func TestFCOSStreamResolve(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stable.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, testFCOSStream)
+	}))
+	defer ts.Close()
+
+	s, err := getFCOSStream(ts.URL+"/", "stable")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s.Metadata.LastModified != "2023-06-01T12:00:00Z" {
+		t.Fatalf("last-modified: %s", s.Metadata.LastModified)
+	}
+
+	cases := []struct {
+		platform string
+		region   string
+		want     fcosImage
+	}{
+		{"aws", "us-west-2", fcosImage{Release: "38.20230514.3.0", Image: "ami-0123"}},
+		{"gcp", "", fcosImage{
+			Release: "38.20230514.3.0",
+			Image:   "fedora-coreos-38-20230514-3-0-gcp-x86-64",
+			Project: "fedora-coreos-cloud",
+			Family:  "fedora-coreos-stable",
+		}},
+		{"qemu", "", fcosImage{
+			Release:   "38.20230514.3.0",
+			Location:  "https://example.com/fcos-qemu.qcow2.xz",
+			Signature: "https://example.com/fcos-qemu.qcow2.xz.sig",
+			Sha256:    "abc123",
+		}},
+	}
+	for _, tc := range cases {
+		img, err := s.resolve("x86_64", tc.platform, tc.region, "")
+		if err != nil {
+			t.Fatalf("%s: %s", tc.platform, err)
+		}
+		if *img != tc.want {
+			t.Fatalf("%s: got %#v, want %#v", tc.platform, *img, tc.want)
+		}
+	}
+
+	if _, err := s.resolve("x86_64", "aws", "mars-1", ""); err == nil {
+		t.Fatal("expected error for unknown region")
+	}
+	if _, err := s.resolve("aarch64", "qemu", "", ""); err == nil {
+		t.Fatal("expected error for unknown architecture")
+	}
+
+	if _, err := getFCOSStream(ts.URL, "next"); err == nil {
+		t.Fatal("expected error for missing stream")
+	}
+}
@@ -0,0 +1,286 @@
+package coreos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// flannelEtcdPrefix is where flanneld looks for its config by default.
+const flannelEtcdPrefix = "/coreos.com/network"
+
+var flannelBackends = []string{"vxlan", "host-gw", "aws-vpc"}
+
+type (
+	// flannelNetworkConfig is the JSON flanneld reads from etcd.
+	flannelNetworkConfig struct {
+		Network   string         `json:"Network"`
+		SubnetLen int            `json:"SubnetLen"`
+		SubnetMin string         `json:"SubnetMin"`
+		SubnetMax string         `json:"SubnetMax"`
+		Backend   flannelBackend `json:"Backend"`
+	}
+
+	flannelBackend struct {
+		Type         string `json:"Type"`
+		VNI          int    `json:"VNI,omitempty"`
+		Port         int    `json:"Port,omitempty"`
+		RouteTableID string `json:"RouteTableID,omitempty"`
+	}
+)
+
+// flannelConfig is a flannel overlay network and how flanneld joins it.
+type flannelConfig struct {
+	network       string
+	subnetLen     int
+	subnetMin     string
+	subnetMax     string
+	backend       flannelBackend
+	minSubnets    int
+	etcdPrefix    string
+	etcdEndpoints []string
+	etcdCAFile    string
+	etcdCertFile  string
+	etcdKeyFile   string
+	iface         string
+	version       string
+}
+
+// resolve validates the config and fills in the subnet settings flanneld
+// would default, so what is rendered is what flanneld uses.
+func (c *flannelConfig) resolve() (*flannelNetworkConfig, error) {
+	ip, network, err := net.ParseCIDR(c.network)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("network %q must be an IPv4 CIDR", c.network)
+	}
+	if !ip.Equal(network.IP) {
+		return nil, fmt.Errorf("network %q has host bits set, use %s", c.network, network)
+	}
+	prefix, _ := network.Mask.Size()
+
+	subnetLen := c.subnetLen
+	switch {
+	case subnetLen == 0 && prefix > 28:
+		return nil, fmt.Errorf("network %s is too small, flannel needs at least a /28", network)
+	case subnetLen == 0 && prefix <= 22:
+		subnetLen = 24
+	case subnetLen == 0:
+		subnetLen = prefix + 2
+	case subnetLen > 30:
+		return nil, fmt.Errorf("subnet_len /%d leaves no room for hosts, use /30 or larger", subnetLen)
+	case subnetLen < prefix+2:
+		return nil, fmt.Errorf("subnet_len /%d is too large for network %s, which needs to hold at least 4 subnets; use /%d or smaller subnets", subnetLen, network, prefix+2)
+	}
+
+	base := ipv4ToUint(network.IP)
+	size := uint32(1) << uint(32-subnetLen)
+	last := base + (uint32(1)<<uint(32-prefix) - size)
+
+	// flanneld leaves the first subnet out by default
+	min, max := base+size, last
+	if c.subnetMin != "" {
+		if min, err = c.subnetBound("subnet_min", c.subnetMin, network, size); err != nil {
+			return nil, err
+		}
+	}
+	if c.subnetMax != "" {
+		if max, err = c.subnetBound("subnet_max", c.subnetMax, network, size); err != nil {
+			return nil, err
+		}
+	}
+	if min > max {
+		return nil, fmt.Errorf("subnet_min %s is above subnet_max %s", uintToIPv4(min), uintToIPv4(max))
+	}
+
+	backend := c.backend
+	if backend.Type == "" {
+		backend.Type = "vxlan"
+	}
+	if err := backend.validate(); err != nil {
+		return nil, err
+	}
+	if (c.etcdCertFile == "") != (c.etcdKeyFile == "") {
+		return nil, fmt.Errorf("etcd_certfile and etcd_keyfile must be set together")
+	}
+	for _, f := range []string{c.etcdCAFile, c.etcdCertFile, c.etcdKeyFile} {
+		if f != "" && !path.IsAbs(f) {
+			return nil, fmt.Errorf("%q must be an absolute path", f)
+		}
+	}
+
+	nc := &flannelNetworkConfig{
+		Network:   network.String(),
+		SubnetLen: subnetLen,
+		SubnetMin: uintToIPv4(min).String(),
+		SubnetMax: uintToIPv4(max).String(),
+		Backend:   backend,
+	}
+	if n := nc.subnetCount(); n < c.minSubnets {
+		return nil, fmt.Errorf("network %s has room for %d /%d subnets between %s and %s, fewer than the %d needed", network, n, subnetLen, nc.SubnetMin, nc.SubnetMax, c.minSubnets)
+	}
+	return nc, nil
+}
+
+// subnetCount is how many machines can get a subnet.
+func (nc *flannelNetworkConfig) subnetCount() int {
+	min := ipv4ToUint(net.ParseIP(nc.SubnetMin))
+	max := ipv4ToUint(net.ParseIP(nc.SubnetMax))
+	return int((max-min)>>uint(32-nc.SubnetLen)) + 1
+}
+
+func (c *flannelConfig) subnetBound(name, v string, network *net.IPNet, size uint32) (uint32, error) {
+	ip := net.ParseIP(v).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("%s %q must be an IPv4 address", name, v)
+	}
+	if !network.Contains(ip) {
+		return 0, fmt.Errorf("%s %s is outside network %s", name, ip, network)
+	}
+	n := ipv4ToUint(ip)
+	if n%size != 0 {
+		return 0, fmt.Errorf("%s %s is not the start of a subnet, e.g. %s", name, ip, uintToIPv4(n-n%size))
+	}
+	return n, nil
+}
+
+func (b *flannelBackend) validate() error {
+	if !oneOf(b.Type, flannelBackends) {
+		return fmt.Errorf("backend must be one of %s", strings.Join(flannelBackends, ", "))
+	}
+	if b.Type != "vxlan" && (b.VNI != 0 || b.Port != 0) {
+		return fmt.Errorf("vni and port only apply to the vxlan backend")
+	}
+	if b.Type != "aws-vpc" && b.RouteTableID != "" {
+		return fmt.Errorf("route_table_id only applies to the aws-vpc backend")
+	}
+	if b.VNI < 0 || b.VNI > 1<<24-1 {
+		return fmt.Errorf("vni %d is out of range", b.VNI)
+	}
+	if b.Port < 0 || b.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", b.Port)
+	}
+	if b.RouteTableID != "" && !strings.HasPrefix(b.RouteTableID, "rtb-") {
+		return fmt.Errorf("route_table_id %q must be a route table ID, rtb-...", b.RouteTableID)
+	}
+	return nil
+}
+
+func ipv4ToUint(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uintToIPv4(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+func (nc *flannelNetworkConfig) String() string {
+	buf, err := json.Marshal(nc)
+	if err != nil {
+		// every field is a plain string or int
+		panic(err)
+	}
+	return string(buf)
+}
+
+func (c *flannelConfig) etcdKey() string {
+	prefix := c.etcdPrefix
+	if prefix == "" {
+		prefix = flannelEtcdPrefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/config"
+}
+
+// storeConfig is the ExecStartPre that writes the network config to etcd
+// before flanneld looks for it.
+func (c *flannelConfig) storeConfig(nc *flannelNetworkConfig) string {
+	flags := ""
+	for _, f := range []struct{ flag, value string }{
+		{"endpoints", strings.Join(c.etcdEndpoints, ",")},
+		{"ca-file", c.etcdCAFile},
+		{"cert-file", c.etcdCertFile},
+		{"key-file", c.etcdKeyFile},
+	} {
+		if f.value != "" {
+			flags += fmt.Sprintf(" --%s=%s", f.flag, f.value)
+		}
+	}
+	return fmt.Sprintf("ExecStartPre=/usr/bin/etcdctl%s set %s '%s'", flags, c.etcdKey(), nc)
+}
+
+// flannelOption is a flanneld setting, named as a coreos.flannel key and
+// as a flag.
+type flannelOption struct {
+	key, flag, value string
+}
+
+func (c *flannelConfig) options() []flannelOption {
+	var opts []flannelOption
+	if len(c.etcdEndpoints) > 0 {
+		opts = append(opts, flannelOption{"etcd_endpoints", "etcd-endpoints", strings.Join(c.etcdEndpoints, ",")})
+	}
+	if c.etcdPrefix != "" {
+		opts = append(opts, flannelOption{"etcd_prefix", "etcd-prefix", c.etcdPrefix})
+	}
+	if c.etcdCAFile != "" {
+		opts = append(opts, flannelOption{"etcd_cafile", "etcd-cafile", c.etcdCAFile})
+	}
+	if c.etcdCertFile != "" {
+		opts = append(opts, flannelOption{"etcd_certfile", "etcd-certfile", c.etcdCertFile})
+		opts = append(opts, flannelOption{"etcd_keyfile", "etcd-keyfile", c.etcdKeyFile})
+	}
+	if c.iface != "" {
+		opts = append(opts, flannelOption{"interface", "iface", c.iface})
+	}
+	return opts
+}
+
+// dropIn renders the flanneld.service drop-in for Ignition, in the form
+// the Container Linux Config transpiler uses.
+func (c *flannelConfig) dropIn(nc *flannelNetworkConfig) string {
+	var buf bytes.Buffer
+	buf.WriteString("[Service]\n")
+	if c.version != "" {
+		fmt.Fprintf(&buf, "Environment=\"FLANNEL_IMAGE_TAG=v%s\"\n", strings.TrimPrefix(c.version, "v"))
+	}
+	buf.WriteString(c.storeConfig(nc) + "\n")
+	if opts := c.options(); len(opts) > 0 {
+		var flags []string
+		for _, o := range opts {
+			flags = append(flags, fmt.Sprintf("--%s=\"%s\"", o.flag, o.value))
+		}
+		fmt.Fprintf(&buf, "ExecStart=\nExecStart=/usr/lib/coreos/flannel-wrapper $FLANNEL_OPTS \\\n  %s\n", strings.Join(flags, " \\\n  "))
+	}
+	return buf.String()
+}
+
+// configs renders flanneld's setup as a coreos.flannel section with a
+// drop-in storing the network config, and as Ignition.
+func (c *flannelConfig) configs(nc *flannelNetworkConfig) (string, string) {
+	cc := &cloudConfig{}
+	if opts := c.options(); len(opts) > 0 {
+		cc.CoreOS.Flannel = make(map[string]interface{})
+		for _, o := range opts {
+			cc.CoreOS.Flannel[o.key] = o.value
+		}
+	}
+	cc.CoreOS.Units = []cloudConfigUnit{{
+		Name:    "flanneld.service",
+		Command: "start",
+		DropIns: []cloudConfigDropIn{{
+			Name:    "50-network-config.conf",
+			Content: "[Service]\n" + c.storeConfig(nc) + "\n",
+		}},
+	}}
+
+	ign := newIgnitionConfig()
+	u := ign.unit("flanneld.service")
+	u.Enable = true
+	u.Dropins = append(u.Dropins, ignitionDropin{Name: "20-network-config.conf", Contents: c.dropIn(nc)})
+	return cc.String(), ign.String()
+}
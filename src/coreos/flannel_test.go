@@ -0,0 +1,120 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFlannelResolve(t *testing.T) {
+	cases := []struct {
+		c    flannelConfig
+		want flannelNetworkConfig
+		n    int
+	}{
+		{
+			flannelConfig{network: "10.1.0.0/16"},
+			flannelNetworkConfig{Network: "10.1.0.0/16", SubnetLen: 24, SubnetMin: "10.1.1.0", SubnetMax: "10.1.255.0", Backend: flannelBackend{Type: "vxlan"}},
+			255,
+		},
+		{
+			flannelConfig{network: "192.168.0.0/24"},
+			flannelNetworkConfig{Network: "192.168.0.0/24", SubnetLen: 26, SubnetMin: "192.168.0.64", SubnetMax: "192.168.0.192", Backend: flannelBackend{Type: "vxlan"}},
+			3,
+		},
+		{
+			flannelConfig{network: "10.0.0.0/8", subnetLen: 20, subnetMin: "10.16.0.0", subnetMax: "10.31.240.0", backend: flannelBackend{Type: "host-gw"}},
+			flannelNetworkConfig{Network: "10.0.0.0/8", SubnetLen: 20, SubnetMin: "10.16.0.0", SubnetMax: "10.31.240.0", Backend: flannelBackend{Type: "host-gw"}},
+			256,
+		},
+	}
+	for i, tc := range cases {
+		nc, err := tc.c.resolve()
+		if err != nil {
+			t.Errorf("%d: %s", i, err)
+			continue
+		}
+		if *nc != tc.want {
+			t.Errorf("%d: got %+v, want %+v", i, nc, tc.want)
+		}
+		if n := nc.subnetCount(); n != tc.n {
+			t.Errorf("%d: %d subnets, want %d", i, n, tc.n)
+		}
+	}
+}
+
+func TestFlannelValidate(t *testing.T) {
+	cases := []struct {
+		c   flannelConfig
+		err string
+	}{
+		{flannelConfig{network: "10.1.0.0"}, "IPv4 CIDR"},
+		{flannelConfig{network: "fd00::/64"}, "IPv4 CIDR"},
+		{flannelConfig{network: "10.1.2.0/16"}, "host bits set, use 10.1.0.0/16"},
+		{flannelConfig{network: "10.1.0.0/30"}, "too small"},
+		{flannelConfig{network: "10.1.0.0/24", subnetLen: 24}, "use /26 or smaller"},
+		{flannelConfig{network: "10.1.0.0/16", subnetLen: 31}, "no room for hosts"},
+		{flannelConfig{network: "10.1.0.0/16", subnetMin: "10.2.0.0"}, "outside network"},
+		{flannelConfig{network: "10.1.0.0/16", subnetMin: "10.1.0.5"}, "not the start of a subnet, e.g. 10.1.0.0"},
+		{flannelConfig{network: "10.1.0.0/16", subnetMin: "10.1.9.0", subnetMax: "10.1.8.0"}, "above subnet_max"},
+		{flannelConfig{network: "10.1.0.0/20", minSubnets: 20}, "room for 15 /24 subnets"},
+		{flannelConfig{network: "10.1.0.0/16", backend: flannelBackend{Type: "udp"}}, "must be one of"},
+		{flannelConfig{network: "10.1.0.0/16", backend: flannelBackend{Type: "host-gw", VNI: 2}}, "only apply to the vxlan"},
+		{flannelConfig{network: "10.1.0.0/16", backend: flannelBackend{Type: "vxlan", RouteTableID: "rtb-1"}}, "only applies to the aws-vpc"},
+		{flannelConfig{network: "10.1.0.0/16", backend: flannelBackend{Type: "aws-vpc", RouteTableID: "vpc-1"}}, "rtb-"},
+		{flannelConfig{network: "10.1.0.0/16", backend: flannelBackend{Type: "vxlan", VNI: 1 << 24}}, "out of range"},
+		{flannelConfig{network: "10.1.0.0/16", etcdCertFile: "/etc/ssl/etcd/cert.pem"}, "set together"},
+		{flannelConfig{network: "10.1.0.0/16", etcdCAFile: "ca.pem"}, "absolute path"},
+	}
+	for i, tc := range cases {
+		if _, err := tc.c.resolve(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: expected %q, got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestFlannelConfigs(t *testing.T) {
+	c := &flannelConfig{
+		network:       "10.1.0.0/16",
+		backend:       flannelBackend{Type: "vxlan", VNI: 1, Port: 8472},
+		etcdEndpoints: []string{"https://10.0.0.10:2379"},
+		etcdCAFile:    "/etc/ssl/etcd/ca.pem",
+		etcdCertFile:  "/etc/ssl/etcd/cert.pem",
+		etcdKeyFile:   "/etc/ssl/etcd/key.pem",
+		iface:         "eth1",
+		version:       "0.9.0",
+	}
+	nc, err := c.resolve()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := `{"Network":"10.1.0.0/16","SubnetLen":24,"SubnetMin":"10.1.1.0","SubnetMax":"10.1.255.0","Backend":{"Type":"vxlan","VNI":1,"Port":8472}}`
+	if got := nc.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `[Service]
+Environment="FLANNEL_IMAGE_TAG=v0.9.0"
+ExecStartPre=/usr/bin/etcdctl --endpoints=https://10.0.0.10:2379 --ca-file=/etc/ssl/etcd/ca.pem --cert-file=/etc/ssl/etcd/cert.pem --key-file=/etc/ssl/etcd/key.pem set /coreos.com/network/config '` + nc.String() + `'
+ExecStart=
+ExecStart=/usr/lib/coreos/flannel-wrapper $FLANNEL_OPTS \
+  --etcd-endpoints="https://10.0.0.10:2379" \
+  --etcd-cafile="/etc/ssl/etcd/ca.pem" \
+  --etcd-certfile="/etc/ssl/etcd/cert.pem" \
+  --etcd-keyfile="/etc/ssl/etcd/key.pem" \
+  --iface="eth1"
+`
+	if got := c.dropIn(nc); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	cc, ign := c.configs(nc)
+	for _, s := range []string{"flannel:", "interface: eth1", "etcd_endpoints: https://10.0.0.10:2379", "etcd_keyfile: /etc/ssl/etcd/key.pem", "--cert-file=/etc/ssl/etcd/cert.pem", "flanneld.service", "50-network-config.conf"} {
+		if !strings.Contains(cc, s) {
+			t.Errorf("cloud-config is missing %q:\n%s", s, cc)
+		}
+	}
+	if !strings.Contains(ign, `"name":"flanneld.service","enable":true`) {
+		t.Errorf("flanneld is not enabled:\n%s", ign)
+	}
+}
@@ -0,0 +1,233 @@
+package coreos
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fleetDefaultEndpoint is the socket fleet listens on on every machine.
+const fleetDefaultEndpoint = "unix:///var/run/fleet.sock"
+
+var fleetStates = []string{"inactive", "loaded", "launched"}
+
+type (
+	fleetUnitOption struct {
+		Section string `json:"section"`
+		Name    string `json:"name"`
+		Value   string `json:"value"`
+	}
+
+	fleetUnit struct {
+		Name         string            `json:"name,omitempty"`
+		Options      []fleetUnitOption `json:"options,omitempty"`
+		DesiredState string            `json:"desiredState,omitempty"`
+		CurrentState string            `json:"currentState,omitempty"`
+		MachineID    string            `json:"machineID,omitempty"`
+	}
+
+	fleetUnitState struct {
+		Name               string `json:"name"`
+		Hash               string `json:"hash"`
+		MachineID          string `json:"machineID"`
+		SystemdLoadState   string `json:"systemdLoadState"`
+		SystemdActiveState string `json:"systemdActiveState"`
+		SystemdSubState    string `json:"systemdSubState"`
+	}
+
+	fleetMachine struct {
+		ID        string            `json:"id"`
+		PrimaryIP string            `json:"primaryIP"`
+		Metadata  map[string]string `json:"metadata"`
+	}
+
+	fleetError struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+)
+
+// fleetClient talks to the fleet v1 API over HTTP or a unix socket.
+type fleetClient struct {
+	base   string
+	client *http.Client
+}
+
+// newFleetClient accepts http(s) URLs and unix:// socket paths.
+func newFleetClient(endpoint string) (*fleetClient, error) {
+	if endpoint == "" {
+		endpoint = fleetDefaultEndpoint
+	}
+	c := &fleetClient{client: &http.Client{Timeout: 30 * time.Second}}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "unix":
+		sock := u.Path
+		c.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		}
+		// the host is ignored, every request goes to the socket
+		c.base = "http://fleet/fleet/v1"
+	case "http", "https":
+		c.base = strings.TrimSuffix(endpoint, "/") + "/fleet/v1"
+	default:
+		return nil, fmt.Errorf("fleet endpoint %q must be an http(s) URL or a unix:// socket", endpoint)
+	}
+	return c, nil
+}
+
+// do sends in as JSON and decodes the reply into out. It returns
+// found=false on a 404.
+func (c *fleetClient) do(method, resource string, in, out interface{}) (bool, error) {
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return false, err
+		}
+		body = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, c.base+resource, body)
+	if err != nil {
+		return false, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 400 {
+		var e fleetError
+		if json.Unmarshal(buf, &e) == nil && e.Error.Message != "" {
+			return false, fmt.Errorf("fleet: %s %s: %s", method, resource, e.Error.Message)
+		}
+		return false, fmt.Errorf("fleet: %s %s: %s", method, resource, resp.Status)
+	}
+	if out != nil && len(bytes.TrimSpace(buf)) > 0 {
+		return true, json.Unmarshal(buf, out)
+	}
+	return true, nil
+}
+
+// unit returns nil when fleet doesn't know the unit.
+func (c *fleetClient) unit(name string) (*fleetUnit, error) {
+	var u fleetUnit
+	found, err := c.do("GET", "/units/"+url.PathEscape(name), nil, &u)
+	if !found || err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (c *fleetClient) createUnit(u *fleetUnit) error {
+	_, err := c.do("PUT", "/units/"+url.PathEscape(u.Name), u, nil)
+	return err
+}
+
+func (c *fleetClient) setDesiredState(name, state string) error {
+	found, err := c.do("PUT", "/units/"+url.PathEscape(name), &fleetUnit{DesiredState: state}, nil)
+	if err == nil && !found {
+		return fmt.Errorf("fleet: unit %s not found", name)
+	}
+	return err
+}
+
+func (c *fleetClient) destroyUnit(name string) error {
+	_, err := c.do("DELETE", "/units/"+url.PathEscape(name), nil, nil)
+	return err
+}
+
+func (c *fleetClient) unitState(name string) (*fleetUnitState, error) {
+	var page struct {
+		States []fleetUnitState `json:"states"`
+	}
+	if _, err := c.do("GET", "/state?unitName="+url.QueryEscape(name), nil, &page); err != nil {
+		return nil, err
+	}
+	for _, s := range page.States {
+		if s.Name == name {
+			return &s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *fleetClient) machines() ([]fleetMachine, error) {
+	var machines []fleetMachine
+	token := ""
+	for {
+		var page struct {
+			Machines      []fleetMachine `json:"machines"`
+			NextPageToken string         `json:"nextPageToken"`
+		}
+		resource := "/machines"
+		if token != "" {
+			resource += "?nextPageToken=" + url.QueryEscape(token)
+		}
+		if _, err := c.do("GET", resource, nil, &page); err != nil {
+			return nil, err
+		}
+		machines = append(machines, page.Machines...)
+		if page.NextPageToken == "" {
+			return machines, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// fleetOptions turns a unit file into fleet unit options.
+func fleetOptions(f *unitFile) []fleetUnitOption {
+	var options []fleetUnitOption
+	for _, s := range f.sections {
+		for _, e := range s.entries {
+			options = append(options, fleetUnitOption{Section: s.name, Name: e.key, Value: e.value})
+		}
+	}
+	return options
+}
+
+// filterFleetMachines returns the machines whose metadata has every key
+// in filter set to its value, sorted by ID.
+func filterFleetMachines(machines []fleetMachine, filter map[string]string) []fleetMachine {
+	var out []fleetMachine
+	for _, m := range machines {
+		match := true
+		for k, v := range filter {
+			match = match && m.Metadata[k] == v
+		}
+		if match {
+			out = append(out, m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
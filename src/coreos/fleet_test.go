@@ -0,0 +1,191 @@
+package coreos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeFleet keeps units in memory and pages machines one at a time.
+type fakeFleet struct {
+	units    map[string]*fleetUnit
+	machines []fleetMachine
+}
+
+func (f *fakeFleet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/fleet/v1")
+	switch {
+	case strings.HasPrefix(p, "/units/"):
+		name := strings.TrimPrefix(p, "/units/")
+		u, ok := f.units[name]
+		switch r.Method {
+		case "GET":
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error": {"code": 404, "message": "unit does not exist"}}`)
+				return
+			}
+			json.NewEncoder(w).Encode(u)
+		case "PUT":
+			var in fleetUnit
+			json.NewDecoder(r.Body).Decode(&in)
+			if ok {
+				u.DesiredState = in.DesiredState
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if len(in.Options) == 0 {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"error": {"code": 409, "message": "unit does not exist and options field empty"}}`)
+				return
+			}
+			in.Name, in.CurrentState, in.MachineID = name, in.DesiredState, "m1"
+			f.units[name] = &in
+			w.WriteHeader(http.StatusCreated)
+		case "DELETE":
+			delete(f.units, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case p == "/state":
+		var states []fleetUnitState
+		if u, ok := f.units[r.FormValue("unitName")]; ok {
+			states = append(states, fleetUnitState{Name: u.Name, MachineID: u.MachineID, SystemdActiveState: "active"})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"states": states})
+	case p == "/machines":
+		i := 0
+		fmt.Sscan(r.FormValue("nextPageToken"), &i)
+		page := map[string]interface{}{"machines": f.machines[i : i+1]}
+		if i+1 < len(f.machines) {
+			page["nextPageToken"] = fmt.Sprint(i + 1)
+		}
+		json.NewEncoder(w).Encode(page)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestFleetClientUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fleet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "fleet.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &httptest.Server{Listener: l, Config: &http.Server{Handler: &fakeFleet{units: map[string]*fleetUnit{}}}}
+	ts.Start()
+	defer ts.Close()
+
+	c, err := newFleetClient("unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parseUnitFile("[Service]\nExecStart=/usr/bin/sleep 100\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.add("X-Fleet", "Global", "true")
+	err = c.createUnit(&fleetUnit{Name: "sleep.service", Options: fleetOptions(f), DesiredState: "loaded"})
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+
+	u, err := c.unit("sleep.service")
+	if err != nil || u == nil {
+		t.Fatalf("unit: %v, %v", u, err)
+	}
+	want := []fleetUnitOption{
+		{"Service", "ExecStart", "/usr/bin/sleep 100"},
+		{"X-Fleet", "Global", "true"},
+	}
+	if !reflect.DeepEqual(u.Options, want) || u.DesiredState != "loaded" || u.MachineID != "m1" {
+		t.Fatalf("got %+v", u)
+	}
+
+	if err := c.setDesiredState("sleep.service", "launched"); err != nil {
+		t.Fatalf("set state: %s", err)
+	}
+	if u, _ := c.unit("sleep.service"); u.DesiredState != "launched" {
+		t.Fatalf("desired state: %s", u.DesiredState)
+	}
+	s, err := c.unitState("sleep.service")
+	if err != nil || s == nil || s.SystemdActiveState != "active" {
+		t.Fatalf("state: %+v, %v", s, err)
+	}
+
+	if err := c.destroyUnit("sleep.service"); err != nil {
+		t.Fatalf("destroy: %s", err)
+	}
+	if u, err := c.unit("sleep.service"); u != nil || err != nil {
+		t.Fatalf("after destroy: %v, %v", u, err)
+	}
+	if err := c.createUnit(&fleetUnit{Name: "empty.service"}); err == nil || !strings.Contains(err.Error(), "options field empty") {
+		t.Fatalf("expected fleet's error message, got %v", err)
+	}
+}
+
+func TestFleetClientMachines(t *testing.T) {
+	machines := []fleetMachine{
+		{ID: "m1", PrimaryIP: "10.0.0.1", Metadata: map[string]string{"role": "worker"}},
+		{ID: "m2", PrimaryIP: "10.0.0.2", Metadata: map[string]string{"role": "etcd"}},
+	}
+	ts := httptest.NewServer(&fakeFleet{machines: machines})
+	defer ts.Close()
+
+	c, err := newFleetClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.machines()
+	if err != nil {
+		t.Fatalf("machines: %s", err)
+	}
+	if !reflect.DeepEqual(got, machines) {
+		t.Fatalf("got %+v", got)
+	}
+
+	if _, err := newFleetClient("tcp://10.0.0.1:49153"); err == nil {
+		t.Fatalf("expected an error for a tcp endpoint")
+	}
+}
+
+func TestFilterFleetMachines(t *testing.T) {
+	machines := []fleetMachine{
+		{ID: "m2", Metadata: map[string]string{"role": "web", "az": "a"}},
+		{ID: "m1", Metadata: map[string]string{"role": "web", "az": "b"}},
+		{ID: "m3", Metadata: map[string]string{"role": "db", "az": "a"}},
+	}
+	ids := func(ms []fleetMachine) string {
+		var s []string
+		for _, m := range ms {
+			s = append(s, m.ID)
+		}
+		return strings.Join(s, ",")
+	}
+
+	if got := ids(filterFleetMachines(machines, nil)); got != "m1,m2,m3" {
+		t.Fatalf("no filter: %s", got)
+	}
+	if got := ids(filterFleetMachines(machines, map[string]string{"role": "web"})); got != "m1,m2" {
+		t.Fatalf("role=web: %s", got)
+	}
+	if got := ids(filterFleetMachines(machines, map[string]string{"role": "web", "az": "a"})); got != "m2" {
+		t.Fatalf("role=web,az=a: %s", got)
+	}
+	if got := ids(filterFleetMachines(machines, map[string]string{"role": "cache"})); got != "" {
+		t.Fatalf("role=cache: %s", got)
+	}
+}
@@ -0,0 +1,160 @@
+package coreos
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// ignitionVersion is the Ignition spec version emitted for Container Linux.
+const ignitionVersion = "2.1.0"
+
+type (
+	ignitionConfig struct {
+		Ignition ignitionHeader  `json:"ignition"`
+		Storage  ignitionStorage `json:"storage"`
+		Systemd  ignitionSystemd `json:"systemd"`
+		Networkd ignitionSystemd `json:"networkd"`
+		Passwd   ignitionPasswd  `json:"passwd"`
+	}
+
+	ignitionHeader struct {
+		Version string `json:"version"`
+	}
+
+	ignitionStorage struct {
+		Files       []ignitionFile      `json:"files,omitempty"`
+		Directories []ignitionDirectory `json:"directories,omitempty"`
+		Links       []ignitionLink      `json:"links,omitempty"`
+	}
+
+	ignitionFile struct {
+		Filesystem string               `json:"filesystem"`
+		Path       string               `json:"path"`
+		Contents   ignitionFileContents `json:"contents"`
+		Mode       int                  `json:"mode,omitempty"`
+		User       *ignitionNode        `json:"user,omitempty"`
+		Group      *ignitionNode        `json:"group,omitempty"`
+	}
+
+	ignitionFileContents struct {
+		Source       string                `json:"source"`
+		Verification *ignitionVerification `json:"verification,omitempty"`
+	}
+
+	ignitionVerification struct {
+		Hash string `json:"hash,omitempty"`
+	}
+
+	ignitionDirectory struct {
+		Filesystem string        `json:"filesystem"`
+		Path       string        `json:"path"`
+		Mode       int           `json:"mode,omitempty"`
+		User       *ignitionNode `json:"user,omitempty"`
+		Group      *ignitionNode `json:"group,omitempty"`
+	}
+
+	ignitionLink struct {
+		Filesystem string `json:"filesystem"`
+		Path       string `json:"path"`
+		Target     string `json:"target"`
+		Hard       bool   `json:"hard,omitempty"`
+	}
+
+	ignitionNode struct {
+		ID   *int   `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	}
+
+	ignitionSystemd struct {
+		Units []ignitionUnit `json:"units,omitempty"`
+	}
+
+	ignitionUnit struct {
+		Name     string           `json:"name"`
+		Enable   bool             `json:"enable,omitempty"`
+		Mask     bool             `json:"mask,omitempty"`
+		Contents string           `json:"contents,omitempty"`
+		Dropins  []ignitionDropin `json:"dropins,omitempty"`
+	}
+
+	ignitionDropin struct {
+		Name     string `json:"name"`
+		Contents string `json:"contents"`
+	}
+
+	ignitionPasswd struct {
+		Users []ignitionUser `json:"users,omitempty"`
+	}
+
+	ignitionUser struct {
+		Name              string   `json:"name"`
+		PasswordHash      string   `json:"passwordHash,omitempty"`
+		SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
+		UID               *int     `json:"uid,omitempty"`
+		Gecos             string   `json:"gecos,omitempty"`
+		HomeDir           string   `json:"homeDir,omitempty"`
+		NoCreateHome      bool     `json:"noCreateHome,omitempty"`
+		PrimaryGroup      string   `json:"primaryGroup,omitempty"`
+		Groups            []string `json:"groups,omitempty"`
+		NoUserGroup       bool     `json:"noUserGroup,omitempty"`
+		System            bool     `json:"system,omitempty"`
+		NoLogInit         bool     `json:"noLogInit,omitempty"`
+		Shell             string   `json:"shell,omitempty"`
+	}
+)
+
+func newIgnitionConfig() *ignitionConfig {
+	return &ignitionConfig{
+		Ignition: ignitionHeader{Version: ignitionVersion},
+	}
+}
+
+// unit returns the unit with the given name, adding an empty one if the
+// config does not have it yet.
+func (c *ignitionConfig) unit(name string) *ignitionUnit {
+	for i := range c.Systemd.Units {
+		if c.Systemd.Units[i].Name == name {
+			return &c.Systemd.Units[i]
+		}
+	}
+	c.Systemd.Units = append(c.Systemd.Units, ignitionUnit{Name: name})
+	return &c.Systemd.Units[len(c.Systemd.Units)-1]
+}
+
+// user returns the user with the given name, adding an empty one if the
+// config does not have it yet.
+func (c *ignitionConfig) user(name string) *ignitionUser {
+	for i := range c.Passwd.Users {
+		if c.Passwd.Users[i].Name == name {
+			return &c.Passwd.Users[i]
+		}
+	}
+	c.Passwd.Users = append(c.Passwd.Users, ignitionUser{Name: name})
+	return &c.Passwd.Users[len(c.Passwd.Users)-1]
+}
+
+func (c *ignitionConfig) addFile(path, contents string, mode int) *ignitionFile {
+	c.Storage.Files = append(c.Storage.Files, ignitionFile{
+		Filesystem: "root",
+		Path:       path,
+		Contents:   ignitionFileContents{Source: dataURL(contents)},
+		Mode:       mode,
+	})
+	return &c.Storage.Files[len(c.Storage.Files)-1]
+}
+
+func (c *ignitionConfig) String() string {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		// every field is a plain string, int or bool
+		panic(err)
+	}
+	return string(buf)
+}
+
+// dataURL encodes contents as an RFC 2397 data URL, the form Ignition
+// expects for inline file contents.
+func dataURL(contents string) string {
+	return "data:," + strings.Replace(url.QueryEscape(contents), "+", "%20", -1)
+}
@@ -0,0 +1,125 @@
+package coreos
+
+import "encoding/json"
+
+// Ignition spec 3 is what Fedora CoreOS consumes. It is not compatible with
+// the spec 2 configs Container Linux reads, so it is modelled separately.
+type (
+	ign3Config struct {
+		Ignition ign3Header  `json:"ignition"`
+		Storage  ign3Storage `json:"storage"`
+		Systemd  ign3Systemd `json:"systemd"`
+		Passwd   ign3Passwd  `json:"passwd"`
+	}
+
+	ign3Header struct {
+		Version string          `json:"version"`
+		Config  *ign3ConfigRefs `json:"config,omitempty"`
+	}
+
+	ign3ConfigRefs struct {
+		Merge   []ign3Resource `json:"merge,omitempty"`
+		Replace *ign3Resource  `json:"replace,omitempty"`
+	}
+
+	ign3Resource struct {
+		Source       string            `json:"source"`
+		Verification *ign3Verification `json:"verification,omitempty"`
+	}
+
+	ign3Verification struct {
+		Hash string `json:"hash"`
+	}
+
+	ign3Storage struct {
+		Files       []ign3File      `json:"files,omitempty"`
+		Directories []ign3Directory `json:"directories,omitempty"`
+		Links       []ign3Link      `json:"links,omitempty"`
+	}
+
+	ign3File struct {
+		Path      string         `json:"path"`
+		Overwrite *bool          `json:"overwrite,omitempty"`
+		Contents  *ign3Resource  `json:"contents,omitempty"`
+		Append    []ign3Resource `json:"append,omitempty"`
+		Mode      *int           `json:"mode,omitempty"`
+		User      *ign3Node      `json:"user,omitempty"`
+		Group     *ign3Node      `json:"group,omitempty"`
+	}
+
+	ign3Directory struct {
+		Path      string    `json:"path"`
+		Overwrite *bool     `json:"overwrite,omitempty"`
+		Mode      *int      `json:"mode,omitempty"`
+		User      *ign3Node `json:"user,omitempty"`
+		Group     *ign3Node `json:"group,omitempty"`
+	}
+
+	ign3Link struct {
+		Path      string    `json:"path"`
+		Overwrite *bool     `json:"overwrite,omitempty"`
+		Target    string    `json:"target"`
+		Hard      bool      `json:"hard,omitempty"`
+		User      *ign3Node `json:"user,omitempty"`
+		Group     *ign3Node `json:"group,omitempty"`
+	}
+
+	ign3Node struct {
+		ID   *int   `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	}
+
+	ign3Systemd struct {
+		Units []ign3Unit `json:"units,omitempty"`
+	}
+
+	ign3Unit struct {
+		Name     string       `json:"name"`
+		Enabled  *bool        `json:"enabled,omitempty"`
+		Mask     bool         `json:"mask,omitempty"`
+		Contents string       `json:"contents,omitempty"`
+		Dropins  []ign3Dropin `json:"dropins,omitempty"`
+	}
+
+	ign3Dropin struct {
+		Name     string `json:"name"`
+		Contents string `json:"contents,omitempty"`
+	}
+
+	ign3Passwd struct {
+		Users  []ign3User  `json:"users,omitempty"`
+		Groups []ign3Group `json:"groups,omitempty"`
+	}
+
+	ign3User struct {
+		Name              string   `json:"name"`
+		PasswordHash      string   `json:"passwordHash,omitempty"`
+		SSHAuthorizedKeys []string `json:"sshAuthorizedKeys,omitempty"`
+		UID               *int     `json:"uid,omitempty"`
+		Gecos             string   `json:"gecos,omitempty"`
+		HomeDir           string   `json:"homeDir,omitempty"`
+		NoCreateHome      bool     `json:"noCreateHome,omitempty"`
+		PrimaryGroup      string   `json:"primaryGroup,omitempty"`
+		Groups            []string `json:"groups,omitempty"`
+		NoUserGroup       bool     `json:"noUserGroup,omitempty"`
+		System            bool     `json:"system,omitempty"`
+		NoLogInit         bool     `json:"noLogInit,omitempty"`
+		Shell             string   `json:"shell,omitempty"`
+	}
+
+	ign3Group struct {
+		Name         string `json:"name"`
+		Gid          *int   `json:"gid,omitempty"`
+		PasswordHash string `json:"passwordHash,omitempty"`
+		System       bool   `json:"system,omitempty"`
+	}
+)
+
+func (c *ign3Config) String() string {
+	buf, err := json.Marshal(c)
+	if err != nil {
+		// every field is a plain string, int or bool
+		panic(err)
+	}
+	return string(buf)
+}
@@ -0,0 +1,180 @@
+package coreos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// locksmithKeyPrefix is where locksmith keeps its reboot semaphores.
+const locksmithKeyPrefix = "/coreos.com/updateengine/rebootlock"
+
+// locksmithConfig holds the locksmithd settings passed through its
+// environment. endpoints is a comma separated list.
+type locksmithConfig struct {
+	windowStart  string
+	windowLength string
+	group        string
+	endpoints    string
+	caFile       string
+	certFile     string
+	keyFile      string
+}
+
+// dropIn renders a locksmithd.service drop-in setting the non-empty
+// values, or nothing when all are empty.
+func (l *locksmithConfig) dropIn() string {
+	env := []struct{ name, value string }{
+		{"LOCKSMITHD_REBOOT_WINDOW_START", l.windowStart},
+		{"LOCKSMITHD_REBOOT_WINDOW_LENGTH", l.windowLength},
+		{"LOCKSMITHD_GROUP", l.group},
+		{"LOCKSMITHD_ENDPOINT", l.endpoints},
+		{"LOCKSMITHD_ETCD_CAFILE", l.caFile},
+		{"LOCKSMITHD_ETCD_CERTFILE", l.certFile},
+		{"LOCKSMITHD_ETCD_KEYFILE", l.keyFile},
+	}
+	var buf bytes.Buffer
+	for _, e := range env {
+		if e.value != "" {
+			fmt.Fprintf(&buf, "Environment=\"%s=%s\"\n", e.name, e.value)
+		}
+	}
+	if buf.Len() == 0 {
+		return ""
+	}
+	return "[Service]\n" + buf.String()
+}
+
+// locksmithSemaphore is the JSON document locksmith stores. Semaphore is
+// the number of locks still available.
+type locksmithSemaphore struct {
+	Index     uint64   `json:"-"`
+	Semaphore int      `json:"semaphore"`
+	Max       int      `json:"max"`
+	Holders   []string `json:"holders"`
+}
+
+// locksmithSemaphoreKey returns the key of the default semaphore, or of a
+// group's.
+func locksmithSemaphoreKey(group string) string {
+	if group == "" {
+		return locksmithKeyPrefix + "/semaphore"
+	}
+	return locksmithKeyPrefix + "/groups/" + group + "/semaphore"
+}
+
+// setMax changes the number of holders allowed, keeping the current ones.
+func (s *locksmithSemaphore) setMax(max int) {
+	s.Semaphore += max - s.Max
+	s.Max = max
+}
+
+// release drops the given holders and returns the ones that held a lock.
+func (s *locksmithSemaphore) release(holders []string) []string {
+	var released []string
+	kept := make([]string, 0, len(s.Holders))
+	for _, h := range s.Holders {
+		if oneOf(h, holders) {
+			released = append(released, h)
+			s.Semaphore++
+		} else {
+			kept = append(kept, h)
+		}
+	}
+	s.Holders = kept
+	return released
+}
+
+// locksmithSemaphore returns nil when the semaphore doesn't exist yet.
+func (c *etcdClient) locksmithSemaphore(key string) (*locksmithSemaphore, error) {
+	n, err := c.get(key, false)
+	if isEtcdError(err, etcdErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s locksmithSemaphore
+	if err := json.Unmarshal([]byte(n.Value), &s); err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+	if s.Holders == nil {
+		s.Holders = []string{}
+	}
+	s.Index = n.ModifiedIndex
+	return &s, nil
+}
+
+// updateLocksmithSemaphore applies change to the semaphore, creating it
+// with a max of 1 as locksmith would when it is missing. The write is a
+// compare-and-swap and is retried when a machine takes or releases a lock
+// in the meantime.
+func (c *etcdClient) updateLocksmithSemaphore(key string, change func(*locksmithSemaphore)) (*locksmithSemaphore, error) {
+	for attempt := 0; ; attempt++ {
+		s, err := c.locksmithSemaphore(key)
+		if err != nil {
+			return nil, err
+		}
+		prevExist := "true"
+		if s == nil {
+			s = &locksmithSemaphore{Semaphore: 1, Max: 1, Holders: []string{}}
+			prevExist = "false"
+		}
+		change(s)
+
+		buf, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		n, err := c.set(key, string(buf), 0, s.Index, prevExist)
+		if (isEtcdError(err, etcdErrTestFailed) || isEtcdError(err, etcdErrNodeExist)) && attempt < 5 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.Index = n.ModifiedIndex
+		return s, nil
+	}
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// checkRebootWindow validates a locksmith window-start and window-length
+// pair. The start is "hh:mm", daily, or "Day hh:mm", weekly, and the
+// window must be shorter than its period.
+func checkRebootWindow(start, length string) error {
+	if start == "" && length == "" {
+		return nil
+	}
+	if start == "" || length == "" {
+		return fmt.Errorf("window_start and window_length must be set together")
+	}
+
+	fields := strings.Fields(start)
+	period := 24 * time.Hour
+	switch len(fields) {
+	case 1:
+	case 2:
+		if !oneOf(strings.ToLower(fields[0]), weekdays) {
+			return fmt.Errorf("window_start %q: unknown day %q, use Sun to Sat", start, fields[0])
+		}
+		period = 7 * 24 * time.Hour
+	default:
+		return fmt.Errorf("window_start %q must be \"hh:mm\" or \"Day hh:mm\"", start)
+	}
+	if _, err := time.Parse("15:04", fields[len(fields)-1]); err != nil {
+		return fmt.Errorf("window_start %q: invalid time of day %q", start, fields[len(fields)-1])
+	}
+
+	d, err := time.ParseDuration(length)
+	if err != nil {
+		return fmt.Errorf("window_length %q: %s", length, err)
+	}
+	if d <= 0 || d >= period {
+		return fmt.Errorf("window_length %s must be positive and shorter than the window's period of %s", length, period)
+	}
+	return nil
+}
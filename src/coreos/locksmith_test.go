@@ -0,0 +1,83 @@
+package coreos
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLocksmithSemaphore(t *testing.T) {
+	ts := httptest.NewServer(newFakeEtcd())
+	defer ts.Close()
+	c, err := newEtcdClient([]string{ts.URL}, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := locksmithSemaphoreKey("")
+
+	if s, err := c.locksmithSemaphore(key); s != nil || err != nil {
+		t.Fatalf("missing semaphore: %v, %v", s, err)
+	}
+
+	// locksmith takes a lock
+	if _, err := c.set(key, `{"semaphore":0,"max":1,"holders":["m1"]}`, 0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := c.updateLocksmithSemaphore(key, func(s *locksmithSemaphore) { s.setMax(3) })
+	if err != nil {
+		t.Fatalf("set max: %s", err)
+	}
+	if s.Max != 3 || s.Semaphore != 2 || !reflect.DeepEqual(s.Holders, []string{"m1"}) {
+		t.Fatalf("after set max: %+v", s)
+	}
+
+	s, err = c.updateLocksmithSemaphore(key, func(s *locksmithSemaphore) {
+		if got := s.release([]string{"m1", "m9"}); !reflect.DeepEqual(got, []string{"m1"}) {
+			t.Errorf("released %v", got)
+		}
+	})
+	if err != nil {
+		t.Fatalf("release: %s", err)
+	}
+	s, err = c.locksmithSemaphore(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Max != 3 || s.Semaphore != 3 || len(s.Holders) != 0 {
+		t.Fatalf("after release: %+v", s)
+	}
+
+	if got := locksmithSemaphoreKey("workers"); got != "/coreos.com/updateengine/rebootlock/groups/workers/semaphore" {
+		t.Fatalf("group key: %s", got)
+	}
+}
+
+func TestCheckRebootWindow(t *testing.T) {
+	cases := []struct {
+		start, length string
+		err           string
+	}{
+		{"", "", ""},
+		{"04:00", "1h", ""},
+		{"Thu 04:00", "30h", ""},
+		{"thu 23:30", "1h30m", ""},
+		{"04:00", "", "set together"},
+		{"Thursday 04:00", "1h", "unknown day"},
+		{"4am", "1h", "invalid time of day"},
+		{"25:00", "1h", "invalid time of day"},
+		{"04:00", "an hour", "window_length"},
+		{"04:00", "24h", "shorter than the window's period"},
+		{"Mon 04:00", "168h", "shorter than the window's period"},
+	}
+	for _, tc := range cases {
+		err := checkRebootWindow(tc.start, tc.length)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%q %q: %s", tc.start, tc.length, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%q %q: got %v, want %q", tc.start, tc.length, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package coreos
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+type (
+	networkdMatch struct {
+		name       string
+		macAddress string
+		driver     string
+		typ        string
+		path       string
+	}
+
+	networkdNetwork struct {
+		name    string
+		match   networkdMatch
+		dhcp    string
+		address []string
+		gateway []string
+		dns     []string
+		domains []string
+		ntp     []string
+		vlan    []string
+		bond    string
+		bridge  string
+		mtu     int
+	}
+
+	networkdNetdev struct {
+		name       string
+		kind       string
+		mtu        int
+		macAddress string
+		vlanID     int
+		bondMode   string
+		miimon     string
+	}
+
+	networkdLink struct {
+		name       string
+		match      networkdMatch
+		linkName   string
+		mtu        int
+		macAddress string
+	}
+
+	// networkdConfig is a set of .network, .netdev and .link files.
+	networkdConfig struct {
+		networks []networkdNetwork
+		netdevs  []networkdNetdev
+		links    []networkdLink
+	}
+)
+
+var (
+	networkdDHCP      = []string{"", "yes", "no", "ipv4", "ipv6"}
+	networkdKinds     = []string{"bond", "bridge", "vlan"}
+	networkdBondModes = []string{"", "balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}
+)
+
+func oneOf(v string, allowed []string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *networkdConfig) validate() error {
+	netdevs := make(map[string]string)
+	for _, nd := range c.netdevs {
+		if nd.name == "" {
+			return fmt.Errorf("netdev is missing a name")
+		}
+		if _, ok := netdevs[nd.name]; ok {
+			return fmt.Errorf("netdev %s is defined twice", nd.name)
+		}
+		if !oneOf(nd.kind, networkdKinds) {
+			return fmt.Errorf("netdev %s: kind must be one of %s", nd.name, strings.Join(networkdKinds, ", "))
+		}
+		if nd.kind == "vlan" && (nd.vlanID < 1 || nd.vlanID > 4094) {
+			return fmt.Errorf("netdev %s: vlan_id must be between 1 and 4094", nd.name)
+		}
+		if nd.kind != "vlan" && nd.vlanID != 0 {
+			return fmt.Errorf("netdev %s: vlan_id is only valid for vlan netdevs", nd.name)
+		}
+		if !oneOf(nd.bondMode, networkdBondModes) {
+			return fmt.Errorf("netdev %s: unknown bond_mode %q", nd.name, nd.bondMode)
+		}
+		if nd.kind != "bond" && (nd.bondMode != "" || nd.miimon != "") {
+			return fmt.Errorf("netdev %s: bond settings are only valid for bond netdevs", nd.name)
+		}
+		if err := checkMTU(nd.mtu); err != nil {
+			return fmt.Errorf("netdev %s: %s", nd.name, err)
+		}
+		if err := checkMAC(nd.macAddress); err != nil {
+			return fmt.Errorf("netdev %s: %s", nd.name, err)
+		}
+		netdevs[nd.name] = nd.kind
+	}
+
+	// files are named after the units, so names can't repeat within a kind
+	networks := make(map[string]bool)
+	for _, n := range c.networks {
+		if err := n.validate(netdevs); err != nil {
+			return fmt.Errorf("network %s: %s", n.name, err)
+		}
+		if networks[n.name] {
+			return fmt.Errorf("network %s is defined twice", n.name)
+		}
+		networks[n.name] = true
+	}
+
+	links := make(map[string]bool)
+	for _, l := range c.links {
+		if l.name == "" {
+			return fmt.Errorf("link is missing a name")
+		}
+		if links[l.name] {
+			return fmt.Errorf("link %s is defined twice", l.name)
+		}
+		links[l.name] = true
+		if l.match == (networkdMatch{}) {
+			return fmt.Errorf("link %s: needs a match", l.name)
+		}
+		if err := checkMTU(l.mtu); err != nil {
+			return fmt.Errorf("link %s: %s", l.name, err)
+		}
+		if err := checkMAC(l.macAddress); err != nil {
+			return fmt.Errorf("link %s: %s", l.name, err)
+		}
+	}
+	return nil
+}
+
+func (n *networkdNetwork) validate(netdevs map[string]string) error {
+	if n.name == "" {
+		return fmt.Errorf("network is missing a name")
+	}
+	if n.match == (networkdMatch{}) {
+		return fmt.Errorf("needs a match")
+	}
+	if !oneOf(n.dhcp, networkdDHCP) {
+		return fmt.Errorf("dhcp must be yes, no, ipv4 or ipv6")
+	}
+	if err := checkMTU(n.mtu); err != nil {
+		return err
+	}
+	if err := checkMAC(n.match.macAddress); err != nil {
+		return err
+	}
+
+	var nets []*net.IPNet
+	for _, a := range n.address {
+		ip, ipnet, err := net.ParseCIDR(a)
+		if err != nil {
+			return fmt.Errorf("address %q is not in CIDR notation", a)
+		}
+		ones, bits := ipnet.Mask.Size()
+		if ip.Equal(ipnet.IP) && ones < bits-1 {
+			return fmt.Errorf("address %s is the network address of its prefix", a)
+		}
+		nets = append(nets, ipnet)
+	}
+
+	for _, g := range n.gateway {
+		gw := net.ParseIP(g)
+		if gw == nil {
+			return fmt.Errorf("gateway %q is not an IP address", g)
+		}
+		reachable := false
+		for _, ipnet := range nets {
+			reachable = reachable || ipnet.Contains(gw)
+		}
+		if !reachable {
+			return fmt.Errorf("gateway %s is not within any address prefix", g)
+		}
+	}
+
+	for _, s := range n.dns {
+		if net.ParseIP(s) == nil {
+			return fmt.Errorf("dns server %q is not an IP address", s)
+		}
+	}
+
+	attached := map[string][]string{"vlan": n.vlan}
+	if n.bond != "" {
+		attached["bond"] = []string{n.bond}
+	}
+	if n.bridge != "" {
+		attached["bridge"] = []string{n.bridge}
+	}
+	for kind, names := range attached {
+		for _, name := range names {
+			if k, ok := netdevs[name]; ok && k != kind {
+				return fmt.Errorf("%s %s is a %s netdev", kind, name, k)
+			}
+		}
+	}
+	return nil
+}
+
+func checkMTU(mtu int) error {
+	if mtu != 0 && (mtu < 68 || mtu > 65535) {
+		return fmt.Errorf("mtu %d is out of range", mtu)
+	}
+	return nil
+}
+
+func checkMAC(mac string) error {
+	if mac == "" {
+		return nil
+	}
+	if _, err := net.ParseMAC(mac); err != nil {
+		return fmt.Errorf("invalid mac address %q", mac)
+	}
+	return nil
+}
+
+func (m networkdMatch) add(f *unitFile) {
+	fields := []struct{ key, value string }{
+		{"Name", m.name},
+		{"MACAddress", m.macAddress},
+		{"Driver", m.driver},
+		{"Type", m.typ},
+		{"Path", m.path},
+	}
+	for _, kv := range fields {
+		if kv.value != "" {
+			f.add("Match", kv.key, kv.value)
+		}
+	}
+}
+
+func (n *networkdNetwork) render() *unitFile {
+	f := &unitFile{}
+	n.match.add(f)
+	if n.mtu != 0 {
+		f.add("Link", "MTUBytes", fmt.Sprint(n.mtu))
+	}
+
+	add := func(key string, values ...string) {
+		for _, v := range values {
+			if v != "" {
+				f.add("Network", key, v)
+			}
+		}
+	}
+	add("DHCP", n.dhcp)
+	add("Address", n.address...)
+	add("Gateway", n.gateway...)
+	add("DNS", n.dns...)
+	if len(n.domains) > 0 {
+		add("Domains", strings.Join(n.domains, " "))
+	}
+	add("NTP", n.ntp...)
+	add("VLAN", n.vlan...)
+	add("Bond", n.bond)
+	add("Bridge", n.bridge)
+	return f
+}
+
+func (nd *networkdNetdev) render() *unitFile {
+	f := &unitFile{}
+	f.add("NetDev", "Name", nd.name)
+	f.add("NetDev", "Kind", nd.kind)
+	if nd.mtu != 0 {
+		f.add("NetDev", "MTUBytes", fmt.Sprint(nd.mtu))
+	}
+	if nd.macAddress != "" {
+		f.add("NetDev", "MACAddress", nd.macAddress)
+	}
+	switch nd.kind {
+	case "vlan":
+		f.add("VLAN", "Id", fmt.Sprint(nd.vlanID))
+	case "bond":
+		if nd.bondMode != "" {
+			f.add("Bond", "Mode", nd.bondMode)
+		}
+		if nd.miimon != "" {
+			f.add("Bond", "MIIMonitorSec", nd.miimon)
+		}
+	}
+	return f
+}
+
+func (l *networkdLink) render() *unitFile {
+	f := &unitFile{}
+	l.match.add(f)
+	if l.linkName != "" {
+		f.add("Link", "Name", l.linkName)
+	}
+	if l.mtu != 0 {
+		f.add("Link", "MTUBytes", fmt.Sprint(l.mtu))
+	}
+	if l.macAddress != "" {
+		f.add("Link", "MACAddress", l.macAddress)
+	}
+	return f
+}
+
+// files returns the rendered units keyed by file name.
+func (c *networkdConfig) files() map[string]string {
+	files := make(map[string]string)
+	for _, n := range c.networks {
+		files[n.name+".network"] = n.render().String()
+	}
+	for _, nd := range c.netdevs {
+		files[nd.name+".netdev"] = nd.render().String()
+	}
+	for _, l := range c.links {
+		files[l.name+".link"] = l.render().String()
+	}
+	return files
+}
+
+// configs renders the files as coreos.units entries and as Ignition
+// networkd units.
+func (c *networkdConfig) configs() (string, string) {
+	files := c.files()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cc := &cloudConfig{}
+	ign := newIgnitionConfig()
+	for _, name := range names {
+		cc.CoreOS.Units = append(cc.CoreOS.Units, cloudConfigUnit{Name: name, Content: files[name]})
+		ign.Networkd.Units = append(ign.Networkd.Units, ignitionUnit{Name: name, Contents: files[name]})
+	}
+	return cc.String(), ign.String()
+}
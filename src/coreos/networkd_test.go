@@ -0,0 +1,146 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNetworkdConfigRender(t *testing.T) {
+	c := &networkdConfig{
+		networks: []networkdNetwork{{
+			name:    "10-eth0",
+			match:   networkdMatch{name: "eth0"},
+			address: []string{"10.0.0.5/24"},
+			gateway: []string{"10.0.0.1"},
+			dns:     []string{"8.8.8.8", "8.8.4.4"},
+			domains: []string{"example.com", "internal"},
+			vlan:    []string{"vlan100"},
+			mtu:     9000,
+		}},
+		netdevs: []networkdNetdev{{
+			name:   "vlan100",
+			kind:   "vlan",
+			vlanID: 100,
+		}},
+	}
+	if err := c.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files := c.files()
+	want := `[Match]
+Name=eth0
+
+[Link]
+MTUBytes=9000
+
+[Network]
+Address=10.0.0.5/24
+Gateway=10.0.0.1
+DNS=8.8.8.8
+DNS=8.8.4.4
+Domains=example.com internal
+VLAN=vlan100
+`
+	if got := files["10-eth0.network"]; got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = `[NetDev]
+Name=vlan100
+Kind=vlan
+
+[VLAN]
+Id=100
+`
+	if got := files["vlan100.netdev"]; got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	cc, ign := c.configs()
+	if !strings.Contains(cc, "name: 10-eth0.network") {
+		t.Fatalf("cloud-config is missing the network unit:\n%s", cc)
+	}
+	if !strings.Contains(ign, `"networkd":{"units":[{"name":"10-eth0.network"`) {
+		t.Fatalf("ignition is missing the networkd unit:\n%s", ign)
+	}
+}
+
+func TestNetworkdConfigValidate(t *testing.T) {
+	cases := []struct {
+		network networkdNetwork
+		netdevs []networkdNetdev
+		err     string
+	}{
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}, address: []string{"10.0.0.5"}},
+			err:     "not in CIDR notation",
+		},
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}, address: []string{"10.0.0.5/24"}, gateway: []string{"10.0.1.1"}},
+			err:     "gateway 10.0.1.1 is not within any address prefix",
+		},
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}, address: []string{"2001:db8::5/64"}, gateway: []string{"2001:db8::1"}},
+		},
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}, address: []string{"10.0.0.0/24"}},
+			err:     "network address",
+		},
+		{
+			network: networkdNetwork{name: "a", address: []string{"10.0.0.5/24"}},
+			err:     "needs a match",
+		},
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}, dns: []string{"dns.example.com"}},
+			err:     "not an IP address",
+		},
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}, bond: "br0"},
+			netdevs: []networkdNetdev{{name: "br0", kind: "bridge"}},
+			err:     "bond br0 is a bridge netdev",
+		},
+		{
+			network: networkdNetwork{name: "a", match: networkdMatch{name: "eth0"}},
+			netdevs: []networkdNetdev{{name: "v", kind: "vlan", vlanID: 5000}},
+			err:     "vlan_id must be between 1 and 4094",
+		},
+	}
+
+	for i, tc := range cases {
+		c := &networkdConfig{networks: []networkdNetwork{tc.network}, netdevs: tc.netdevs}
+		err := c.validate()
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%d: err: %s", i, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%d: got %v, want %q", i, err, tc.err)
+		}
+	}
+}
+
+func TestNetworkdConfigDuplicateNames(t *testing.T) {
+	match := networkdMatch{name: "eth0"}
+	cases := []struct {
+		c   networkdConfig
+		err string
+	}{
+		{networkdConfig{networks: []networkdNetwork{{name: "a", match: match}, {name: "a", match: match}}}, "network a is defined twice"},
+		{networkdConfig{netdevs: []networkdNetdev{{name: "br0", kind: "bridge"}, {name: "br0", kind: "bridge"}}}, "netdev br0 is defined twice"},
+		{networkdConfig{links: []networkdLink{{name: "l", match: match}, {name: "l", match: match}}}, "link l is defined twice"},
+	}
+	for i, tc := range cases {
+		if err := tc.c.validate(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: got %v, want %q", i, err, tc.err)
+		}
+	}
+
+	// a network may share its name with a netdev, the suffixes differ
+	c := &networkdConfig{
+		networks: []networkdNetwork{{name: "br0", match: networkdMatch{name: "br0"}}},
+		netdevs:  []networkdNetdev{{name: "br0", kind: "bridge"}},
+	}
+	if err := c.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,198 @@
+package coreos
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// omahaAppID is the Omaha application ID of Container Linux, which Flatcar
+// kept.
+const omahaAppID = "{e96281a6-d1af-4bde-9a0a-97b76e56dc57}"
+
+// The subset of the Omaha v3 protocol update_engine speaks. The same app
+// element carries an update check or events in a request, and the result
+// in a response.
+type (
+	omahaRequest struct {
+		XMLName        xml.Name   `xml:"request"`
+		Protocol       string     `xml:"protocol,attr"`
+		Version        string     `xml:"version,attr,omitempty"`
+		UpdaterVersion string     `xml:"updaterversion,attr,omitempty"`
+		InstallSource  string     `xml:"installsource,attr,omitempty"`
+		IsMachine      int        `xml:"ismachine,attr,omitempty"`
+		OS             *omahaOS   `xml:"os"`
+		Apps           []omahaApp `xml:"app"`
+	}
+
+	omahaResponse struct {
+		XMLName  xml.Name       `xml:"response"`
+		Protocol string         `xml:"protocol,attr"`
+		Server   string         `xml:"server,attr,omitempty"`
+		DayStart *omahaDayStart `xml:"daystart"`
+		Apps     []omahaApp     `xml:"app"`
+	}
+
+	omahaOS struct {
+		Version  string `xml:"version,attr,omitempty"`
+		Platform string `xml:"platform,attr,omitempty"`
+		SP       string `xml:"sp,attr,omitempty"`
+	}
+
+	omahaDayStart struct {
+		ElapsedSeconds int `xml:"elapsed_seconds,attr"`
+	}
+
+	omahaApp struct {
+		AppID       string            `xml:"appid,attr"`
+		Version     string            `xml:"version,attr,omitempty"`
+		Track       string            `xml:"track,attr,omitempty"`
+		BootID      string            `xml:"bootid,attr,omitempty"`
+		MachineID   string            `xml:"machineid,attr,omitempty"`
+		Board       string            `xml:"board,attr,omitempty"`
+		OEM         string            `xml:"oem,attr,omitempty"`
+		Status      string            `xml:"status,attr,omitempty"`
+		UpdateCheck *omahaUpdateCheck `xml:"updatecheck"`
+		Ping        *omahaPing        `xml:"ping"`
+		Events      []omahaEvent      `xml:"event"`
+	}
+
+	omahaUpdateCheck struct {
+		Status   string         `xml:"status,attr,omitempty"`
+		URLs     []omahaURL     `xml:"urls>url"`
+		Manifest *omahaManifest `xml:"manifest"`
+	}
+
+	omahaURL struct {
+		Codebase string `xml:"codebase,attr"`
+	}
+
+	omahaManifest struct {
+		Version  string         `xml:"version,attr"`
+		Packages []omahaPackage `xml:"packages>package"`
+		Actions  []omahaAction  `xml:"actions>action"`
+	}
+
+	// omahaPackage's Hash is the base64 SHA-1 of the payload.
+	omahaPackage struct {
+		Hash     string `xml:"hash,attr"`
+		Name     string `xml:"name,attr"`
+		Size     uint64 `xml:"size,attr"`
+		Required bool   `xml:"required,attr"`
+	}
+
+	// omahaAction's SHA256 is the base64 SHA-256 of the payload, which is
+	// what update_engine verifies.
+	omahaAction struct {
+		Event                 string `xml:"event,attr"`
+		SHA256                string `xml:"sha256,attr,omitempty"`
+		NeedsAdmin            bool   `xml:"needsadmin,attr"`
+		IsDelta               bool   `xml:"IsDelta,attr"`
+		DisablePayloadBackoff bool   `xml:"DisablePayloadBackoff,attr,omitempty"`
+	}
+
+	omahaPing struct {
+		Status string `xml:"status,attr,omitempty"`
+	}
+
+	omahaEvent struct {
+		Type            int    `xml:"eventtype,attr,omitempty"`
+		Result          int    `xml:"eventresult,attr,omitempty"`
+		ErrorCode       int    `xml:"errorcode,attr,omitempty"`
+		PreviousVersion string `xml:"previousversion,attr,omitempty"`
+		Status          string `xml:"status,attr,omitempty"`
+	}
+)
+
+// omahaUpdate is the payload an update check was offered.
+type omahaUpdate struct {
+	Version string
+	URL     string
+	Size    uint64
+	// Sha256 is hex encoded.
+	Sha256 string
+}
+
+// omahaCheck sends an update check for app to server. It returns nil when
+// the server has no update to offer.
+func omahaCheck(server string, app omahaApp) (*omahaUpdate, error) {
+	app.UpdateCheck = &omahaUpdateCheck{}
+	req := &omahaRequest{
+		Protocol:       "3.0",
+		Version:        "terraform-provider-coreos",
+		UpdaterVersion: "terraform-provider-coreos",
+		InstallSource:  "ondemandupdate",
+		IsMachine:      1,
+		OS:             &omahaOS{Platform: "CoreOS"},
+		Apps:           []omahaApp{app},
+	}
+	buf, err := xml.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(server, "text/xml", bytes.NewReader(append([]byte(xml.Header), buf...)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("update check against %s: %s", server, resp.Status)
+	}
+	var r omahaResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("update check against %s: %s", server, err)
+	}
+
+	var a *omahaApp
+	for i := range r.Apps {
+		if strings.EqualFold(r.Apps[i].AppID, app.AppID) {
+			a = &r.Apps[i]
+		}
+	}
+	if a == nil {
+		return nil, fmt.Errorf("%s did not answer for app %s", server, app.AppID)
+	}
+	if a.Status != "" && a.Status != "ok" {
+		return nil, fmt.Errorf("%s rejected app %s: %s", server, app.AppID, a.Status)
+	}
+	uc := a.UpdateCheck
+	if uc == nil {
+		return nil, fmt.Errorf("%s sent no update check result", server)
+	}
+	switch uc.Status {
+	case "noupdate":
+		return nil, nil
+	case "ok":
+	default:
+		return nil, fmt.Errorf("update check against %s: %s", server, uc.Status)
+	}
+	return uc.update()
+}
+
+func (uc *omahaUpdateCheck) update() (*omahaUpdate, error) {
+	if uc.Manifest == nil || len(uc.Manifest.Packages) == 0 || len(uc.URLs) == 0 {
+		return nil, fmt.Errorf("update offered without a payload")
+	}
+	pkg := uc.Manifest.Packages[0]
+	u := &omahaUpdate{
+		Version: uc.Manifest.Version,
+		URL:     strings.TrimSuffix(uc.URLs[0].Codebase, "/") + "/" + pkg.Name,
+		Size:    pkg.Size,
+	}
+	for _, a := range uc.Manifest.Actions {
+		if a.Event != "postinstall" || a.SHA256 == "" {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(a.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload sha256 %q: %s", a.SHA256, err)
+		}
+		u.Sha256 = hex.EncodeToString(sum)
+	}
+	return u, nil
+}
@@ -0,0 +1,73 @@
+package coreos
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// omahaStandIn offers 1010.5.0 to the beta group and nothing else.
+func omahaStandIn(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req omahaRequest
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Apps) != 1 || req.Apps[0].UpdateCheck == nil {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		app := req.Apps[0]
+
+		switch {
+		case app.AppID != omahaAppID:
+			fmt.Fprintf(w, `<response protocol="3.0"><app appid="%s" status="error-unknownApplication"></app></response>`, app.AppID)
+		case app.Track == "beta" && app.Version != "1010.5.0":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<response protocol="3.0" server="stand-in">
+ <daystart elapsed_seconds="0"></daystart>
+ <app appid="%s" status="ok">
+  <updatecheck status="ok">
+   <urls><url codebase="https://update.example.com/amd64-usr/1010.5.0/"></url></urls>
+   <manifest version="1010.5.0">
+    <packages><package hash="y/3Ol/6dDZAPgqN8UsAfTfHvQAo=" name="update.gz" size="237846452" required="false"></package></packages>
+    <actions><action event="postinstall" sha256="3q2+7w==" needsadmin="false" IsDelta="false" DisablePayloadBackoff="true"></action></actions>
+   </manifest>
+  </updatecheck>
+ </app>
+</response>`, app.AppID)
+		default:
+			fmt.Fprintf(w, `<response protocol="3.0"><app appid="%s" status="ok"><updatecheck status="noupdate"></updatecheck></app></response>`, app.AppID)
+		}
+	}))
+}
+
+func TestOmahaCheck(t *testing.T) {
+	ts := omahaStandIn(t)
+	defer ts.Close()
+
+	u, err := omahaCheck(ts.URL, omahaApp{AppID: omahaAppID, Version: "899.17.0", Track: "beta"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := omahaUpdate{
+		Version: "1010.5.0",
+		URL:     "https://update.example.com/amd64-usr/1010.5.0/update.gz",
+		Size:    237846452,
+		Sha256:  "deadbeef",
+	}
+	if u == nil || *u != want {
+		t.Fatalf("got %+v, want %+v", u, want)
+	}
+
+	u, err = omahaCheck(ts.URL, omahaApp{AppID: omahaAppID, Version: "1010.5.0", Track: "beta"})
+	if err != nil || u != nil {
+		t.Fatalf("expected no update, got %+v, %v", u, err)
+	}
+
+	if _, err := omahaCheck(ts.URL, omahaApp{AppID: "{unknown}", Track: "beta"}); err == nil {
+		t.Fatal("expected an error for an unknown app")
+	}
+}
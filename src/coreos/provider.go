@@ -5,10 +5,185 @@ import (
 	"github.com/hashicorp/terraform/terraform"
 )
 
+type providerConfig struct {
+	distribution string
+
+	etcdEndpoints  []string
+	etcdCACert     string
+	etcdClientCert string
+	etcdClientKey  string
+
+	fleetEndpoint string
+
+	passwordKey string
+}
+
 func Provider() terraform.ResourceProvider {
 	return &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			"distribution": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "default distribution: coreos or flatcar",
+				Default:     defaultDistribution,
+				Optional:    true,
+			},
+			"etcd_endpoints": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "default etcd client URLs",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"etcd_ca_cert": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "default PEM CA certificate etcd is verified with",
+				Optional:    true,
+			},
+			"etcd_client_cert": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "default PEM client certificate presented to etcd",
+				Optional:    true,
+			},
+			"etcd_client_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "default PEM key of the etcd client certificate",
+				Optional:    true,
+			},
+			"fleet_endpoint": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "default fleet API URL or unix:// socket",
+				Default:     fleetDefaultEndpoint,
+				Optional:    true,
+			},
+			"password_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "secret the password stand-ins coreos_user keeps in the state are keyed with",
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("COREOS_PASSWORD_KEY", ""),
+			},
+		},
+
 		ResourcesMap: map[string]*schema.Resource{
-			"coreos_ami": resourceCoreOSAMI(),
+			"coreos_ami":                      resourceCoreOSAMI(),
+			"coreos_butane_config":            resourceCoreOSButaneConfig(),
+			"coreos_cloud_config_to_ignition": resourceCoreOSCloudConfigToIgnition(),
+			"coreos_container_linux_config":   resourceCoreOSContainerLinuxConfig(),
+			"coreos_container_unit":           resourceCoreOSContainerUnit(),
+			"coreos_coreupdate_group":         resourceCoreOSCoreUpdateGroup(),
+			"coreos_docker_config":            resourceCoreOSDockerConfig(),
+			"coreos_etcd_directory":           resourceCoreOSEtcdDirectory(),
+			"coreos_etcd_discovery":           resourceCoreOSEtcdDiscovery(),
+			"coreos_etcd_health":              resourceCoreOSEtcdHealth(),
+			"coreos_etcd_key":                 resourceCoreOSEtcdKey(),
+			"coreos_etcd_member":              resourceCoreOSEtcdMember(),
+			"coreos_etcd_static_cluster":      resourceCoreOSEtcdStaticCluster(),
+			"coreos_fcos_image":               resourceCoreOSFCOSImage(),
+			"coreos_flannel_config":           resourceCoreOSFlannelConfig(),
+			"coreos_fleet_machines":           resourceCoreOSFleetMachines(),
+			"coreos_fleet_unit":               resourceCoreOSFleetUnit(),
+			"coreos_locksmith_semaphore":      resourceCoreOSLocksmithSemaphore(),
+			"coreos_networkd_config":          resourceCoreOSNetworkdConfig(),
+			"coreos_omaha_version":            resourceCoreOSOmahaVersion(),
+			"coreos_systemd_unit":             resourceCoreOSSystemdUnit(),
+			"coreos_tls_ca":                   resourceCoreOSTLSCA(),
+			"coreos_tls_cert":                 resourceCoreOSTLSCert(),
+			"coreos_update_config":            resourceCoreOSUpdateConfig(),
+			"coreos_update_group":             resourceCoreOSUpdateGroup(),
+			"coreos_user":                     resourceCoreOSUser(),
 		},
+
+		ConfigureFunc: providerConfigure,
+	}
+}
+
+func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+	dist := d.Get("distribution").(string)
+	if _, err := getDistribution(dist); err != nil {
+		return nil, err
+	}
+	if err := setPasswordStateKey(d.Get("password_key").(string)); err != nil {
+		return nil, err
+	}
+	return &providerConfig{
+		distribution:   dist,
+		etcdEndpoints:  stringList(d.Get("etcd_endpoints")),
+		etcdCACert:     d.Get("etcd_ca_cert").(string),
+		etcdClientCert: d.Get("etcd_client_cert").(string),
+		etcdClientKey:  d.Get("etcd_client_key").(string),
+		fleetEndpoint:  d.Get("fleet_endpoint").(string),
+		passwordKey:    d.Get("password_key").(string),
+	}, nil
+}
+
+// resourceDistribution returns the distribution a resource asked for,
+// falling back to the provider default.
+func resourceDistribution(d *schema.ResourceData, meta interface{}) (*distribution, error) {
+	name := d.Get("distribution").(string)
+	if name == "" {
+		name = defaultDistribution
+		if c, ok := meta.(*providerConfig); ok {
+			name = c.distribution
+		}
+	}
+	return getDistribution(name)
+}
+
+// etcdConnectionSchema adds the attributes resources that talk to etcd
+// use to override the provider's connection settings.
+func etcdConnectionSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	s["endpoints"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Description: "etcd client URLs, defaults to the provider's",
+		Optional:    true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+	}
+	s["ca_cert"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "PEM CA certificate etcd is verified with",
+		Optional:    true,
+	}
+	s["client_cert"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "PEM client certificate presented to etcd",
+		Optional:    true,
+	}
+	s["client_key"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "PEM key of the client certificate",
+		Optional:    true,
+	}
+	return s
+}
+
+// resourceEtcdClient returns a client for the etcd a resource points at,
+// falling back to the provider's settings for anything it leaves out.
+func resourceEtcdClient(d *schema.ResourceData, meta interface{}) (*etcdClient, error) {
+	c, ok := meta.(*providerConfig)
+	if !ok {
+		c = &providerConfig{}
+	}
+
+	endpoints := stringList(d.Get("endpoints"))
+	if len(endpoints) == 0 {
+		endpoints = c.etcdEndpoints
+	}
+	get := func(key, fallback string) string {
+		if v := d.Get(key).(string); v != "" {
+			return v
+		}
+		return fallback
+	}
+	return newEtcdClient(endpoints,
+		get("ca_cert", c.etcdCACert),
+		get("client_cert", c.etcdClientCert),
+		get("client_key", c.etcdClientKey))
+}
+
+// resourceFleetClient returns a client for the fleet API a resource's
+// endpoint names, or the provider's.
+func resourceFleetClient(d *schema.ResourceData, meta interface{}) (*fleetClient, error) {
+	endpoint := d.Get("endpoint").(string)
+	if c, ok := meta.(*providerConfig); ok && endpoint == "" {
+		endpoint = c.fleetEndpoint
 	}
+	return newFleetClient(endpoint)
 }
@@ -0,0 +1,132 @@
+package coreos
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// distribution describes where a Container Linux derivative publishes its
+// releases. Flatcar keeps the CoreOS layout under its own host and file
+// prefix.
+type distribution struct {
+	name       string
+	host       string
+	prefix     string
+	versionVar string
+	channels   []string
+	// signingKey is the fingerprint of the key release artifacts are
+	// signed with.
+	signingKey string
+	// updateServer is the Omaha endpoint update_engine checks by default.
+	updateServer string
+}
+
+var distributions = map[string]*distribution{
+	"coreos": &distribution{
+		name:         "coreos",
+		host:         "http://%s.release.core-os.net",
+		prefix:       "coreos_production",
+		versionVar:   "COREOS_VERSION",
+		channels:     []string{"stable", "beta", "alpha"},
+		signingKey:   "04127D0BFABEC8871FFB2CCE50E0885593D2DCB4",
+		updateServer: "https://public.update.core-os.net/v1/update/",
+	},
+	"flatcar": &distribution{
+		name:         "flatcar",
+		host:         "https://%s.release.flatcar-linux.net",
+		prefix:       "flatcar_production",
+		versionVar:   "FLATCAR_VERSION",
+		channels:     []string{"stable", "beta", "alpha", "lts"},
+		signingKey:   "F88CFEDEFF29A5B4D9523864E25D9AED0593B34A",
+		updateServer: "https://public.update.flatcar-linux.net/v1/update/",
+	},
+}
+
+// defaultDistribution is used when neither the resource nor the provider
+// picks one.
+const defaultDistribution = "coreos"
+
+func getDistribution(name string) (*distribution, error) {
+	d, ok := distributions[name]
+	if !ok {
+		names := make([]string, 0, len(distributions))
+		for k := range distributions {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown distribution %q, must be one of: %s", name, strings.Join(names, ", "))
+	}
+	return d, nil
+}
+
+func (d *distribution) checkChannel(channel string) error {
+	for _, c := range d.channels {
+		if c == channel {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s channel %q, must be one of: %s", d.name, channel, strings.Join(d.channels, ", "))
+}
+
+// url returns the location of a file in the current release of channel.
+// Names starting with an underscore are prefixed with the distribution's
+// file prefix, so "_ami_all.json" becomes "coreos_production_ami_all.json".
+func (d *distribution) url(channel, file string) string {
+	if strings.HasPrefix(file, "_") {
+		file = d.prefix + file
+	}
+	return fmt.Sprintf(d.host, channel) + "/amd64-usr/current/" + file
+}
+
+func (d *distribution) getAMIs(channel string) (*amiInfo, error) {
+	if err := d.checkChannel(channel); err != nil {
+		return nil, err
+	}
+	url := d.url(channel, "_ami_all.json")
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	var data amiInfo
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// getVersion reads the release version from the channel's version.txt.
+func (d *distribution) getVersion(channel string) (string, error) {
+	if err := d.checkChannel(channel); err != nil {
+		return "", err
+	}
+	url := d.url(channel, "version.txt")
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+
+	s := bufio.NewScanner(resp.Body)
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), "=", 2)
+		if len(parts) == 2 && parts[0] == d.versionVar {
+			return parts[1], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s not found in %s", d.versionVar, url)
+}
@@ -0,0 +1,73 @@
+package coreos
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDistributionURL(t *testing.T) {
+	cases := []struct {
+		dist    string
+		channel string
+		file    string
+		want    string
+	}{
+		{"coreos", "stable", "_ami_all.json", "http://stable.release.core-os.net/amd64-usr/current/coreos_production_ami_all.json"},
+		{"flatcar", "lts", "_ami_all.json", "https://lts.release.flatcar-linux.net/amd64-usr/current/flatcar_production_ami_all.json"},
+		{"flatcar", "beta", "version.txt", "https://beta.release.flatcar-linux.net/amd64-usr/current/version.txt"},
+	}
+	for _, tc := range cases {
+		d, err := getDistribution(tc.dist)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if got := d.url(tc.channel, tc.file); got != tc.want {
+			t.Errorf("got %s, want %s", got, tc.want)
+		}
+	}
+
+	if _, err := getDistribution("rhel"); err == nil {
+		t.Fatal("expected error for unknown distribution")
+	}
+	if err := distributions["coreos"].checkChannel("lts"); err == nil {
+		t.Fatal("coreos has no lts channel")
+	}
+}
+
+func TestDistributionGetVersion(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/lts/amd64-usr/current/version.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "FLATCAR_BUILD=2605\nFLATCAR_VERSION=2605.12.0\nFLATCAR_SDK_VERSION=2605.11.0\n")
+	}))
+	defer ts.Close()
+
+	d := *distributions["flatcar"]
+	d.host = ts.URL + "/%s"
+
+	v, err := d.getVersion("lts")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "2605.12.0" {
+		t.Fatalf("version: %s", v)
+	}
+}
+
+func TestAMIInfoFind(t *testing.T) {
+	// Flatcar lists no pv AMIs
+	info := &amiInfo{AMIs: []ami{{Name: "us-west-2", HVM: "ami-1234"}}}
+	if id, err := info.find("us-west-2", "hvm"); err != nil || id != "ami-1234" {
+		t.Fatalf("hvm: %s %v", id, err)
+	}
+	if _, err := info.find("us-west-2", "pv"); err == nil {
+		t.Fatal("expected an error for a missing pv ami")
+	}
+	if _, err := info.find("eu-west-1", "hvm"); err == nil {
+		t.Fatal("expected an error for a missing region")
+	}
+}
@@ -1,10 +1,8 @@
 package coreos
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
@@ -51,23 +49,41 @@ func resourceCoreOSAMI() *schema.Resource {
 				Optional:    true,
 				ForceNew:    true,
 			},
+			"distribution": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "coreos or flatcar, defaults to the provider's distribution",
+				Optional:    true,
+				ForceNew:    true,
+			},
 			"ami": &schema.Schema{
 				Type:        schema.TypeString,
 				Computed:    true,
 				Description: "ami",
 			},
+			"version": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "release version",
+			},
+			"signing_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "fingerprint of the key the release is signed with",
+			},
 		},
 	}
 }
 
 func Create(d *schema.ResourceData, meta interface{}) error {
 	log.Println("[INFO] calling create")
-	ami, err := getAMI(d)
+	dist, err := resourceDistribution(d, meta)
 	if err != nil {
 		return err
 	}
-	d.Set("ami", ami)
-	d.SetId(getID(d))
+	if err := readAMI(d, dist); err != nil {
+		return err
+	}
+	d.SetId(getID(d, dist))
 	return nil
 }
 
@@ -79,55 +95,82 @@ func Delete(d *schema.ResourceData, meta interface{}) error {
 
 func Exists(d *schema.ResourceData, meta interface{}) (bool, error) {
 	log.Println("[INFO] calling exists")
-	return getID(d) == d.Id(), nil
+	dist, err := resourceDistribution(d, meta)
+	if err != nil {
+		return false, err
+	}
+	return getID(d, dist) == d.Id(), nil
 }
 
 func Read(d *schema.ResourceData, meta interface{}) error {
 	log.Println("[INFO] calling read")
-	ami, err := getAMI(d)
+	dist, err := resourceDistribution(d, meta)
 	if err != nil {
 		return err
 	}
-	d.Set("ami", ami)
-	d.SetId(getID(d))
+	if err := readAMI(d, dist); err != nil {
+		return err
+	}
+	d.SetId(getID(d, dist))
 	return nil
 }
 
-func getAMI(d *schema.ResourceData) (string, error) {
-	url := fmt.Sprintf("http://%s.release.core-os.net/amd64-usr/current/coreos_production_ami_all.json", d.Get("channel").(string))
-	resp, err := http.Get(url)
+func readAMI(d *schema.ResourceData, dist *distribution) error {
+	ami, err := getAMI(d, dist)
 	if err != nil {
-		return "", err
+		return err
 	}
-	defer resp.Body.Close()
+	version, err := dist.getVersion(d.Get("channel").(string))
+	if err != nil {
+		return err
+	}
+	d.Set("ami", ami)
+	d.Set("version", version)
+	d.Set("signing_key", dist.signingKey)
+	return nil
+}
 
-	var data amiInfo
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+func getAMI(d *schema.ResourceData, dist *distribution) (string, error) {
+	data, err := dist.getAMIs(d.Get("channel").(string))
+	if err != nil {
 		return "", err
 	}
 
-	t := d.Get("type").(string)
-	r := d.Get("region").(string)
+	return data.find(d.Get("region").(string), d.Get("type").(string))
+}
 
-	for _, a := range data.AMIs {
+// find returns the AMI of the given virtualization type in region. Not
+// every release has both types, Flatcar only publishes hvm ones.
+func (i *amiInfo) find(r, t string) (string, error) {
+	for _, a := range i.AMIs {
 		if a.Name == r {
+			var id string
 			switch t {
 			case "pv":
-				return a.PV, nil
+				id = a.PV
 			case "hvm":
-				return a.HVM, nil
+				id = a.HVM
 			default:
 				return "", fmt.Errorf("invalid type: %s", t)
 			}
+			if id == "" {
+				return "", fmt.Errorf("no %s ami found in %s", t, r)
+			}
+			return id, nil
 		}
 	}
 	return "", fmt.Errorf("no ami found")
 }
 
-func getID(d *schema.ResourceData) string {
+func getID(d *schema.ResourceData, dist *distribution) string {
 	channel := d.Get("channel").(string)
 	r := d.Get("region").(string)
 	t := d.Get("type").(string)
 
-	return strings.Join([]string{channel, r, t}, ":")
+	parts := []string{channel, r, t}
+	// IDs predating distributions have no prefix and always meant coreos.
+	if dist.name != "coreos" {
+		parts = append([]string{dist.name}, parts...)
+	}
+	return strings.Join(parts, ":")
 }
@@ -0,0 +1,87 @@
+package coreos
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSButaneConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSButaneConfigCreate,
+		Delete: resourceCoreOSButaneConfigDelete,
+		Exists: resourceCoreOSButaneConfigExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"content": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Butane YAML with variant fcos",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"files_dir": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "directory local file references are resolved in",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"strict": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "fail on unused keys instead of warning",
+				Default:     false,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Ignition spec 3 config",
+			},
+			"warnings": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "unused keys found in the config",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func transpileButaneResource(d *schema.ResourceData) (string, []string, error) {
+	return TranspileButaneConfig(
+		d.Get("content").(string),
+		d.Get("files_dir").(string),
+		d.Get("strict").(bool),
+	)
+}
+
+func resourceCoreOSButaneConfigCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	ign, warnings, err := transpileButaneResource(d)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		log.Printf("[WARN] butane: %s", w)
+	}
+	d.Set("ignition", ign)
+	d.Set("warnings", warnings)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSButaneConfigDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSButaneConfigExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	ign, _, err := transpileButaneResource(d)
+	if err != nil {
+		return false, err
+	}
+	return hash(ign) == d.Id(), nil
+}
@@ -0,0 +1,82 @@
+package coreos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSCloudConfigToIgnition() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSCloudConfigToIgnitionCreate,
+		Delete: resourceCoreOSCloudConfigToIgnitionDelete,
+		Exists: resourceCoreOSCloudConfigToIgnitionExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "cloud-config document to convert",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "converted Ignition config",
+			},
+			"warnings": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "parts of the cloud-config that could not be translated",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func resourceCoreOSCloudConfigToIgnitionCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	ign, warnings, err := ConvertCloudConfig(d.Get("cloud_config").(string))
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		log.Printf("[WARN] cloud-config: %s", w)
+	}
+	d.Set("ignition", ign)
+	d.Set("warnings", warnings)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSCloudConfigToIgnitionDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSCloudConfigToIgnitionExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	ign, _, err := ConvertCloudConfig(d.Get("cloud_config").(string))
+	if err != nil {
+		return false, err
+	}
+	return hash(ign) == d.Id(), nil
+}
+
+// resourceLocalRead is the Read of resources that only exist in the state,
+// such as rendered configs and generated certificates. Their Exists
+// re-renders or re-checks them and reports false once they are stale, so
+// there is nothing left to refresh here.
+func resourceLocalRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	return nil
+}
+
+func hash(s string) string {
+	sha := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sha[:])
+}
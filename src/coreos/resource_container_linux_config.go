@@ -0,0 +1,62 @@
+package coreos
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSContainerLinuxConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSContainerLinuxConfigCreate,
+		Delete: resourceCoreOSContainerLinuxConfigDelete,
+		Exists: resourceCoreOSContainerLinuxConfigExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"content": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Container Linux Config YAML",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"platform": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "platform used to resolve dynamic values: ec2, gce, azure, digitalocean, packet or vagrant",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "transpiled Ignition config",
+			},
+		},
+	}
+}
+
+func resourceCoreOSContainerLinuxConfigCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	ign, err := TranspileContainerLinuxConfig(d.Get("content").(string), d.Get("platform").(string))
+	if err != nil {
+		return err
+	}
+	d.Set("ignition", ign)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSContainerLinuxConfigDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSContainerLinuxConfigExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	ign, err := TranspileContainerLinuxConfig(d.Get("content").(string), d.Get("platform").(string))
+	if err != nil {
+		return false, err
+	}
+	return hash(ign) == d.Id(), nil
+}
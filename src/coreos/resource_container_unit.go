@@ -0,0 +1,211 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSContainerUnit() *schema.Resource {
+	s := map[string]*schema.Schema{
+		"name": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "service and container name",
+			Required:    true,
+			ForceNew:    true,
+		},
+		"image": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "container image, without tag",
+			Required:    true,
+			ForceNew:    true,
+		},
+		"tag": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "image tag",
+			Default:     "latest",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"digest": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "image digest, takes precedence over tag",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"args": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "arguments passed to the container",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"ports": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "published ports, host:container[/protocol]",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"volumes": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "host:container[:ro] bind mounts",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"env": &schema.Schema{
+			Type:        schema.TypeMap,
+			Description: "environment variables",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"restart": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "systemd restart policy",
+			Default:     "always",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"restart_sec": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "delay before restarting",
+			Default:     "10",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"timeout_start_sec": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "start timeout, long enough to pull the image",
+			Default:     "5min",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"requires": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "units the service requires",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"after": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "units the service starts after",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"runtime": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "docker or rkt",
+			Default:     "docker",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"fleet_conflicts": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "fleet Conflicts globs",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"fleet_machine_metadata": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "fleet MachineMetadata key=value pairs",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"fleet_machine_of": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "fleet MachineOf unit",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"fleet_global": &schema.Schema{
+			Type:        schema.TypeBool,
+			Description: "run on every fleet machine",
+			Default:     false,
+			Optional:    true,
+			ForceNew:    true,
+		},
+	}
+	for k, v := range unitOutputsSchema() {
+		s[k] = v
+	}
+
+	return &schema.Resource{
+		Create: resourceCoreOSContainerUnitCreate,
+		Delete: resourceCoreOSContainerUnitDelete,
+		Exists: resourceCoreOSContainerUnitExists,
+		Read:   resourceLocalRead,
+
+		Schema: s,
+	}
+}
+
+func containerUnitFromResource(d *schema.ResourceData) (*systemdUnit, error) {
+	env := make(map[string]string)
+	for k, v := range d.Get("env").(map[string]interface{}) {
+		env[k] = fmt.Sprint(v)
+	}
+
+	c := &containerUnit{
+		name:            d.Get("name").(string),
+		image:           d.Get("image").(string),
+		tag:             d.Get("tag").(string),
+		digest:          d.Get("digest").(string),
+		args:            stringList(d.Get("args")),
+		ports:           stringList(d.Get("ports")),
+		volumes:         stringList(d.Get("volumes")),
+		env:             env,
+		restart:         d.Get("restart").(string),
+		restartSec:      d.Get("restart_sec").(string),
+		timeoutStartSec: d.Get("timeout_start_sec").(string),
+		requires:        stringList(d.Get("requires")),
+		after:           stringList(d.Get("after")),
+		runtime:         d.Get("runtime").(string),
+
+		fleetConflicts:       stringList(d.Get("fleet_conflicts")),
+		fleetMachineMetadata: stringList(d.Get("fleet_machine_metadata")),
+		fleetMachineOf:       d.Get("fleet_machine_of").(string),
+		fleetGlobal:          d.Get("fleet_global").(bool),
+	}
+	return c.unit()
+}
+
+func stringList(v interface{}) []string {
+	l, _ := v.([]interface{})
+	out := make([]string, len(l))
+	for i, s := range l {
+		out[i] = s.(string)
+	}
+	return out
+}
+
+func resourceCoreOSContainerUnitCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	u, err := containerUnitFromResource(d)
+	if err != nil {
+		return err
+	}
+	d.SetId(setUnitOutputs(d, u))
+	return nil
+}
+
+func resourceCoreOSContainerUnitDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSContainerUnitExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	u, err := containerUnitFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	_, ign := unitConfigs(u)
+	return hash(ign) == d.Id(), nil
+}
@@ -0,0 +1,239 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSCoreUpdateGroup() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSCoreUpdateGroupCreate,
+		Delete: resourceCoreOSCoreUpdateGroupDelete,
+		Exists: resourceCoreOSCoreUpdateGroupExists,
+		Read:   resourceCoreOSCoreUpdateGroupRead,
+		Update: resourceCoreOSCoreUpdateGroupUpdate,
+
+		Schema: map[string]*schema.Schema{
+			"server": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "CoreUpdate base URL",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"user": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "CoreUpdate user",
+				Optional:    true,
+			},
+			"key": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "CoreUpdate API key",
+				Optional:    true,
+			},
+			"app_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "application the group belongs to",
+				Default:     coreUpdateAppID,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "group name",
+				Required:    true,
+			},
+			"description": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "group description",
+				Optional:    true,
+			},
+			"channel": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "name of the channel the group follows",
+				Optional:    true,
+			},
+			"track": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "what machines set GROUP to, chosen by the server when empty",
+				Optional:    true,
+				Computed:    true,
+			},
+			"updates_enabled": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "whether the group is offered updates",
+				Default:     true,
+				Optional:    true,
+			},
+			"safe_mode": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "update one machine at a time and stop on the first failure",
+				Default:     false,
+				Optional:    true,
+			},
+			"office_hours": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "only update during office hours in timezone",
+				Default:     false,
+				Optional:    true,
+			},
+			"timezone": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "timezone office hours are in, e.g. Europe/Berlin",
+				Optional:    true,
+			},
+			"max_updates_per_period": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "machines updated per period_interval at most",
+				Default:     2,
+				Optional:    true,
+			},
+			"period_interval": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "period max_updates_per_period applies to",
+				Default:     "15 minutes",
+				Optional:    true,
+			},
+			"update_timeout": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "how long a machine may take to report its update before counting as failed",
+				Default:     "60 minutes",
+				Optional:    true,
+			},
+			"group_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "CoreUpdate group ID",
+			},
+		},
+	}
+}
+
+func resourceCoreUpdateClient(d *schema.ResourceData) (*coreUpdateClient, error) {
+	return newCoreUpdateClient(d.Get("server").(string), d.Get("user").(string), d.Get("key").(string))
+}
+
+func coreUpdateGroupFromResource(d *schema.ResourceData, c *coreUpdateClient) (*coreUpdateGroup, error) {
+	appID := d.Get("app_id").(string)
+	channelID, err := c.channelID(appID, d.Get("channel").(string))
+	if err != nil {
+		return nil, err
+	}
+	g := &coreUpdateGroup{
+		ID:                        d.Id(),
+		Name:                      d.Get("name").(string),
+		Description:               d.Get("description").(string),
+		ApplicationID:             appID,
+		ChannelID:                 channelID,
+		Track:                     d.Get("track").(string),
+		PolicyUpdatesEnabled:      d.Get("updates_enabled").(bool),
+		PolicySafeMode:            d.Get("safe_mode").(bool),
+		PolicyOfficeHours:         d.Get("office_hours").(bool),
+		PolicyTimezone:            d.Get("timezone").(string),
+		PolicyPeriodInterval:      d.Get("period_interval").(string),
+		PolicyMaxUpdatesPerPeriod: d.Get("max_updates_per_period").(int),
+		PolicyUpdateTimeout:       d.Get("update_timeout").(string),
+	}
+	if g.PolicyMaxUpdatesPerPeriod < 1 {
+		return nil, fmt.Errorf("max_updates_per_period must be at least 1, use updates_enabled to stop updates")
+	}
+	return g, nil
+}
+
+func setCoreUpdateGroup(d *schema.ResourceData, c *coreUpdateClient, g *coreUpdateGroup) error {
+	channel, err := c.channelName(g.ApplicationID, g.ChannelID)
+	if err != nil {
+		return err
+	}
+	d.Set("name", g.Name)
+	d.Set("description", g.Description)
+	d.Set("channel", channel)
+	d.Set("track", g.Track)
+	d.Set("updates_enabled", g.PolicyUpdatesEnabled)
+	d.Set("safe_mode", g.PolicySafeMode)
+	d.Set("office_hours", g.PolicyOfficeHours)
+	d.Set("timezone", g.PolicyTimezone)
+	d.Set("max_updates_per_period", g.PolicyMaxUpdatesPerPeriod)
+	d.Set("period_interval", g.PolicyPeriodInterval)
+	d.Set("update_timeout", g.PolicyUpdateTimeout)
+	d.Set("group_id", g.ID)
+	return nil
+}
+
+func resourceCoreOSCoreUpdateGroupCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := resourceCoreUpdateClient(d)
+	if err != nil {
+		return err
+	}
+	g, err := coreUpdateGroupFromResource(d, c)
+	if err != nil {
+		return err
+	}
+	g, err = c.createGroup(g)
+	if err != nil {
+		return err
+	}
+	d.SetId(g.ID)
+	return setCoreUpdateGroup(d, c, g)
+}
+
+func resourceCoreOSCoreUpdateGroupDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	c, err := resourceCoreUpdateClient(d)
+	if err != nil {
+		return err
+	}
+	if err := c.deleteGroup(d.Get("app_id").(string), d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSCoreUpdateGroupExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := resourceCoreUpdateClient(d)
+	if err != nil {
+		return false, err
+	}
+	g, err := c.group(d.Get("app_id").(string), d.Id())
+	return g != nil, err
+}
+
+func resourceCoreOSCoreUpdateGroupRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceCoreUpdateClient(d)
+	if err != nil {
+		return err
+	}
+	g, err := c.group(d.Get("app_id").(string), d.Id())
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		// deleted in the UI
+		d.SetId("")
+		return nil
+	}
+	// everything is read back, so edits made in the UI show up as drift
+	return setCoreUpdateGroup(d, c, g)
+}
+
+func resourceCoreOSCoreUpdateGroupUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	c, err := resourceCoreUpdateClient(d)
+	if err != nil {
+		return err
+	}
+	g, err := coreUpdateGroupFromResource(d, c)
+	if err != nil {
+		return err
+	}
+	g, err = c.updateGroup(g)
+	if err != nil {
+		return err
+	}
+	return setCoreUpdateGroup(d, c, g)
+}
@@ -0,0 +1,213 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSDockerConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSDockerConfigCreate,
+		Delete: resourceCoreOSDockerConfigDelete,
+		Exists: resourceCoreOSDockerConfigExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"storage_driver": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "storage driver, e.g. overlay2",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"log_driver": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "default logging driver, e.g. journald",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"log_opts": &schema.Schema{
+				Type:        schema.TypeMap,
+				Description: "logging driver options",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"insecure_registries": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "registries, or CIDRs of registries, pulled from without TLS verification",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"registry_mirrors": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "Docker Hub mirror URLs",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"bridge_ip": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "docker0 address in CIDR notation",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"tcp_port": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "port to expose the remote API on, 0 for none",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"insecure_tcp": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "expose the remote API on tcp_port without TLS, which gives anyone who can reach it root",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"tls_ca_cert": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "PEM encoded CA remote API clients must be signed by",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"tls_cert": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "PEM encoded remote API server certificate",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"tls_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "PEM encoded private key of tls_cert",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"registry_ca": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "CA certificates of private registries",
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"registry": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "registry host, with the port if it isn't 443",
+							Required:    true,
+						},
+						"ca_cert": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "PEM encoded CA certificate",
+							Required:    true,
+						},
+					},
+				},
+			},
+			"extra_flags": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "further daemon flags",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"docker_opts": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "DOCKER_OPTS value",
+			},
+			"dropin": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "docker.service drop-in setting DOCKER_OPTS",
+			},
+			"files": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "certificate files keyed by path",
+			},
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "cloud-config installing the drop-in, socket and files",
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Ignition config installing the drop-in, socket and files",
+			},
+		},
+	}
+}
+
+func dockerConfigFromResource(d *schema.ResourceData) (*dockerConfig, error) {
+	c := &dockerConfig{
+		storageDriver:      d.Get("storage_driver").(string),
+		logDriver:          d.Get("log_driver").(string),
+		logOpts:            make(map[string]string),
+		insecureRegistries: stringList(d.Get("insecure_registries")),
+		registryMirrors:    stringList(d.Get("registry_mirrors")),
+		bridgeIP:           d.Get("bridge_ip").(string),
+		tcpPort:            d.Get("tcp_port").(int),
+		insecureTCP:        d.Get("insecure_tcp").(bool),
+		tlsCACert:          d.Get("tls_ca_cert").(string),
+		tlsCert:            d.Get("tls_cert").(string),
+		tlsKey:             d.Get("tls_key").(string),
+		registryCAs:        make(map[string]string),
+		extraFlags:         stringList(d.Get("extra_flags")),
+	}
+	for k, v := range d.Get("log_opts").(map[string]interface{}) {
+		c.logOpts[k] = v.(string)
+	}
+	for _, v := range d.Get("registry_ca").([]interface{}) {
+		m := v.(map[string]interface{})
+		r := m["registry"].(string)
+		if _, ok := c.registryCAs[r]; ok {
+			return nil, fmt.Errorf("registry_ca: %s is listed twice", r)
+		}
+		c.registryCAs[r] = m["ca_cert"].(string)
+	}
+	return c, c.validate()
+}
+
+func resourceCoreOSDockerConfigCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := dockerConfigFromResource(d)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]interface{})
+	for _, f := range c.files() {
+		files[f.path] = f.contents
+	}
+	flags := c.flags()
+	dropIn := ""
+	if len(flags) > 0 {
+		dropIn = dockerOptsDropIn(flags)
+	}
+	cc, ign := c.configs()
+	d.Set("docker_opts", strings.Join(flags, " "))
+	d.Set("dropin", dropIn)
+	d.Set("files", files)
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSDockerConfigDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSDockerConfigExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := dockerConfigFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	_, ign := c.configs()
+	return hash(ign) == d.Id(), nil
+}
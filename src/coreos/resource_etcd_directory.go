@@ -0,0 +1,190 @@
+package coreos
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSEtcdDirectory() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSEtcdDirectoryCreate,
+		Delete: resourceCoreOSEtcdDirectoryDelete,
+		Exists: resourceCoreOSEtcdDirectoryExists,
+		Read:   resourceCoreOSEtcdDirectoryRead,
+		Update: resourceCoreOSEtcdDirectoryUpdate,
+
+		Schema: etcdConnectionSchema(map[string]*schema.Schema{
+			"path": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "directory path",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"values": &schema.Schema{
+				Type:        schema.TypeMap,
+				Description: "values keyed by their path relative to the directory",
+				Optional:    true,
+			},
+			"prune": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "delete keys directly in the directory that are not in values",
+				Default:     false,
+				Optional:    true,
+			},
+			"ttl": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "seconds until the directory expires, 0 for never",
+				Default:     0,
+				Optional:    true,
+			},
+			"created": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "whether the directory was created rather than adopted",
+			},
+			"indexes": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "etcd index of the last write to each key",
+			},
+		}),
+	}
+}
+
+func etcdDirectoryValues(d *schema.ResourceData) map[string]string {
+	values := make(map[string]string)
+	for k, v := range d.Get("values").(map[string]interface{}) {
+		values[k] = v.(string)
+	}
+	return values
+}
+
+func etcdDirectoryIndexes(d *schema.ResourceData) map[string]uint64 {
+	indexes := make(map[string]uint64)
+	for k, v := range d.Get("indexes").(map[string]interface{}) {
+		n, _ := strconv.ParseUint(v.(string), 10, 64)
+		indexes[k] = n
+	}
+	return indexes
+}
+
+func syncEtcdDirectory(d *schema.ResourceData, c *etcdClient) error {
+	indexes, err := c.syncDir(d.Id(), etcdDirectoryValues(d), etcdDirectoryIndexes(d), d.Get("prune").(bool))
+	if err != nil {
+		return err
+	}
+	m := make(map[string]interface{})
+	for k, v := range indexes {
+		m[k] = strconv.FormatUint(v, 10)
+	}
+	d.Set("indexes", m)
+	return nil
+}
+
+func resourceCoreOSEtcdDirectoryCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	// an existing directory is adopted, and pruned if asked to
+	dir := d.Get("path").(string)
+	_, err = c.mkdir(dir, d.Get("ttl").(int), "false")
+	if err != nil && !isEtcdError(err, etcdErrNodeExist) {
+		return err
+	}
+	d.SetId(dir)
+	d.Set("created", err == nil)
+	return syncEtcdDirectory(d, c)
+}
+
+func resourceCoreOSEtcdDirectoryDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+	var managed []string
+	for k := range etcdDirectoryIndexes(d) {
+		managed = append(managed, k)
+	}
+	sort.Strings(managed)
+	if err := c.removeDir(d.Id(), managed, d.Get("created").(bool)); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSEtcdDirectoryExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return false, err
+	}
+	_, err = c.get(d.Id(), false)
+	if isEtcdError(err, etcdErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func resourceCoreOSEtcdDirectoryRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.readDir(d.Id())
+	if isEtcdError(err, etcdErrKeyNotFound) {
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keys added out of band only show up as drift when they would be
+	// pruned, which keys in subdirectories never are; edits and deletes
+	// of managed keys always do.
+	// Managed keys take the index they were read at, so an apply based on
+	// this refresh only overwrites what it has seen.
+	managed := etcdDirectoryIndexes(d)
+	prune := d.Get("prune").(bool)
+	values := make(map[string]interface{})
+	indexes := make(map[string]interface{})
+	for k, n := range current {
+		_, ok := managed[k]
+		if ok {
+			indexes[k] = strconv.FormatUint(n.ModifiedIndex, 10)
+		}
+		if ok || prune && !strings.Contains(k, "/") {
+			values[k] = n.Value
+		}
+	}
+	d.Set("values", values)
+	d.Set("indexes", indexes)
+	return nil
+}
+
+func resourceCoreOSEtcdDirectoryUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	// a TTL is refreshed on every update, and cleared when it is set to 0
+	if ttl := d.Get("ttl").(int); ttl > 0 || d.HasChange("ttl") {
+		if _, err := c.mkdir(d.Id(), ttl, "true"); err != nil {
+			return err
+		}
+	}
+	return syncEtcdDirectory(d, c)
+}
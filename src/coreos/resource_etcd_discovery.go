@@ -0,0 +1,69 @@
+package coreos
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSEtcdDiscovery() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSEtcdDiscoveryCreate,
+		Delete: resourceCoreOSEtcdDiscoveryDelete,
+		Exists: resourceCoreOSEtcdDiscoveryExists,
+		Read:   resourceCoreOSEtcdDiscoveryRead,
+
+		Schema: map[string]*schema.Schema{
+			"size": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "expected number of cluster members",
+				Default:     3,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"endpoint": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "discovery service to request the token from",
+				Default:     etcdDiscoveryURL,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"url": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "discovery URL to pass to etcd",
+			},
+		},
+	}
+}
+
+func resourceCoreOSEtcdDiscoveryCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	token, err := newDiscoveryToken(d.Get("endpoint").(string), d.Get("size").(int))
+	if err != nil {
+		return err
+	}
+	d.Set("url", token)
+	d.SetId(token)
+	return nil
+}
+
+func resourceCoreOSEtcdDiscoveryDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	if err := deleteDiscoveryToken(d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSEtcdDiscoveryExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return discoveryTokenExists(d.Id())
+}
+
+func resourceCoreOSEtcdDiscoveryRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	d.Set("url", d.Id())
+	return nil
+}
@@ -0,0 +1,127 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSEtcdHealth() *schema.Resource {
+	s := etcdConnectionSchema(map[string]*schema.Schema{
+		"quorum": &schema.Schema{
+			Type:        schema.TypeInt,
+			Description: "healthy endpoints to wait for, defaults to a majority",
+			Default:     0,
+			Optional:    true,
+		},
+		"member_count": &schema.Schema{
+			Type:        schema.TypeInt,
+			Description: "started members to wait for, defaults to the number of endpoints",
+			Default:     0,
+			Optional:    true,
+		},
+		"timeout": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "how long to wait before failing",
+			Default:     "10m",
+			Optional:    true,
+		},
+		"interval": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "delay between checks",
+			Default:     "5s",
+			Optional:    true,
+		},
+		"leader_id": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "ID of the leader",
+		},
+		"member_ids": &schema.Schema{
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "IDs of every member",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+	})
+	// there is nothing to update, a changed gate is waited on again
+	for _, v := range s {
+		v.ForceNew = !v.Computed
+	}
+
+	return &schema.Resource{
+		Create: resourceCoreOSEtcdHealthCreate,
+		Delete: resourceCoreOSEtcdHealthDelete,
+		Exists: resourceCoreOSEtcdHealthExists,
+		Read:   resourceCoreOSEtcdHealthRead,
+
+		Schema: s,
+	}
+}
+
+func resourceCoreOSEtcdHealthCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+	timeout, err := time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return fmt.Errorf("timeout: %s", err)
+	}
+	interval, err := time.ParseDuration(d.Get("interval").(string))
+	if err != nil {
+		return fmt.Errorf("interval: %s", err)
+	}
+
+	quorum := d.Get("quorum").(int)
+	if quorum == 0 {
+		quorum = etcdQuorum(len(c.endpoints))
+	}
+	members := d.Get("member_count").(int)
+	if members == 0 {
+		members = len(c.endpoints)
+	}
+
+	s, err := c.waitHealthy(c.endpoints, quorum, members, timeout, interval)
+	if err != nil {
+		return err
+	}
+	d.Set("leader_id", s.leader)
+	d.Set("member_ids", s.memberIDs)
+	d.SetId(hash(strings.Join(c.endpoints, ",")))
+	return nil
+}
+
+func resourceCoreOSEtcdHealthDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSEtcdHealthExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return true, nil
+}
+
+func resourceCoreOSEtcdHealthRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	// An unhealthy cluster doesn't fail the refresh; the gate only blocks
+	// when it is created.
+	s := c.clusterStatus(c.endpoints)
+	if s.leader == "" {
+		log.Printf("[WARN] no healthy etcd endpoint to refresh from")
+		return nil
+	}
+	d.Set("leader_id", s.leader)
+	d.Set("member_ids", s.memberIDs)
+	return nil
+}
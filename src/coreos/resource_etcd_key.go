@@ -0,0 +1,151 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSEtcdKey() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSEtcdKeyCreate,
+		Delete: resourceCoreOSEtcdKeyDelete,
+		Exists: resourceCoreOSEtcdKeyExists,
+		Read:   resourceCoreOSEtcdKeyRead,
+		Update: resourceCoreOSEtcdKeyUpdate,
+
+		Schema: etcdConnectionSchema(map[string]*schema.Schema{
+			"key": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "key path",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"value": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "key value",
+				Required:    true,
+			},
+			"ttl": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "seconds until the key expires, 0 for never",
+				Default:     0,
+				Optional:    true,
+			},
+			"modified_index": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "etcd index of the last write, used for compare-and-swap",
+			},
+		}),
+	}
+}
+
+func resourceCoreOSEtcdKeyCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	key := d.Get("key").(string)
+	n, err := createEtcdKey(c, key, d.Get("value").(string), d.Get("ttl").(int))
+	if err != nil {
+		return err
+	}
+	d.Set("modified_index", int(n.ModifiedIndex))
+	d.SetId(key)
+	return nil
+}
+
+// createEtcdKey writes a key, adopting it if it already exists the way
+// syncDir adopts the keys of a directory: the value read is swapped for
+// the new one, so a concurrent write fails the apply instead of being lost.
+func createEtcdKey(c *etcdClient, key, value string, ttl int) (*etcdNode, error) {
+	cur, err := c.get(key, false)
+	var n *etcdNode
+	switch {
+	case isEtcdError(err, etcdErrKeyNotFound):
+		n, err = c.set(key, value, ttl, 0, "false")
+	case err != nil:
+		return nil, err
+	case cur.Dir:
+		return nil, fmt.Errorf("%s is a directory", key)
+	default:
+		n, err = c.set(key, value, ttl, cur.ModifiedIndex, "")
+	}
+	if isEtcdError(err, etcdErrTestFailed) || isEtcdError(err, etcdErrNodeExist) {
+		return nil, fmt.Errorf("%s was changed while it was being created, try again", key)
+	}
+	return n, err
+}
+
+func resourceCoreOSEtcdKeyDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+	if err := c.delete(d.Id(), false, 0); err != nil && !isEtcdError(err, etcdErrKeyNotFound) {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSEtcdKeyExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return false, err
+	}
+	_, err = c.get(d.Id(), false)
+	if isEtcdError(err, etcdErrKeyNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func resourceCoreOSEtcdKeyRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	n, err := c.get(d.Id(), false)
+	if isEtcdError(err, etcdErrKeyNotFound) {
+		// expired or deleted out of band
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the remaining TTL is not read back, it would differ on every refresh
+	d.Set("value", n.Value)
+	d.Set("modified_index", int(n.ModifiedIndex))
+	return nil
+}
+
+func resourceCoreOSEtcdKeyUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	// only overwrite the value that was last read, so concurrent edits
+	// fail instead of being lost
+	prev := uint64(d.Get("modified_index").(int))
+	n, err := c.set(d.Id(), d.Get("value").(string), d.Get("ttl").(int), prev, "")
+	if isEtcdError(err, etcdErrTestFailed) {
+		return fmt.Errorf("%s was changed since it was last read, refresh and try again", d.Id())
+	}
+	if err != nil {
+		return err
+	}
+	d.Set("modified_index", int(n.ModifiedIndex))
+	return nil
+}
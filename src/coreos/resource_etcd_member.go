@@ -0,0 +1,143 @@
+package coreos
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSEtcdMember() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSEtcdMemberCreate,
+		Delete: resourceCoreOSEtcdMemberDelete,
+		Exists: resourceCoreOSEtcdMemberExists,
+		Read:   resourceCoreOSEtcdMemberRead,
+		Update: resourceCoreOSEtcdMemberUpdate,
+
+		Schema: etcdConnectionSchema(map[string]*schema.Schema{
+			"peer_urls": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "peer URLs the new member will listen on",
+				Required:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "name the new member will start with, used for initial_cluster",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"member_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "member ID assigned by the cluster",
+			},
+			"client_urls": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "client URLs, once the member has started",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"initial_cluster": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "initial-cluster value for the new member, which starts with initial-cluster-state existing",
+			},
+		}),
+	}
+}
+
+func resourceCoreOSEtcdMemberCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	members, err := c.members()
+	if err != nil {
+		return err
+	}
+	peerURLs := stringList(d.Get("peer_urls"))
+	m, err := c.addMember(peerURLs)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] added etcd member %s", m.ID)
+
+	d.Set("member_id", m.ID)
+	d.Set("initial_cluster", initialCluster(members, d.Get("name").(string), peerURLs))
+	d.SetId(m.ID)
+	return nil
+}
+
+func resourceCoreOSEtcdMemberDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	members, err := c.members()
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.ID != d.Id() {
+			continue
+		}
+		if err := c.checkRemoval(members, m.ID); err != nil {
+			return err
+		}
+		if err := c.removeMember(m.ID); err != nil {
+			return err
+		}
+		log.Printf("[INFO] removed etcd member %s", m.ID)
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSEtcdMemberExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return false, err
+	}
+	m, err := c.member(d.Id())
+	return m != nil, err
+}
+
+func resourceCoreOSEtcdMemberRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	m, err := c.member(d.Id())
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		// removed out of band
+		d.SetId("")
+		return nil
+	}
+	d.Set("peer_urls", m.PeerURLs)
+	d.Set("client_urls", m.ClientURLs)
+	d.Set("member_id", m.ID)
+	return nil
+}
+
+func resourceCoreOSEtcdMemberUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	if !d.HasChange("peer_urls") {
+		return nil
+	}
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+	return c.updateMember(d.Id(), stringList(d.Get("peer_urls")))
+}
@@ -0,0 +1,214 @@
+package coreos
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSEtcdStaticCluster() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSEtcdStaticClusterCreate,
+		Delete: resourceCoreOSEtcdStaticClusterDelete,
+		Exists: resourceCoreOSEtcdStaticClusterExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"member": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "cluster members, in count.index order",
+				Required:    true,
+				ForceNew:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "member name",
+							Required:    true,
+						},
+						"ip": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "address the member is reached at",
+							Required:    true,
+						},
+					},
+				},
+			},
+			"token": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "initial cluster token, derived from the members when empty",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"tls": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "serve clients and peers over TLS with client certificate auth",
+				Default:     false,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"client_port": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "client port",
+				Default:     2379,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"peer_port": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "peer port",
+				Default:     2380,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "etcd version etcd-member runs, the OS default when empty",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"cert_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "certificate used for clients and peers when tls is on",
+				Default:     "/etc/ssl/etcd/cert.pem",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"key_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "private key of cert_file",
+				Default:     "/etc/ssl/etcd/key.pem",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"trusted_ca_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "CA client and peer certificates are checked against",
+				Default:     "/etc/ssl/etcd/ca.pem",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"initial_cluster": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "initial-cluster setting",
+			},
+			"initial_cluster_token": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "initial-cluster-token setting",
+			},
+			"advertise_client_urls": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "client URL of each member",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"advertise_peer_urls": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "peer URL of each member",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"listen_client_urls": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "URL each member listens for clients on",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"listen_peer_urls": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "URL each member listens for peers on",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "coreos.etcd2 cloud-config of each member",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Ignition config with the etcd-member drop-in of each member",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func etcdStaticClusterFromResource(d *schema.ResourceData) (*etcdStaticCluster, error) {
+	c := &etcdStaticCluster{
+		token:      d.Get("token").(string),
+		tls:        d.Get("tls").(bool),
+		clientPort: d.Get("client_port").(int),
+		peerPort:   d.Get("peer_port").(int),
+		version:    d.Get("version").(string),
+		certFile:   d.Get("cert_file").(string),
+		keyFile:    d.Get("key_file").(string),
+		caFile:     d.Get("trusted_ca_file").(string),
+	}
+	for _, v := range d.Get("member").([]interface{}) {
+		m := v.(map[string]interface{})
+		c.members = append(c.members, etcdStaticMember{
+			name: m["name"].(string),
+			ip:   m["ip"].(string),
+		})
+	}
+	return c, c.validate()
+}
+
+func etcdStaticClusterIgnition(c *etcdStaticCluster) []string {
+	ign := make([]string, len(c.members))
+	for i := range c.members {
+		ign[i] = c.ignition(i)
+	}
+	return ign
+}
+
+func resourceCoreOSEtcdStaticClusterCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := etcdStaticClusterFromResource(d)
+	if err != nil {
+		return err
+	}
+
+	n := len(c.members)
+	clientURLs, peerURLs := make([]string, n), make([]string, n)
+	listenClient, cc := make([]string, n), make([]string, n)
+	for i := range c.members {
+		clientURLs[i] = c.clientURL(i)
+		peerURLs[i] = c.peerURL(i)
+		listenClient[i] = c.listenClientURL()
+		cc[i] = c.cloudConfig(i)
+	}
+	ign := etcdStaticClusterIgnition(c)
+
+	d.Set("initial_cluster", c.initialCluster())
+	d.Set("initial_cluster_token", c.initialClusterToken())
+	d.Set("advertise_client_urls", clientURLs)
+	d.Set("advertise_peer_urls", peerURLs)
+	d.Set("listen_client_urls", listenClient)
+	d.Set("listen_peer_urls", peerURLs)
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(hash(strings.Join(ign, "\n")))
+	return nil
+}
+
+func resourceCoreOSEtcdStaticClusterDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSEtcdStaticClusterExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := etcdStaticClusterFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	return hash(strings.Join(etcdStaticClusterIgnition(c), "\n")) == d.Id(), nil
+}
@@ -0,0 +1,161 @@
+package coreos
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSFCOSImage() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSFCOSImageCreate,
+		Delete: resourceCoreOSFCOSImageDelete,
+		Exists: resourceCoreOSFCOSImageExists,
+		Read:   resourceCoreOSFCOSImageRead,
+
+		Schema: map[string]*schema.Schema{
+			"stream": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Fedora CoreOS stream: stable, testing or next",
+				Default:     "stable",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"architecture": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "CPU architecture",
+				Default:     "x86_64",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"platform": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "platform: aws, gcp, azure, qemu, metal, openstack, ...",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"region": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "AWS region",
+				Default:     "us-west-2",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"format": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "artifact format, e.g. qcow2.xz or raw.xz",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"stream_url": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "base URL stream metadata is fetched from",
+				Default:     fcosStreamURL,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"release": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Fedora CoreOS release",
+			},
+			"last_modified": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "when the stream metadata was last modified",
+			},
+			"image": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "AMI ID on aws, image name on gcp",
+			},
+			"project": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "GCP project holding the image",
+			},
+			"family": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "GCP image family",
+			},
+			"location": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "artifact URL",
+			},
+			"signature": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "artifact signature URL",
+			},
+			"sha256": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "artifact sha256 digest",
+			},
+		},
+	}
+}
+
+func resourceCoreOSFCOSImageCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	if err := readFCOSImage(d); err != nil {
+		return err
+	}
+	d.SetId(getFCOSImageID(d))
+	return nil
+}
+
+func resourceCoreOSFCOSImageDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSFCOSImageExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return getFCOSImageID(d) == d.Id(), nil
+}
+
+func resourceCoreOSFCOSImageRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	return readFCOSImage(d)
+}
+
+func readFCOSImage(d *schema.ResourceData) error {
+	s, err := getFCOSStream(d.Get("stream_url").(string), d.Get("stream").(string))
+	if err != nil {
+		return err
+	}
+	img, err := s.resolve(
+		d.Get("architecture").(string),
+		d.Get("platform").(string),
+		d.Get("region").(string),
+		d.Get("format").(string),
+	)
+	if err != nil {
+		return err
+	}
+
+	d.Set("release", img.Release)
+	d.Set("last_modified", s.Metadata.LastModified)
+	d.Set("image", img.Image)
+	d.Set("project", img.Project)
+	d.Set("family", img.Family)
+	d.Set("location", img.Location)
+	d.Set("signature", img.Signature)
+	d.Set("sha256", img.Sha256)
+	return nil
+}
+
+func getFCOSImageID(d *schema.ResourceData) string {
+	return strings.Join([]string{
+		d.Get("stream").(string),
+		d.Get("architecture").(string),
+		d.Get("platform").(string),
+		d.Get("region").(string),
+		d.Get("format").(string),
+	}, ":")
+}
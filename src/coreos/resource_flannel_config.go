@@ -0,0 +1,226 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSFlannelConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSFlannelConfigCreate,
+		Delete: resourceCoreOSFlannelConfigDelete,
+		Exists: resourceCoreOSFlannelConfigExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"network": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "IPv4 CIDR of the overlay network",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"subnet_len": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "prefix length of each machine's subnet",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+			},
+			"subnet_min": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "first subnet handed out",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+			},
+			"subnet_max": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "last subnet handed out",
+				Optional:    true,
+				Computed:    true,
+				ForceNew:    true,
+			},
+			"min_subnets": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "fail unless at least this many subnets fit, e.g. the machine count",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"backend": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "how packets travel between machines",
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "vxlan, host-gw or aws-vpc",
+							Default:     "vxlan",
+							Optional:    true,
+						},
+						"vni": &schema.Schema{
+							Type:        schema.TypeInt,
+							Description: "VXLAN network identifier, for vxlan",
+							Optional:    true,
+						},
+						"port": &schema.Schema{
+							Type:        schema.TypeInt,
+							Description: "UDP port, for vxlan",
+							Optional:    true,
+						},
+						"route_table_id": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "VPC route table to add routes to, for aws-vpc",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"etcd_endpoints": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "etcd client URLs flanneld and etcdctl use, localhost when empty",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"etcd_prefix": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "etcd directory of the network, " + flannelEtcdPrefix + " when empty",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"etcd_cafile": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "path of the CA certificate etcd is verified with",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"etcd_certfile": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "path of the etcd client certificate flanneld and etcdctl use",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"etcd_keyfile": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "path of the etcd client key flanneld and etcdctl use",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"interface": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "interface or address flanneld sends traffic from",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "flannel version the Ignition drop-in runs, the OS default when empty",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"subnet_count": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "number of machines the network has subnets for",
+			},
+			"network_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "JSON network config to store in etcd",
+			},
+			"etcd_key": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "etcd key flanneld reads network_config from",
+			},
+			"dropin": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "flanneld.service drop-in storing the config and passing the options",
+			},
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "cloud-config with the coreos.flannel section and flanneld drop-in",
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Ignition config enabling flanneld with the drop-in",
+			},
+		},
+	}
+}
+
+func flannelConfigFromResource(d *schema.ResourceData) (*flannelConfig, *flannelNetworkConfig, error) {
+	c := &flannelConfig{
+		network:       d.Get("network").(string),
+		subnetLen:     d.Get("subnet_len").(int),
+		subnetMin:     d.Get("subnet_min").(string),
+		subnetMax:     d.Get("subnet_max").(string),
+		minSubnets:    d.Get("min_subnets").(int),
+		etcdPrefix:    d.Get("etcd_prefix").(string),
+		etcdEndpoints: stringList(d.Get("etcd_endpoints")),
+		etcdCAFile:    d.Get("etcd_cafile").(string),
+		etcdCertFile:  d.Get("etcd_certfile").(string),
+		etcdKeyFile:   d.Get("etcd_keyfile").(string),
+		iface:         d.Get("interface").(string),
+		version:       d.Get("version").(string),
+	}
+	backends := d.Get("backend").([]interface{})
+	if len(backends) > 1 {
+		return nil, nil, fmt.Errorf("only one backend can be set")
+	}
+	for _, v := range backends {
+		m := v.(map[string]interface{})
+		c.backend = flannelBackend{
+			Type:         m["type"].(string),
+			VNI:          m["vni"].(int),
+			Port:         m["port"].(int),
+			RouteTableID: m["route_table_id"].(string),
+		}
+	}
+	nc, err := c.resolve()
+	return c, nc, err
+}
+
+func resourceCoreOSFlannelConfigCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, nc, err := flannelConfigFromResource(d)
+	if err != nil {
+		return err
+	}
+
+	cc, ign := c.configs(nc)
+	d.Set("subnet_len", nc.SubnetLen)
+	d.Set("subnet_min", nc.SubnetMin)
+	d.Set("subnet_max", nc.SubnetMax)
+	d.Set("subnet_count", nc.subnetCount())
+	d.Set("network_config", nc.String())
+	d.Set("etcd_key", c.etcdKey())
+	d.Set("dropin", c.dropIn(nc))
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSFlannelConfigDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSFlannelConfigExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, nc, err := flannelConfigFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	_, ign := c.configs(nc)
+	return hash(ign) == d.Id(), nil
+}
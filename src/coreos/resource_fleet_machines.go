@@ -0,0 +1,141 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSFleetMachines() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSFleetMachinesCreate,
+		Delete: resourceCoreOSFleetMachinesDelete,
+		Exists: resourceCoreOSFleetMachinesExists,
+		Read:   resourceCoreOSFleetMachinesRead,
+
+		Schema: map[string]*schema.Schema{
+			"endpoint": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "fleet API URL or unix:// socket, defaults to the provider's",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"metadata": &schema.Schema{
+				Type:        schema.TypeMap,
+				Description: "only list machines with these metadata values",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"machines": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "matching machines, sorted by ID",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"primary_ip": &schema.Schema{
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"metadata": &schema.Schema{
+							Type:     schema.TypeMap,
+							Computed: true,
+						},
+					},
+				},
+			},
+			"ids": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "IDs of the matching machines",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"primary_ips": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "primary IPs of the matching machines",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func fleetMachinesFilter(d *schema.ResourceData) map[string]string {
+	filter := make(map[string]string)
+	for k, v := range d.Get("metadata").(map[string]interface{}) {
+		filter[k] = fmt.Sprint(v)
+	}
+	return filter
+}
+
+func readFleetMachines(d *schema.ResourceData, meta interface{}) error {
+	c, err := resourceFleetClient(d, meta)
+	if err != nil {
+		return err
+	}
+	all, err := c.machines()
+	if err != nil {
+		return err
+	}
+
+	var (
+		machines []map[string]interface{}
+		ids, ips []string
+	)
+	for _, m := range filterFleetMachines(all, fleetMachinesFilter(d)) {
+		metadata := make(map[string]interface{})
+		for k, v := range m.Metadata {
+			metadata[k] = v
+		}
+		machines = append(machines, map[string]interface{}{
+			"id":         m.ID,
+			"primary_ip": m.PrimaryIP,
+			"metadata":   metadata,
+		})
+		ids = append(ids, m.ID)
+		ips = append(ips, m.PrimaryIP)
+	}
+	d.Set("machines", machines)
+	d.Set("ids", ids)
+	d.Set("primary_ips", ips)
+	return nil
+}
+
+func getFleetMachinesID(d *schema.ResourceData) string {
+	filter := fleetMachinesFilter(d)
+	pairs := make([]string, 0, len(filter))
+	for _, k := range sortedKeys(filter) {
+		pairs = append(pairs, k+"="+filter[k])
+	}
+	return hash(d.Get("endpoint").(string) + "\n" + strings.Join(pairs, ","))
+}
+
+func resourceCoreOSFleetMachinesCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	if err := readFleetMachines(d, meta); err != nil {
+		return err
+	}
+	d.SetId(getFleetMachinesID(d))
+	return nil
+}
+
+func resourceCoreOSFleetMachinesDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSFleetMachinesExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return getFleetMachinesID(d) == d.Id(), nil
+}
+
+func resourceCoreOSFleetMachinesRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	return readFleetMachines(d, meta)
+}
@@ -0,0 +1,220 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSFleetUnit() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSFleetUnitCreate,
+		Delete: resourceCoreOSFleetUnitDelete,
+		Exists: resourceCoreOSFleetUnitExists,
+		Read:   resourceCoreOSFleetUnitRead,
+		Update: resourceCoreOSFleetUnitUpdate,
+
+		Schema: map[string]*schema.Schema{
+			"endpoint": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "fleet API URL or unix:// socket, defaults to the provider's",
+				Optional:    true,
+			},
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "unit name",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"content": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "unit file",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"desired_state": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "inactive, loaded or launched",
+				Default:     "launched",
+				Optional:    true,
+			},
+			"machine_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "X-Fleet MachineID",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"machine_of": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "X-Fleet MachineOf",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"machine_metadata": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "X-Fleet MachineMetadata key=value pairs",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"conflicts": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "X-Fleet Conflicts globs",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"global": &schema.Schema{
+				Type:        schema.TypeBool,
+				Description: "X-Fleet Global",
+				Default:     false,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"current_state": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "state fleet has reached",
+			},
+			"machine": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the machine the unit is scheduled to",
+			},
+			"active_state": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "systemd active state on that machine",
+			},
+		},
+	}
+}
+
+// fleetUnitFromResource builds the unit to submit: the options from the
+// unit file plus the scheduling attributes.
+func fleetUnitFromResource(d *schema.ResourceData) (*fleetUnit, error) {
+	f, err := parseUnitFile(d.Get("content").(string))
+	if err != nil {
+		return nil, err
+	}
+	if v := d.Get("machine_id").(string); v != "" {
+		f.add("X-Fleet", "MachineID", v)
+	}
+	if v := d.Get("machine_of").(string); v != "" {
+		f.add("X-Fleet", "MachineOf", v)
+	}
+	for _, v := range stringList(d.Get("machine_metadata")) {
+		f.add("X-Fleet", "MachineMetadata", v)
+	}
+	for _, v := range stringList(d.Get("conflicts")) {
+		f.add("X-Fleet", "Conflicts", v)
+	}
+	if d.Get("global").(bool) {
+		f.add("X-Fleet", "Global", "true")
+	}
+
+	state := d.Get("desired_state").(string)
+	if !oneOf(state, fleetStates) {
+		return nil, fmt.Errorf("desired_state must be one of %s", strings.Join(fleetStates, ", "))
+	}
+	return &fleetUnit{
+		Name:         d.Get("name").(string),
+		Options:      fleetOptions(f),
+		DesiredState: state,
+	}, nil
+}
+
+func resourceCoreOSFleetUnitCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := resourceFleetClient(d, meta)
+	if err != nil {
+		return err
+	}
+	u, err := fleetUnitFromResource(d)
+	if err != nil {
+		return err
+	}
+	if err := c.createUnit(u); err != nil {
+		return err
+	}
+	d.SetId(u.Name)
+	return readFleetUnit(d, c)
+}
+
+func resourceCoreOSFleetUnitDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	c, err := resourceFleetClient(d, meta)
+	if err != nil {
+		return err
+	}
+	if err := c.destroyUnit(d.Id()); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSFleetUnitExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := resourceFleetClient(d, meta)
+	if err != nil {
+		return false, err
+	}
+	u, err := c.unit(d.Id())
+	return u != nil, err
+}
+
+func resourceCoreOSFleetUnitRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceFleetClient(d, meta)
+	if err != nil {
+		return err
+	}
+	return readFleetUnit(d, c)
+}
+
+func readFleetUnit(d *schema.ResourceData, c *fleetClient) error {
+	u, err := c.unit(d.Id())
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		// destroyed out of band
+		d.SetId("")
+		return nil
+	}
+	d.Set("desired_state", u.DesiredState)
+	d.Set("current_state", u.CurrentState)
+	d.Set("machine", u.MachineID)
+
+	s, err := c.unitState(d.Id())
+	if err != nil {
+		return err
+	}
+	active := ""
+	if s != nil {
+		active = s.SystemdActiveState
+	}
+	d.Set("active_state", active)
+	return nil
+}
+
+func resourceCoreOSFleetUnitUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	c, err := resourceFleetClient(d, meta)
+	if err != nil {
+		return err
+	}
+	if d.HasChange("desired_state") {
+		state := d.Get("desired_state").(string)
+		if !oneOf(state, fleetStates) {
+			return fmt.Errorf("desired_state must be one of %s", strings.Join(fleetStates, ", "))
+		}
+		if err := c.setDesiredState(d.Id(), state); err != nil {
+			return err
+		}
+	}
+	return readFleetUnit(d, c)
+}
@@ -0,0 +1,155 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSLocksmithSemaphore() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSLocksmithSemaphoreCreate,
+		Delete: resourceCoreOSLocksmithSemaphoreDelete,
+		Exists: resourceCoreOSLocksmithSemaphoreExists,
+		Read:   resourceCoreOSLocksmithSemaphoreRead,
+		Update: resourceCoreOSLocksmithSemaphoreUpdate,
+
+		Schema: etcdConnectionSchema(map[string]*schema.Schema{
+			"group": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "locksmith group, empty for the default semaphore",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"max": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "machines allowed to reboot at once",
+				Default:     1,
+				Optional:    true,
+			},
+			"release": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "machine IDs whose locks are released when this list changes",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"window_start": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "locksmith window-start to validate, \"hh:mm\" or \"Day hh:mm\"",
+				Optional:    true,
+			},
+			"window_length": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "locksmith window-length to validate",
+				Optional:    true,
+			},
+			"holders": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "machine IDs holding a lock",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"available": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "locks that can still be taken",
+			},
+		}),
+	}
+}
+
+// applyLocksmithSemaphore sets max and releases the requested holders,
+// when asked to.
+func applyLocksmithSemaphore(d *schema.ResourceData, meta interface{}, release bool) error {
+	if err := checkRebootWindow(d.Get("window_start").(string), d.Get("window_length").(string)); err != nil {
+		return err
+	}
+	max := d.Get("max").(int)
+	if max < 0 {
+		return fmt.Errorf("max must not be negative")
+	}
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	key := locksmithSemaphoreKey(d.Get("group").(string))
+	s, err := c.updateLocksmithSemaphore(key, func(s *locksmithSemaphore) {
+		s.setMax(max)
+		if release {
+			for _, h := range s.release(stringList(d.Get("release"))) {
+				log.Printf("[INFO] released the reboot lock held by %s", h)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	d.SetId(key)
+	setLocksmithSemaphore(d, s)
+	return nil
+}
+
+func setLocksmithSemaphore(d *schema.ResourceData, s *locksmithSemaphore) {
+	d.Set("max", s.Max)
+	d.Set("holders", s.Holders)
+	d.Set("available", s.Semaphore)
+}
+
+func resourceCoreOSLocksmithSemaphoreCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	// an existing semaphore is adopted
+	return applyLocksmithSemaphore(d, meta, len(stringList(d.Get("release"))) > 0)
+}
+
+func resourceCoreOSLocksmithSemaphoreDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+
+	// Back to locksmith's default of one; holders keep their locks.
+	_, err = c.updateLocksmithSemaphore(d.Id(), func(s *locksmithSemaphore) {
+		s.setMax(1)
+	})
+	if err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSLocksmithSemaphoreExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return false, err
+	}
+	s, err := c.locksmithSemaphore(d.Id())
+	return s != nil, err
+}
+
+func resourceCoreOSLocksmithSemaphoreRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	c, err := resourceEtcdClient(d, meta)
+	if err != nil {
+		return err
+	}
+	s, err := c.locksmithSemaphore(d.Id())
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		d.SetId("")
+		return nil
+	}
+	setLocksmithSemaphore(d, s)
+	return nil
+}
+
+func resourceCoreOSLocksmithSemaphoreUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	return applyLocksmithSemaphore(d, meta, d.HasChange("release"))
+}
@@ -0,0 +1,311 @@
+package coreos
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSNetworkdConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSNetworkdConfigCreate,
+		Delete: resourceCoreOSNetworkdConfigDelete,
+		Exists: resourceCoreOSNetworkdConfigExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"network": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: ".network files configuring matched interfaces",
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "file name without the .network suffix",
+							Required:    true,
+						},
+						"match": networkdMatchSchema(),
+						"dhcp": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "yes, no, ipv4 or ipv6",
+							Optional:    true,
+						},
+						"address": &schema.Schema{
+							Type:        schema.TypeList,
+							Description: "static addresses in CIDR notation",
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"gateway": &schema.Schema{
+							Type:        schema.TypeList,
+							Description: "gateways, each within one of the address prefixes",
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"dns": &schema.Schema{
+							Type:        schema.TypeList,
+							Description: "DNS servers",
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"domains": &schema.Schema{
+							Type:        schema.TypeList,
+							Description: "DNS search domains",
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"ntp": &schema.Schema{
+							Type:        schema.TypeList,
+							Description: "NTP servers",
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"vlan": &schema.Schema{
+							Type:        schema.TypeList,
+							Description: "VLAN netdevs to create on the interface",
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"bond": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "bond netdev the interface is enslaved to",
+							Optional:    true,
+						},
+						"bridge": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "bridge netdev the interface is added to",
+							Optional:    true,
+						},
+						"mtu": &schema.Schema{
+							Type:        schema.TypeInt,
+							Description: "interface MTU in bytes",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"netdev": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: ".netdev files creating virtual devices",
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "device name, also used as the file name",
+							Required:    true,
+						},
+						"kind": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "bond, bridge or vlan",
+							Required:    true,
+						},
+						"mtu": &schema.Schema{
+							Type:        schema.TypeInt,
+							Description: "device MTU in bytes",
+							Optional:    true,
+						},
+						"mac_address": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "device MAC address",
+							Optional:    true,
+						},
+						"vlan_id": &schema.Schema{
+							Type:        schema.TypeInt,
+							Description: "VLAN id, for vlan devices",
+							Optional:    true,
+						},
+						"bond_mode": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "bonding mode, for bond devices",
+							Optional:    true,
+						},
+						"bond_miimon": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "MII link monitoring interval, for bond devices",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"link": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: ".link files renaming or tuning physical devices",
+				Optional:    true,
+				ForceNew:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "file name without the .link suffix",
+							Required:    true,
+						},
+						"match": networkdMatchSchema(),
+						"link_name": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "interface name to assign",
+							Optional:    true,
+						},
+						"mtu": &schema.Schema{
+							Type:        schema.TypeInt,
+							Description: "interface MTU in bytes",
+							Optional:    true,
+						},
+						"mac_address": &schema.Schema{
+							Type:        schema.TypeString,
+							Description: "MAC address to assign",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"files": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "rendered files keyed by name",
+			},
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "cloud-config installing the files as coreos.units",
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Ignition config installing the files as networkd units",
+			},
+		},
+	}
+}
+
+func networkdMatchSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Description: "[Match] section selecting the devices",
+		Required:    true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": &schema.Schema{
+					Type:        schema.TypeString,
+					Description: "interface name glob",
+					Optional:    true,
+				},
+				"mac_address": &schema.Schema{
+					Type:        schema.TypeString,
+					Description: "hardware address",
+					Optional:    true,
+				},
+				"driver": &schema.Schema{
+					Type:        schema.TypeString,
+					Description: "driver name glob",
+					Optional:    true,
+				},
+				"type": &schema.Schema{
+					Type:        schema.TypeString,
+					Description: "device type, e.g. ether or vlan",
+					Optional:    true,
+				},
+				"path": &schema.Schema{
+					Type:        schema.TypeString,
+					Description: "persistent device path glob",
+					Optional:    true,
+				},
+			},
+		},
+	}
+}
+
+func networkdMatchFromConfig(v interface{}) networkdMatch {
+	var m networkdMatch
+	blocks, _ := v.([]interface{})
+	for _, b := range blocks {
+		block, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		m.name, _ = block["name"].(string)
+		m.macAddress, _ = block["mac_address"].(string)
+		m.driver, _ = block["driver"].(string)
+		m.typ, _ = block["type"].(string)
+		m.path, _ = block["path"].(string)
+	}
+	return m
+}
+
+func networkdConfigFromResource(d *schema.ResourceData) (*networkdConfig, error) {
+	c := &networkdConfig{}
+	for _, v := range d.Get("network").([]interface{}) {
+		m := v.(map[string]interface{})
+		c.networks = append(c.networks, networkdNetwork{
+			name:    m["name"].(string),
+			match:   networkdMatchFromConfig(m["match"]),
+			dhcp:    m["dhcp"].(string),
+			address: stringList(m["address"]),
+			gateway: stringList(m["gateway"]),
+			dns:     stringList(m["dns"]),
+			domains: stringList(m["domains"]),
+			ntp:     stringList(m["ntp"]),
+			vlan:    stringList(m["vlan"]),
+			bond:    m["bond"].(string),
+			bridge:  m["bridge"].(string),
+			mtu:     m["mtu"].(int),
+		})
+	}
+	for _, v := range d.Get("netdev").([]interface{}) {
+		m := v.(map[string]interface{})
+		c.netdevs = append(c.netdevs, networkdNetdev{
+			name:       m["name"].(string),
+			kind:       m["kind"].(string),
+			mtu:        m["mtu"].(int),
+			macAddress: m["mac_address"].(string),
+			vlanID:     m["vlan_id"].(int),
+			bondMode:   m["bond_mode"].(string),
+			miimon:     m["bond_miimon"].(string),
+		})
+	}
+	for _, v := range d.Get("link").([]interface{}) {
+		m := v.(map[string]interface{})
+		c.links = append(c.links, networkdLink{
+			name:       m["name"].(string),
+			match:      networkdMatchFromConfig(m["match"]),
+			linkName:   m["link_name"].(string),
+			mtu:        m["mtu"].(int),
+			macAddress: m["mac_address"].(string),
+		})
+	}
+	return c, c.validate()
+}
+
+func resourceCoreOSNetworkdConfigCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := networkdConfigFromResource(d)
+	if err != nil {
+		return err
+	}
+
+	cc, ign := c.configs()
+	d.Set("files", c.files())
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSNetworkdConfigDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSNetworkdConfigExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := networkdConfigFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	_, ign := c.configs()
+	return hash(ign) == d.Id(), nil
+}
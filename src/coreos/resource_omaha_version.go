@@ -0,0 +1,172 @@
+package coreos
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSOmahaVersion() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSOmahaVersionCreate,
+		Delete: resourceCoreOSOmahaVersionDelete,
+		Exists: resourceCoreOSOmahaVersionExists,
+		Read:   resourceCoreOSOmahaVersionRead,
+
+		Schema: map[string]*schema.Schema{
+			"server": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Omaha update server URL, defaults to the distribution's public one",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"distribution": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "coreos or flatcar, defaults to the provider's distribution",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"app_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Omaha application ID",
+				Default:     omahaAppID,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"group": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "update group, sent as the Omaha track",
+				Default:     "stable",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"current_version": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "version the check claims to be running",
+				Default:     "0.0.0",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"board": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "board the check claims to be running on",
+				Default:     "amd64-usr",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"machine_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "machine ID the check is sent as, which servers may use to stage rollouts",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"update_available": &schema.Schema{
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "whether the server offered an update",
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "offered version",
+			},
+			"url": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "payload URL",
+			},
+			"size": &schema.Schema{
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "payload size in bytes",
+			},
+			"sha256": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "hex encoded payload sha256 digest",
+			},
+		},
+	}
+}
+
+func resourceCoreOSOmahaVersionCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	server, err := omahaServer(d, meta)
+	if err != nil {
+		return err
+	}
+	if err := readOmahaVersion(d, server); err != nil {
+		return err
+	}
+	d.SetId(getOmahaVersionID(d, server))
+	return nil
+}
+
+func resourceCoreOSOmahaVersionDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSOmahaVersionExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	server, err := omahaServer(d, meta)
+	if err != nil {
+		return false, err
+	}
+	return getOmahaVersionID(d, server) == d.Id(), nil
+}
+
+func resourceCoreOSOmahaVersionRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	server, err := omahaServer(d, meta)
+	if err != nil {
+		return err
+	}
+	return readOmahaVersion(d, server)
+}
+
+func omahaServer(d *schema.ResourceData, meta interface{}) (string, error) {
+	if s := d.Get("server").(string); s != "" {
+		return s, nil
+	}
+	dist, err := resourceDistribution(d, meta)
+	if err != nil {
+		return "", err
+	}
+	return dist.updateServer, nil
+}
+
+func readOmahaVersion(d *schema.ResourceData, server string) error {
+	u, err := omahaCheck(server, omahaApp{
+		AppID:     d.Get("app_id").(string),
+		Version:   d.Get("current_version").(string),
+		Track:     d.Get("group").(string),
+		MachineID: d.Get("machine_id").(string),
+		Board:     d.Get("board").(string),
+	})
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		u = &omahaUpdate{}
+	}
+	d.Set("update_available", u.Version != "")
+	d.Set("version", u.Version)
+	d.Set("url", u.URL)
+	d.Set("size", int(u.Size))
+	d.Set("sha256", u.Sha256)
+	return nil
+}
+
+func getOmahaVersionID(d *schema.ResourceData, server string) string {
+	return hash(strings.Join([]string{
+		server,
+		d.Get("app_id").(string),
+		d.Get("group").(string),
+		d.Get("current_version").(string),
+		d.Get("board").(string),
+		d.Get("machine_id").(string),
+	}, "\n"))
+}
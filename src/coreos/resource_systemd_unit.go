@@ -0,0 +1,213 @@
+package coreos
+
+import (
+	"log"
+	"sort"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSSystemdUnit() *schema.Resource {
+	s := unitSectionsSchema()
+	s["name"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "unit name, including its type suffix",
+		Required:    true,
+		ForceNew:    true,
+	}
+	s["enable"] = &schema.Schema{
+		Type:        schema.TypeBool,
+		Description: "enable the unit when it is installed",
+		Default:     false,
+		Optional:    true,
+		ForceNew:    true,
+	}
+
+	dropin := unitSectionsSchema()
+	dropin["name"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "drop-in file name, ending in .conf",
+		Required:    true,
+	}
+	s["dropin"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Description: "drop-ins to install alongside the unit",
+		Optional:    true,
+		ForceNew:    true,
+		Elem:        &schema.Resource{Schema: dropin},
+	}
+
+	for k, v := range unitOutputsSchema() {
+		s[k] = v
+	}
+	s["warnings"] = &schema.Schema{
+		Type:        schema.TypeList,
+		Computed:    true,
+		Description: "problems found while linting the unit",
+		Elem:        &schema.Schema{Type: schema.TypeString},
+	}
+
+	return &schema.Resource{
+		Create: resourceCoreOSSystemdUnitCreate,
+		Delete: resourceCoreOSSystemdUnitDelete,
+		Exists: resourceCoreOSSystemdUnitExists,
+		Read:   resourceLocalRead,
+
+		Schema: s,
+	}
+}
+
+// unitSectionsSchema returns one optional block per unit section, each with
+// the section's typed directives and an "extra" map for anything else.
+func unitSectionsSchema() map[string]*schema.Schema {
+	s := make(map[string]*schema.Schema)
+	for _, spec := range unitSections {
+		fields := map[string]*schema.Schema{
+			"extra": &schema.Schema{
+				Type:        schema.TypeMap,
+				Description: "directives without a typed attribute",
+				Optional:    true,
+			},
+		}
+		for _, d := range spec.directives {
+			f := &schema.Schema{Description: d.name, Optional: true}
+			switch d.kind {
+			case directiveString:
+				f.Type = schema.TypeString
+			case directiveList:
+				f.Type = schema.TypeList
+				f.Elem = &schema.Schema{Type: schema.TypeString}
+			case directiveBool:
+				f.Type = schema.TypeBool
+			}
+			fields[d.attr] = f
+		}
+		s[spec.attr] = &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "[" + spec.name + "] section",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Resource{Schema: fields},
+		}
+	}
+	return s
+}
+
+// unitOutputsSchema is shared by every resource that renders systemd units.
+func unitOutputsSchema() map[string]*schema.Schema {
+	return map[string]*schema.Schema{
+		"content": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "rendered unit file",
+		},
+		"cloud_config": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "cloud-config installing the unit",
+		},
+		"ignition": &schema.Schema{
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Ignition config installing the unit",
+		},
+	}
+}
+
+// unitFileFromConfig builds a unit file from the section blocks in m.
+func unitFileFromConfig(m map[string]interface{}) *unitFile {
+	f := &unitFile{}
+	for _, spec := range unitSections {
+		blocks, _ := m[spec.attr].([]interface{})
+		for _, b := range blocks {
+			block, ok := b.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, d := range spec.directives {
+				switch d.kind {
+				case directiveString:
+					if v, _ := block[d.attr].(string); v != "" {
+						f.add(spec.name, d.name, v)
+					}
+				case directiveList:
+					vs, _ := block[d.attr].([]interface{})
+					for _, v := range vs {
+						f.add(spec.name, d.name, v.(string))
+					}
+				case directiveBool:
+					// "true" rather than "yes" as fleet only parses the former
+					if v, _ := block[d.attr].(bool); v {
+						f.add(spec.name, d.name, "true")
+					}
+				}
+			}
+
+			extra, _ := block["extra"].(map[string]interface{})
+			keys := make([]string, 0, len(extra))
+			for k := range extra {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				f.add(spec.name, k, extra[k].(string))
+			}
+		}
+	}
+	return f
+}
+
+func systemdUnitFromResource(d *schema.ResourceData) *systemdUnit {
+	m := make(map[string]interface{})
+	for _, spec := range unitSections {
+		m[spec.attr] = d.Get(spec.attr)
+	}
+
+	u := &systemdUnit{
+		name:   d.Get("name").(string),
+		enable: d.Get("enable").(bool),
+		file:   unitFileFromConfig(m),
+	}
+	for _, v := range d.Get("dropin").([]interface{}) {
+		dm := v.(map[string]interface{})
+		u.dropins = append(u.dropins, systemdDropin{
+			name: dm["name"].(string),
+			file: unitFileFromConfig(dm),
+		})
+	}
+	return u
+}
+
+// setUnitOutputs records the rendered forms of u and returns the ID they
+// hash to.
+func setUnitOutputs(d *schema.ResourceData, units ...*systemdUnit) string {
+	cc, ign := unitConfigs(units...)
+	d.Set("content", units[0].content())
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	return hash(ign)
+}
+
+func resourceCoreOSSystemdUnitCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	u := systemdUnitFromResource(d)
+	warnings := u.lint()
+	for _, w := range warnings {
+		log.Printf("[WARN] %s", w)
+	}
+	d.Set("warnings", warnings)
+	d.SetId(setUnitOutputs(d, u))
+	return nil
+}
+
+func resourceCoreOSSystemdUnitDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSSystemdUnitExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	_, ign := unitConfigs(systemdUnitFromResource(d))
+	return hash(ign) == d.Id(), nil
+}
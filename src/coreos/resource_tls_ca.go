@@ -0,0 +1,177 @@
+package coreos
+
+import (
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSTLSCA() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSTLSCACreate,
+		Delete: resourceCoreOSTLSCADelete,
+		Exists: resourceCoreOSTLSCAExists,
+		Read:   resourceLocalRead,
+
+		Schema: tlsSchema(map[string]*schema.Schema{
+			"validity_hours": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "hours the CA is valid for",
+				Default:     87600,
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"cert_path": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "where the rendered configs write the CA certificate, empty for nowhere",
+				Default:     "/etc/ssl/etcd/ca.pem",
+				Optional:    true,
+				ForceNew:    true,
+			},
+		}),
+	}
+}
+
+// tlsSchema adds the key, subject, renewal and output attributes shared by
+// coreos_tls_ca and coreos_tls_cert to s.
+func tlsSchema(s map[string]*schema.Schema) map[string]*schema.Schema {
+	s["key_algorithm"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "RSA or ECDSA",
+		Default:     "RSA",
+		Optional:    true,
+		ForceNew:    true,
+	}
+	s["rsa_bits"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Description: "RSA key size",
+		Default:     2048,
+		Optional:    true,
+		ForceNew:    true,
+	}
+	s["ecdsa_curve"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "P256, P384 or P521",
+		Default:     "P256",
+		Optional:    true,
+		ForceNew:    true,
+	}
+	s["common_name"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "subject common name",
+		Required:    true,
+		ForceNew:    true,
+	}
+	s["organization"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "subject organization",
+		Optional:    true,
+		ForceNew:    true,
+	}
+	s["early_renewal_hours"] = &schema.Schema{
+		Type:        schema.TypeInt,
+		Description: "hours before expiry the certificate is replaced",
+		Default:     0,
+		Optional:    true,
+		ForceNew:    true,
+	}
+	s["file_owner"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Description: "user, or user:group, owning the written files",
+		Optional:    true,
+		ForceNew:    true,
+	}
+	s["private_key_pem"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "PEM encoded private key",
+	}
+	s["cert_pem"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "PEM encoded certificate",
+	}
+	s["validity_end_time"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "RFC 3339 time the certificate expires",
+	}
+	s["cloud_config"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "cloud-config write_files entries for the files",
+	}
+	s["ignition"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Description: "Ignition config writing the files",
+	}
+	return s
+}
+
+func tlsKeySpecFromResource(d *schema.ResourceData) tlsKeySpec {
+	return tlsKeySpec{
+		algorithm: d.Get("key_algorithm").(string),
+		rsaBits:   d.Get("rsa_bits").(int),
+		curve:     d.Get("ecdsa_curve").(string),
+	}
+}
+
+// tlsCertExists reports false once the certificate is due for renewal, so
+// the next apply replaces it.
+func tlsCertExists(d *schema.ResourceData) bool {
+	cert, err := parseCertificate(d.Get("cert_pem").(string))
+	if err != nil {
+		return false
+	}
+	renewal := time.Duration(d.Get("early_renewal_hours").(int)) * time.Hour
+	return time.Now().Add(renewal).Before(cert.NotAfter)
+}
+
+func resourceCoreOSTLSCACreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	key, err := tlsKeySpecFromResource(d).generate()
+	if err != nil {
+		return err
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+	certPEM, err := newCACert(key, &tlsCertSpec{
+		commonName:   d.Get("common_name").(string),
+		organization: d.Get("organization").(string),
+		validity:     time.Duration(d.Get("validity_hours").(int)) * time.Hour,
+	})
+	if err != nil {
+		return err
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+
+	// the CA key is only in the state, never in the rendered configs
+	cc, ign := tlsFileConfigs([]tlsFile{
+		{d.Get("cert_path").(string), certPEM, 0644},
+	}, d.Get("file_owner").(string))
+	d.Set("private_key_pem", keyPEM)
+	d.Set("cert_pem", certPEM)
+	d.Set("validity_end_time", cert.NotAfter.Format(time.RFC3339))
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(cert.SerialNumber.String())
+	return nil
+}
+
+func resourceCoreOSTLSCADelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSTLSCAExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return tlsCertExists(d), nil
+}
@@ -0,0 +1,137 @@
+package coreos
+
+import (
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSTLSCert() *schema.Resource {
+	s := tlsSchema(map[string]*schema.Schema{
+		"ca_cert_pem": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "PEM encoded certificate of the signing CA",
+			Required:    true,
+			ForceNew:    true,
+		},
+		"ca_private_key_pem": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "PEM encoded private key of the signing CA",
+			Required:    true,
+			ForceNew:    true,
+		},
+		"profile": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "server, client or peer",
+			Default:     "server",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"dns_names": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "DNS subject alternative names",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"ip_addresses": &schema.Schema{
+			Type:        schema.TypeList,
+			Description: "IP subject alternative names",
+			Optional:    true,
+			ForceNew:    true,
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"validity_hours": &schema.Schema{
+			Type:        schema.TypeInt,
+			Description: "hours the certificate is valid for, capped at the CA's expiry",
+			Default:     8760,
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"cert_path": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "where the rendered configs write the certificate",
+			Default:     "/etc/ssl/etcd/cert.pem",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"key_path": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "where the rendered configs write the private key",
+			Default:     "/etc/ssl/etcd/key.pem",
+			Optional:    true,
+			ForceNew:    true,
+		},
+		"ca_cert_path": &schema.Schema{
+			Type:        schema.TypeString,
+			Description: "where the rendered configs write the CA certificate, empty for nowhere",
+			Default:     "/etc/ssl/etcd/ca.pem",
+			Optional:    true,
+			ForceNew:    true,
+		},
+	})
+	// the paths default to etcd's, and etcd runs as the etcd user
+	s["file_owner"].Default = "etcd"
+
+	return &schema.Resource{
+		Create: resourceCoreOSTLSCertCreate,
+		Delete: resourceCoreOSTLSCertDelete,
+		Exists: resourceCoreOSTLSCertExists,
+		Read:   resourceLocalRead,
+
+		Schema: s,
+	}
+}
+
+func resourceCoreOSTLSCertCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	key, err := tlsKeySpecFromResource(d).generate()
+	if err != nil {
+		return err
+	}
+	keyPEM, err := encodePrivateKey(key)
+	if err != nil {
+		return err
+	}
+	caPEM := d.Get("ca_cert_pem").(string)
+	certPEM, err := newCert(caPEM, d.Get("ca_private_key_pem").(string), key, &tlsCertSpec{
+		commonName:   d.Get("common_name").(string),
+		organization: d.Get("organization").(string),
+		dnsNames:     stringList(d.Get("dns_names")),
+		ipAddresses:  stringList(d.Get("ip_addresses")),
+		profile:      d.Get("profile").(string),
+		validity:     time.Duration(d.Get("validity_hours").(int)) * time.Hour,
+	})
+	if err != nil {
+		return err
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+
+	cc, ign := tlsFileConfigs([]tlsFile{
+		{d.Get("ca_cert_path").(string), caPEM, 0644},
+		{d.Get("cert_path").(string), certPEM, 0644},
+		{d.Get("key_path").(string), keyPEM, 0600},
+	}, d.Get("file_owner").(string))
+	d.Set("private_key_pem", keyPEM)
+	d.Set("cert_pem", certPEM)
+	d.Set("validity_end_time", cert.NotAfter.Format(time.RFC3339))
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(cert.SerialNumber.String())
+	return nil
+}
+
+func resourceCoreOSTLSCertDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSTLSCertExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return tlsCertExists(d), nil
+}
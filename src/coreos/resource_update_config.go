@@ -0,0 +1,145 @@
+package coreos
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSUpdateConfig() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSUpdateConfigCreate,
+		Delete: resourceCoreOSUpdateConfigDelete,
+		Exists: resourceCoreOSUpdateConfigExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"group": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "update group or channel",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"server": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Omaha update server URL",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"reboot_strategy": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "etcd-lock, reboot, off or best-effort",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"window_start": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "start of the reboot window, \"hh:mm\" or \"Day hh:mm\"",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"window_length": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "length of the reboot window",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"locksmith_group": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "locksmith group sharing a reboot semaphore",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"etcd_endpoints": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "etcd client URLs locksmithd takes the reboot lock from",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"etcd_cafile": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "path of the CA certificate etcd is verified with",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"etcd_certfile": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "path of locksmithd's etcd client certificate",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"etcd_keyfile": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "path of locksmithd's etcd client key",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"update_conf": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "contents of /etc/coreos/update.conf",
+			},
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "cloud-config with the coreos.update and coreos.locksmith sections",
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Ignition config writing update.conf and configuring locksmithd",
+			},
+		},
+	}
+}
+
+func updateConfigFromResource(d *schema.ResourceData) (*updateConfig, error) {
+	u := &updateConfig{
+		group:          d.Get("group").(string),
+		server:         d.Get("server").(string),
+		rebootStrategy: d.Get("reboot_strategy").(string),
+		locksmith: locksmithConfig{
+			windowStart:  d.Get("window_start").(string),
+			windowLength: d.Get("window_length").(string),
+			group:        d.Get("locksmith_group").(string),
+			endpoints:    strings.Join(stringList(d.Get("etcd_endpoints")), ","),
+			caFile:       d.Get("etcd_cafile").(string),
+			certFile:     d.Get("etcd_certfile").(string),
+			keyFile:      d.Get("etcd_keyfile").(string),
+		},
+	}
+	return u, u.validate()
+}
+
+func resourceCoreOSUpdateConfigCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	u, err := updateConfigFromResource(d)
+	if err != nil {
+		return err
+	}
+
+	cc, ign := u.configs()
+	d.Set("update_conf", u.updateConf())
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSUpdateConfigDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSUpdateConfigExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	u, err := updateConfigFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	_, ign := u.configs()
+	return hash(ign) == d.Id(), nil
+}
@@ -0,0 +1,111 @@
+package coreos
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceCoreOSUpdateGroup() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSUpdateGroupCreate,
+		Delete: resourceCoreOSUpdateGroupDelete,
+		Exists: resourceCoreOSUpdateGroupExists,
+		Read:   resourceCoreOSUpdateGroupRead,
+		Update: resourceCoreOSUpdateGroupUpdate,
+
+		Schema: map[string]*schema.Schema{
+			"server": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "base URL of the update-server",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"admin_token": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "token the update-server's admin API was started with, kept in the state in plaintext",
+				Optional:    true,
+			},
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "group name, what machines set GROUP to",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"version": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "version offered to the group, the latest payload when empty",
+				Optional:    true,
+			},
+			"rollout_percent": &schema.Schema{
+				Type:        schema.TypeInt,
+				Description: "share of the group's machines offered the version, 0 pauses the rollout",
+				Default:     100,
+				Optional:    true,
+			},
+		},
+	}
+}
+
+func putUpdateGroup(d *schema.ResourceData) error {
+	g := &updateGroup{
+		Version:        d.Get("version").(string),
+		RolloutPercent: d.Get("rollout_percent").(int),
+	}
+	if g.RolloutPercent < 0 || g.RolloutPercent > 100 {
+		return fmt.Errorf("rollout_percent must be between 0 and 100")
+	}
+	server, token := d.Get("server").(string), d.Get("admin_token").(string)
+	if token != "" && !strings.HasPrefix(server, "https://") {
+		log.Printf("[WARN] admin_token is sent to %s without TLS", server)
+	}
+	_, err := updateGroupRequest("PUT", server, token, d.Get("name").(string), g, nil)
+	return err
+}
+
+func resourceCoreOSUpdateGroupCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	if err := putUpdateGroup(d); err != nil {
+		return err
+	}
+	d.SetId(d.Get("name").(string))
+	return nil
+}
+
+func resourceCoreOSUpdateGroupDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	if _, err := updateGroupRequest("DELETE", d.Get("server").(string), d.Get("admin_token").(string), d.Id(), nil, nil); err != nil {
+		return err
+	}
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSUpdateGroupExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	return updateGroupRequest("GET", d.Get("server").(string), d.Get("admin_token").(string), d.Id(), nil, nil)
+}
+
+func resourceCoreOSUpdateGroupRead(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling read")
+	var g updateGroup
+	found, err := updateGroupRequest("GET", d.Get("server").(string), d.Get("admin_token").(string), d.Id(), nil, &g)
+	if err != nil {
+		return err
+	}
+	if !found {
+		d.SetId("")
+		return nil
+	}
+	d.Set("name", g.ID)
+	d.Set("version", g.Version)
+	d.Set("rollout_percent", g.RolloutPercent)
+	return nil
+}
+
+func resourceCoreOSUpdateGroupUpdate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling update")
+	return putUpdateGroup(d)
+}
@@ -0,0 +1,176 @@
+package coreos
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// passwordStateKey is the provider's password_key. StateFunc isn't given
+// the provider's meta, so providerConfigure hands the key over here. It
+// is shared by every provider in the process, so they must agree on it.
+var passwordStateKey struct {
+	sync.Mutex
+	key string
+}
+
+// setPasswordStateKey records key, and fails if another provider set a
+// different one; a provider without a key leaves it alone.
+func setPasswordStateKey(key string) error {
+	passwordStateKey.Lock()
+	defer passwordStateKey.Unlock()
+	if key == "" {
+		return nil
+	}
+	if passwordStateKey.key != "" && passwordStateKey.key != key {
+		return fmt.Errorf("password_key differs from another coreos provider's, every provider has to use the same one")
+	}
+	passwordStateKey.key = key
+	return nil
+}
+
+func resourceCoreOSUser() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceCoreOSUserCreate,
+		Delete: resourceCoreOSUserDelete,
+		Exists: resourceCoreOSUserExists,
+		Read:   resourceLocalRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "user name",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"groups": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "supplementary groups, e.g. sudo or docker",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"shell": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "login shell",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"ssh_authorized_keys": &schema.Schema{
+				Type:        schema.TypeList,
+				Description: "authorized_keys lines",
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"password": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "plaintext password, hashed at apply time and kept out of the state; needs the provider's password_key",
+				Optional:    true,
+				ForceNew:    true,
+				StateFunc:   userPasswordState,
+			},
+			"password_hash": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-512 crypt hash of the password",
+			},
+			"ssh_key_fingerprints": &schema.Schema{
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "SHA256 fingerprints of ssh_authorized_keys",
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"cloud_config": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "cloud-config users entry",
+			},
+			"ignition": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Ignition config passwd entry",
+			},
+		},
+	}
+}
+
+// userPasswordState is what the state holds instead of the password: an
+// HMAC of it under the provider's password_key, so changing the password
+// shows up in plans while the state is no help guessing it. Without a key
+// it is a marker that keeps the password in the diff, for Create to refuse.
+func userPasswordState(v interface{}) string {
+	p, _ := v.(string)
+	passwordStateKey.Lock()
+	key := passwordStateKey.key
+	passwordStateKey.Unlock()
+	switch {
+	case p == "":
+		return ""
+	case key == "":
+		return "no-password-key"
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(p))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func userConfigFromResource(d *schema.ResourceData) (*userConfig, error) {
+	c := &userConfig{
+		name:    d.Get("name").(string),
+		groups:  stringList(d.Get("groups")),
+		shell:   d.Get("shell").(string),
+		sshKeys: stringList(d.Get("ssh_authorized_keys")),
+	}
+	return c, c.validate()
+}
+
+func resourceCoreOSUserCreate(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling create")
+	c, err := userConfigFromResource(d)
+	if err != nil {
+		return err
+	}
+
+	// the plaintext is only around while applying, a fresh salt is fine
+	if p := d.Get("password").(string); p != "" {
+		if c, ok := meta.(*providerConfig); !ok || c.passwordKey == "" {
+			return fmt.Errorf("password needs the provider's password_key, or COREOS_PASSWORD_KEY, to keep it out of the state")
+		}
+		salt, err := newCryptSalt()
+		if err != nil {
+			return err
+		}
+		c.passwordHash = sha512Crypt(p, salt, sha512CryptRounds)
+	}
+
+	cc, ign := c.configs()
+	d.Set("password_hash", c.passwordHash)
+	d.Set("ssh_key_fingerprints", c.fingerprints())
+	d.Set("cloud_config", cc)
+	d.Set("ignition", ign)
+	d.SetId(hash(ign))
+	return nil
+}
+
+func resourceCoreOSUserDelete(d *schema.ResourceData, meta interface{}) error {
+	log.Println("[INFO] calling delete")
+	d.SetId("")
+	return nil
+}
+
+func resourceCoreOSUserExists(d *schema.ResourceData, meta interface{}) (bool, error) {
+	log.Println("[INFO] calling exists")
+	c, err := userConfigFromResource(d)
+	if err != nil {
+		return false, err
+	}
+	c.passwordHash = d.Get("password_hash").(string)
+	_, ign := c.configs()
+	return hash(ign) == d.Id(), nil
+}
@@ -0,0 +1,399 @@
+package coreos
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+type (
+	// unitDirective describes a directive that can be set through a typed
+	// attribute. Attributes are the snake_case form of the directive.
+	unitDirective struct {
+		name string
+		attr string
+		kind directiveKind
+	}
+
+	// unitSectionSpec lists the typed directives of one INI section, in the
+	// order they are rendered.
+	unitSectionSpec struct {
+		name       string
+		attr       string
+		directives []unitDirective
+	}
+
+	unitEntry struct {
+		key   string
+		value string
+	}
+
+	unitSection struct {
+		name    string
+		entries []unitEntry
+	}
+
+	// unitFile is a parsed or generated systemd unit or drop-in.
+	unitFile struct {
+		sections []unitSection
+	}
+
+	systemdDropin struct {
+		name string
+		file *unitFile
+	}
+
+	// systemdUnit is a unit plus its drop-ins, ready to be rendered into
+	// cloud-config or Ignition.
+	systemdUnit struct {
+		name    string
+		enable  bool
+		file    *unitFile
+		dropins []systemdDropin
+	}
+)
+
+type directiveKind int
+
+const (
+	directiveString directiveKind = iota
+	directiveList
+	directiveBool
+)
+
+// unitSections is the order sections are rendered in.
+var unitSections = []unitSectionSpec{
+	{"Unit", "unit", []unitDirective{
+		{"Description", "description", directiveString},
+		{"Documentation", "documentation", directiveList},
+		{"Requires", "requires", directiveList},
+		{"Wants", "wants", directiveList},
+		{"BindsTo", "binds_to", directiveList},
+		{"PartOf", "part_of", directiveList},
+		{"Conflicts", "conflicts", directiveList},
+		{"Before", "before", directiveList},
+		{"After", "after", directiveList},
+		{"ConditionPathExists", "condition_path_exists", directiveList},
+		{"DefaultDependencies", "default_dependencies", directiveString},
+	}},
+	{"Service", "service", []unitDirective{
+		{"Type", "type", directiveString},
+		{"RemainAfterExit", "remain_after_exit", directiveBool},
+		{"User", "user", directiveString},
+		{"Group", "group", directiveString},
+		{"WorkingDirectory", "working_directory", directiveString},
+		{"Environment", "environment", directiveList},
+		{"EnvironmentFile", "environment_file", directiveList},
+		{"TimeoutStartSec", "timeout_start_sec", directiveString},
+		{"TimeoutStopSec", "timeout_stop_sec", directiveString},
+		{"ExecStartPre", "exec_start_pre", directiveList},
+		{"ExecStart", "exec_start", directiveList},
+		{"ExecStartPost", "exec_start_post", directiveList},
+		{"ExecReload", "exec_reload", directiveList},
+		{"ExecStop", "exec_stop", directiveList},
+		{"ExecStopPost", "exec_stop_post", directiveList},
+		{"KillMode", "kill_mode", directiveString},
+		{"Restart", "restart", directiveString},
+		{"RestartSec", "restart_sec", directiveString},
+	}},
+	{"Timer", "timer", []unitDirective{
+		{"OnActiveSec", "on_active_sec", directiveString},
+		{"OnBootSec", "on_boot_sec", directiveString},
+		{"OnStartupSec", "on_startup_sec", directiveString},
+		{"OnUnitActiveSec", "on_unit_active_sec", directiveString},
+		{"OnUnitInactiveSec", "on_unit_inactive_sec", directiveString},
+		{"OnCalendar", "on_calendar", directiveList},
+		{"AccuracySec", "accuracy_sec", directiveString},
+		{"RandomizedDelaySec", "randomized_delay_sec", directiveString},
+		{"Persistent", "persistent", directiveBool},
+		{"Unit", "unit", directiveString},
+	}},
+	{"Mount", "mount", []unitDirective{
+		{"What", "what", directiveString},
+		{"Where", "where", directiveString},
+		{"Type", "type", directiveString},
+		{"Options", "options", directiveString},
+		{"DirectoryMode", "directory_mode", directiveString},
+		{"TimeoutSec", "timeout_sec", directiveString},
+	}},
+	{"Install", "install", []unitDirective{
+		{"Alias", "alias", directiveList},
+		{"WantedBy", "wanted_by", directiveList},
+		{"RequiredBy", "required_by", directiveList},
+		{"Also", "also", directiveList},
+	}},
+	{"X-Fleet", "x_fleet", []unitDirective{
+		{"MachineID", "machine_id", directiveString},
+		{"MachineOf", "machine_of", directiveString},
+		{"MachineMetadata", "machine_metadata", directiveList},
+		{"Conflicts", "conflicts", directiveList},
+		{"Global", "global", directiveBool},
+	}},
+}
+
+// unitTypes are the unit name suffixes systemd knows.
+var unitTypes = []string{
+	".automount", ".device", ".mount", ".path", ".scope", ".service",
+	".slice", ".socket", ".swap", ".target", ".timer",
+}
+
+// directiveName reports whether key is shaped like a systemd directive.
+// Only the shape is checked: systemd has far more directives than the
+// typed attributes, and anything in extra is passed through as given.
+func directiveName(key string) bool {
+	if key == "" || key[0] < 'A' || key[0] > 'Z' {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *unitFile) section(name string) *unitSection {
+	for i := range f.sections {
+		if f.sections[i].name == name {
+			return &f.sections[i]
+		}
+	}
+	return nil
+}
+
+// add appends a directive, creating its section if needed. Sections are
+// kept in unitSections order.
+func (f *unitFile) add(section, key, value string) {
+	s := f.section(section)
+	if s == nil {
+		f.sections = append(f.sections, unitSection{name: section})
+		sort.SliceStable(f.sections, func(i, j int) bool {
+			return unitSectionOrder(f.sections[i].name) < unitSectionOrder(f.sections[j].name)
+		})
+		s = f.section(section)
+	}
+	s.entries = append(s.entries, unitEntry{key, value})
+}
+
+func unitSectionOrder(name string) int {
+	for i, s := range unitSections {
+		if s.name == name {
+			return i
+		}
+	}
+	return len(unitSections)
+}
+
+// values returns every value set for key in section.
+func (f *unitFile) values(section, key string) []string {
+	s := f.section(section)
+	if s == nil {
+		return nil
+	}
+	var out []string
+	for _, e := range s.entries {
+		if e.key == key {
+			out = append(out, e.value)
+		}
+	}
+	return out
+}
+
+func (f *unitFile) String() string {
+	var buf bytes.Buffer
+	for i, s := range f.sections {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[%s]\n", s.name)
+		for _, e := range s.entries {
+			fmt.Fprintf(&buf, "%s=%s\n", e.key, e.value)
+		}
+	}
+	return buf.String()
+}
+
+// parseUnitFile reads a unit file. Comments and blank lines are dropped
+// and continuation lines are joined.
+func parseUnitFile(content string) (*unitFile, error) {
+	f := &unitFile{}
+	section := ""
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSpace(strings.TrimSuffix(line, "\\")) + " " + strings.TrimSpace(lines[i])
+		}
+
+		switch {
+		case line == "", line[0] == '#', line[0] == ';':
+		case line[0] == '[':
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed section header %q", i+1, line)
+			}
+			section = line[1 : len(line)-1]
+		default:
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("line %d: expected key=value, got %q", i+1, line)
+			}
+			if section == "" {
+				return nil, fmt.Errorf("line %d: %s is outside of a section", i+1, strings.TrimSpace(kv[0]))
+			}
+			f.add(section, strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+	return f, nil
+}
+
+// lint reports common mistakes in the unit and its drop-ins.
+func (u *systemdUnit) lint() []string {
+	var warnings []string
+
+	ext := path.Ext(u.name)
+	known := false
+	for _, t := range unitTypes {
+		known = known || t == ext
+	}
+	if !known {
+		warnings = append(warnings, fmt.Sprintf("%s: unknown unit type %q", u.name, ext))
+	}
+
+	files := []*unitFile{u.file}
+	names := []string{u.name}
+	for _, d := range u.dropins {
+		files = append(files, d.file)
+		names = append(names, u.name+".d/"+d.name)
+		if path.Ext(d.name) != ".conf" {
+			warnings = append(warnings, fmt.Sprintf("%s: drop-in %q must end in .conf", u.name, d.name))
+		}
+	}
+
+	// settings from drop-ins override the unit, so look at them all
+	// together for cross-directive checks
+	merged := &unitFile{}
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		for _, s := range f.sections {
+			for _, e := range s.entries {
+				if !directiveName(e.key) {
+					warnings = append(warnings, fmt.Sprintf("%s: [%s] %s is not a directive name", names[i], s.name, e.key))
+				}
+				if strings.HasPrefix(e.key, "Exec") && e.value != "" {
+					if cmd := strings.TrimLeft(e.value, "-@+!:"); !strings.HasPrefix(cmd, "/") {
+						warnings = append(warnings, fmt.Sprintf("%s: %s must use an absolute path: %s", names[i], e.key, e.value))
+					}
+				}
+				merged.add(s.name, e.key, e.value)
+			}
+		}
+	}
+
+	typ := lastValue(merged.values("Service", "Type"))
+	if typ == "oneshot" && !unitBool(lastValue(merged.values("Service", "RemainAfterExit"))) {
+		warnings = append(warnings, fmt.Sprintf("%s: Type=oneshot without RemainAfterExit=yes is restarted every time it is pulled in", u.name))
+	}
+
+	sectionTypes := map[string]string{"Service": ".service", "Timer": ".timer", "Mount": ".mount"}
+	for section, want := range sectionTypes {
+		if merged.section(section) != nil && ext != want {
+			warnings = append(warnings, fmt.Sprintf("%s: [%s] section requires a %s unit", u.name, section, want))
+		}
+	}
+	if ext == ".mount" {
+		if where := lastValue(merged.values("Mount", "Where")); where != "" {
+			if want := mountUnitName(where); want != u.name {
+				warnings = append(warnings, fmt.Sprintf("%s: mount unit for %s must be named %s", u.name, where, want))
+			}
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings
+}
+
+// escapeSpecifiers doubles the % of a value so systemd doesn't expand it
+// as a specifier.
+func escapeSpecifiers(v string) string {
+	return strings.Replace(v, "%", "%%", -1)
+}
+
+// unitBool reports whether v is one of the values systemd treats as true.
+func unitBool(v string) bool {
+	switch strings.ToLower(v) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true
+	}
+	return false
+}
+
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// mountUnitName escapes a mount point the way systemd-escape --path does.
+func mountUnitName(where string) string {
+	p := strings.Trim(path.Clean(where), "/")
+	if p == "" {
+		return "-.mount"
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '/':
+			buf.WriteByte('-')
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == ':', c == '_', c == '.' && i > 0:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, `\x%02x`, c)
+		}
+	}
+	return buf.String() + ".mount"
+}
+
+func (u *systemdUnit) content() string {
+	if u.file == nil {
+		return ""
+	}
+	return u.file.String()
+}
+
+// ignitionUnit returns the unit as an Ignition systemd unit.
+func (u *systemdUnit) ignitionUnit() ignitionUnit {
+	iu := ignitionUnit{Name: u.name, Enable: u.enable, Contents: u.content()}
+	for _, d := range u.dropins {
+		iu.Dropins = append(iu.Dropins, ignitionDropin{Name: d.name, Contents: d.file.String()})
+	}
+	return iu
+}
+
+// cloudConfigUnit returns the unit as a coreos.units entry.
+func (u *systemdUnit) cloudConfigUnit() cloudConfigUnit {
+	cu := cloudConfigUnit{Name: u.name, Enable: u.enable, Content: u.content()}
+	for _, d := range u.dropins {
+		cu.DropIns = append(cu.DropIns, cloudConfigDropIn{Name: d.name, Content: d.file.String()})
+	}
+	return cu
+}
+
+// unitConfigs renders units as a cloud-config document and an Ignition
+// config, the two forms every unit-producing resource exposes.
+func unitConfigs(units ...*systemdUnit) (string, string) {
+	cc := &cloudConfig{}
+	ign := newIgnitionConfig()
+	for _, u := range units {
+		cc.CoreOS.Units = append(cc.CoreOS.Units, u.cloudConfigUnit())
+		ign.Systemd.Units = append(ign.Systemd.Units, u.ignitionUnit())
+	}
+	return cc.String(), ign.String()
+}
@@ -0,0 +1,117 @@
+package coreos
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnitFileFromConfig(t *testing.T) {
+	f := unitFileFromConfig(map[string]interface{}{
+		"install": []interface{}{map[string]interface{}{
+			"wanted_by": []interface{}{"multi-user.target"},
+		}},
+		"service": []interface{}{map[string]interface{}{
+			"type":              "oneshot",
+			"remain_after_exit": true,
+			"exec_start":        []interface{}{"/usr/bin/true"},
+			"extra":             map[string]interface{}{"Nice": "10"},
+		}},
+		"unit": []interface{}{map[string]interface{}{
+			"description": "test",
+			"after":       []interface{}{"network.target", "docker.service"},
+		}},
+	})
+
+	want := `[Unit]
+Description=test
+After=network.target
+After=docker.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=true
+ExecStart=/usr/bin/true
+Nice=10
+
+[Install]
+WantedBy=multi-user.target
+`
+	if got := f.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseUnitFile(t *testing.T) {
+	f, err := parseUnitFile(`# comment
+[Unit]
+Description=test
+
+[Service]
+; another comment
+ExecStart=/usr/bin/docker run \
+    busybox
+Environment = A=1
+`)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	want := `[Unit]
+Description=test
+
+[Service]
+ExecStart=/usr/bin/docker run busybox
+Environment=A=1
+`
+	if got := f.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := parseUnitFile("ExecStart=/bin/true\n"); err == nil {
+		t.Fatalf("expected an error for a directive outside of a section")
+	}
+}
+
+func TestSystemdUnitLint(t *testing.T) {
+	f := &unitFile{}
+	f.add("Service", "Type", "oneshot")
+	f.add("Service", "ExecStart", "-usr/bin/true")
+	f.add("Service", "Nice", "10")
+	f.add("Service", "LimitNOFILE", "65536")
+	f.add("Service", "limit_nofile", "65536")
+	f.add("Mount", "Where", "/var/lib")
+
+	u := &systemdUnit{
+		name: "app.service",
+		file: f,
+		dropins: []systemdDropin{
+			{name: "10-reset.conf", file: &unitFile{sections: []unitSection{
+				{name: "Service", entries: []unitEntry{{"ExecStart", ""}}},
+			}}},
+		},
+	}
+
+	want := []string{
+		"app.service: ExecStart must use an absolute path: -usr/bin/true",
+		"app.service: Type=oneshot without RemainAfterExit=yes is restarted every time it is pulled in",
+		"app.service: [Mount] section requires a .mount unit",
+		"app.service: [Service] limit_nofile is not a directive name",
+	}
+	if got := u.lint(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMountUnitName(t *testing.T) {
+	cases := map[string]string{
+		"/":               "-.mount",
+		"/var/lib/docker": "var-lib-docker.mount",
+		"/mnt/data-disk/": `mnt-data\x2ddisk.mount`,
+		"/srv/with space": `srv-with\x20space.mount`,
+	}
+	for where, want := range cases {
+		if got := mountUnitName(where); got != want {
+			t.Errorf("%s: got %s, want %s", where, got, want)
+		}
+	}
+}
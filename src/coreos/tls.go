@@ -0,0 +1,257 @@
+package coreos
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ecdsaCurves = map[string]elliptic.Curve{
+	"P256": elliptic.P256(),
+	"P384": elliptic.P384(),
+	"P521": elliptic.P521(),
+}
+
+// tlsProfiles are the extended key usages of each kind of certificate.
+// etcd and fleet peers both accept and make connections.
+var tlsProfiles = map[string][]x509.ExtKeyUsage{
+	"server": {x509.ExtKeyUsageServerAuth},
+	"client": {x509.ExtKeyUsageClientAuth},
+	"peer":   {x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+}
+
+// tlsKeySpec says what kind of private key to generate.
+type tlsKeySpec struct {
+	algorithm string
+	rsaBits   int
+	curve     string
+}
+
+func (s tlsKeySpec) generate() (crypto.Signer, error) {
+	switch s.algorithm {
+	case "RSA":
+		if s.rsaBits < 2048 {
+			return nil, fmt.Errorf("rsa_bits must be at least 2048")
+		}
+		return rsa.GenerateKey(rand.Reader, s.rsaBits)
+	case "ECDSA":
+		curve, ok := ecdsaCurves[s.curve]
+		if !ok {
+			return nil, fmt.Errorf("ecdsa_curve must be one of P256, P384 or P521")
+		}
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+	return nil, fmt.Errorf("key_algorithm must be RSA or ECDSA")
+}
+
+func encodePrivateKey(key crypto.Signer) (string, error) {
+	var block *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return "", err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+func parsePrivateKey(s string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in private key")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported private key type %q", block.Type)
+}
+
+func parseCertificate(s string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no CERTIFICATE PEM block")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// tlsCertSpec describes a certificate to issue.
+type tlsCertSpec struct {
+	commonName   string
+	organization string
+	dnsNames     []string
+	ipAddresses  []string
+	profile      string
+	validity     time.Duration
+}
+
+func (s *tlsCertSpec) template() (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	if s.validity <= 0 {
+		return nil, fmt.Errorf("validity_hours must be positive")
+	}
+	// backdated a little so freshly issued certs work on machines whose
+	// clocks lag behind
+	now := time.Now()
+	t := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: s.commonName},
+		NotBefore:    now.Add(-5 * time.Minute),
+		NotAfter:     now.Add(s.validity),
+		DNSNames:     s.dnsNames,
+	}
+	if s.organization != "" {
+		t.Subject.Organization = []string{s.organization}
+	}
+	for _, a := range s.ipAddresses {
+		ip := net.ParseIP(a)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", a)
+		}
+		t.IPAddresses = append(t.IPAddresses, ip)
+	}
+	return t, nil
+}
+
+// subjectKeyID is the SHA-1 of the public key, as RFC 5280 suggests.
+func subjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(der)
+	return sum[:], nil
+}
+
+// newCACert self-signs a CA certificate for key.
+func newCACert(key crypto.Signer, spec *tlsCertSpec) (string, error) {
+	t, err := spec.template()
+	if err != nil {
+		return "", err
+	}
+	t.IsCA = true
+	t.BasicConstraintsValid = true
+	t.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	if t.SubjectKeyId, err = subjectKeyID(key.Public()); err != nil {
+		return "", err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, t, t, key.Public(), key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), nil
+}
+
+// newCert issues a certificate for key, signed by the CA.
+func newCert(caCertPEM, caKeyPEM string, key crypto.Signer, spec *tlsCertSpec) (string, error) {
+	usages, ok := tlsProfiles[spec.profile]
+	if !ok {
+		var names []string
+		for k := range tlsProfiles {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("profile must be one of %s", strings.Join(names, ", "))
+	}
+	if spec.profile != "client" && len(spec.dnsNames) == 0 && len(spec.ipAddresses) == 0 {
+		return "", fmt.Errorf("%s certificates need dns_names or ip_addresses to be verified against", spec.profile)
+	}
+	ca, err := parseCertificate(caCertPEM)
+	if err != nil {
+		return "", fmt.Errorf("ca_cert_pem: %s", err)
+	}
+	if !ca.IsCA {
+		return "", fmt.Errorf("ca_cert_pem is not a CA certificate")
+	}
+	caKey, err := parsePrivateKey(caKeyPEM)
+	if err != nil {
+		return "", fmt.Errorf("ca_private_key_pem: %s", err)
+	}
+
+	t, err := spec.template()
+	if err != nil {
+		return "", err
+	}
+	t.ExtKeyUsage = usages
+	t.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	t.BasicConstraintsValid = true
+	if t.NotAfter.After(ca.NotAfter) {
+		t.NotAfter = ca.NotAfter
+	}
+	if t.SubjectKeyId, err = subjectKeyID(key.Public()); err != nil {
+		return "", err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, t, ca, key.Public(), caKey)
+	if err != nil {
+		return "", fmt.Errorf("signing: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), nil
+}
+
+// tlsFile is a PEM file to install on the machine.
+type tlsFile struct {
+	path     string
+	contents string
+	mode     int
+}
+
+// tlsFileConfigs renders files as write_files entries and as Ignition
+// files, owned by owner when it's set. owner is user or user:group, as in
+// write_files, and is split into Ignition's user and group.
+func tlsFileConfigs(files []tlsFile, owner string) (string, string) {
+	cc := &cloudConfig{}
+	ign := newIgnitionConfig()
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		cc.WriteFiles = append(cc.WriteFiles, cloudConfigFile{
+			Path:               f.path,
+			Content:            f.contents,
+			Owner:              owner,
+			RawFilePermissions: fmt.Sprintf("0%o", f.mode),
+		})
+		file := ign.addFile(f.path, f.contents, f.mode)
+		if owner != "" {
+			parts := strings.SplitN(owner, ":", 2)
+			file.User = ignitionNodeFor(parts[0])
+			if len(parts) == 2 {
+				file.Group = ignitionNodeFor(parts[1])
+			}
+		}
+	}
+	return cc.String(), ign.String()
+}
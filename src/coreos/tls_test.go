@@ -0,0 +1,177 @@
+package coreos
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLSIssue(t *testing.T) {
+	caKey, err := tlsKeySpec{algorithm: "ECDSA", curve: "P256"}.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKeyPEM, err := encodePrivateKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPEM, err := newCACert(caKey, &tlsCertSpec{commonName: "etcd-ca", validity: 48 * time.Hour})
+	if err != nil {
+		t.Fatalf("ca: %s", err)
+	}
+	ca, err := parseCertificate(caPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.IsCA {
+		t.Fatal("CA certificate is not a CA")
+	}
+
+	key, err := tlsKeySpec{algorithm: "RSA", rsaBits: 2048}.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := &tlsCertSpec{
+		commonName:  "node-1",
+		dnsNames:    []string{"node-1.example.com"},
+		ipAddresses: []string{"10.0.0.11"},
+		profile:     "peer",
+		validity:    365 * 24 * time.Hour,
+	}
+	certPEM, err := newCert(caPEM, caKeyPEM, key, spec)
+	if err != nil {
+		t.Fatalf("cert: %s", err)
+	}
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cert.NotAfter.Equal(ca.NotAfter) {
+		t.Errorf("validity not capped at the CA's: %s, CA %s", cert.NotAfter, ca.NotAfter)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, usage := range []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth} {
+		for _, name := range []string{"node-1.example.com", "10.0.0.11"} {
+			opts := x509.VerifyOptions{Roots: roots, DNSName: name, KeyUsages: []x509.ExtKeyUsage{usage}}
+			if _, err := cert.Verify(opts); err != nil {
+				t.Errorf("verify %s for %v: %s", name, usage, err)
+			}
+		}
+	}
+
+	spec.profile = "client"
+	certPEM, err = newCert(caPEM, caKeyPEM, key, spec)
+	if err != nil {
+		t.Fatalf("cert: %s", err)
+	}
+	cert, _ = parseCertificate(certPEM)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}); err == nil {
+		t.Error("client certificate verified as a server certificate")
+	}
+
+	spec.profile = "server"
+	spec.dnsNames, spec.ipAddresses = nil, nil
+	if _, err := newCert(caPEM, caKeyPEM, key, spec); err == nil || !strings.Contains(err.Error(), "dns_names") {
+		t.Errorf("expected a server certificate without SANs to fail, got %v", err)
+	}
+	spec.ipAddresses = []string{"10.0.0.300"}
+	if _, err := newCert(caPEM, caKeyPEM, key, spec); err == nil {
+		t.Error("expected an invalid IP address to fail")
+	}
+	spec.profile = "admin"
+	if _, err := newCert(caPEM, caKeyPEM, key, spec); err == nil || !strings.Contains(err.Error(), "client, peer, server") {
+		t.Errorf("expected an unknown profile to fail, got %v", err)
+	}
+	if _, err := newCert(certPEM, caKeyPEM, key, &tlsCertSpec{profile: "client", validity: time.Hour}); err == nil {
+		t.Error("expected signing with a leaf certificate to fail")
+	}
+}
+
+func TestTLSKeys(t *testing.T) {
+	if _, err := (tlsKeySpec{algorithm: "RSA", rsaBits: 1024}).generate(); err == nil {
+		t.Error("expected a 1024 bit RSA key to be refused")
+	}
+	if _, err := (tlsKeySpec{algorithm: "ECDSA", curve: "P192"}).generate(); err == nil {
+		t.Error("expected an unknown curve to be refused")
+	}
+	if _, err := (tlsKeySpec{algorithm: "DSA"}).generate(); err == nil {
+		t.Error("expected an unknown algorithm to be refused")
+	}
+
+	for _, spec := range []tlsKeySpec{{algorithm: "RSA", rsaBits: 2048}, {algorithm: "ECDSA", curve: "P384"}} {
+		key, err := spec.generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := encodePrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := parsePrivateKey(s)
+		if err != nil {
+			t.Fatalf("%s: %s", spec.algorithm, err)
+		}
+		switch parsed.(type) {
+		case *rsa.PrivateKey:
+			if spec.algorithm != "RSA" {
+				t.Errorf("%s key parsed as RSA", spec.algorithm)
+			}
+		case *ecdsa.PrivateKey:
+			if spec.algorithm != "ECDSA" {
+				t.Errorf("%s key parsed as ECDSA", spec.algorithm)
+			}
+		}
+	}
+}
+
+func TestTLSFileConfigs(t *testing.T) {
+	cc, ign := tlsFileConfigs([]tlsFile{
+		{"/etc/ssl/etcd/ca.pem", "ca\n", 0644},
+		{"", "skipped\n", 0644},
+		{"/etc/ssl/etcd/key.pem", "key\n", 0600},
+	}, "etcd")
+
+	for _, s := range []string{"path: /etc/ssl/etcd/key.pem", `permissions: "0600"`, "owner: etcd"} {
+		if !strings.Contains(cc, s) {
+			t.Errorf("cloud-config is missing %q:\n%s", s, cc)
+		}
+	}
+	if strings.Contains(cc, "skipped") || strings.Contains(ign, "skipped") {
+		t.Error("a file without a path was rendered")
+	}
+	for _, s := range []string{`"path":"/etc/ssl/etcd/key.pem"`, `"mode":384`, `"user":{"name":"etcd"}`} {
+		if !strings.Contains(ign, s) {
+			t.Errorf("ignition is missing %q:\n%s", s, ign)
+		}
+	}
+}
+
+func TestTLSCertDefaultOwner(t *testing.T) {
+	s := resourceCoreOSTLSCert().Schema
+	owner, _ := s["file_owner"].Default.(string)
+	cc, ign := tlsFileConfigs([]tlsFile{{s["key_path"].Default.(string), "key\n", 0600}}, owner)
+	if !strings.Contains(cc, "owner: etcd") {
+		t.Errorf("cloud-config owner:\n%s", cc)
+	}
+	if !strings.Contains(ign, `"user":{"name":"etcd"}`) {
+		t.Errorf("ignition owner:\n%s", ign)
+	}
+	if _, err := (tlsKeySpec{algorithm: "ECDSA", curve: "P224"}).generate(); err == nil {
+		t.Error("expected P224 to be refused")
+	}
+}
+
+func TestTLSFileConfigsGroup(t *testing.T) {
+	cc, ign := tlsFileConfigs([]tlsFile{{"/etc/ssl/etcd/key.pem", "key\n", 0600}}, "etcd:232")
+	if !strings.Contains(cc, "owner: etcd:232") {
+		t.Errorf("cloud-config owner:\n%s", cc)
+	}
+	if !strings.Contains(ign, `"user":{"name":"etcd"},"group":{"id":232}`) {
+		t.Errorf("ignition owner:\n%s", ign)
+	}
+}
@@ -0,0 +1,126 @@
+package coreos
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var rebootStrategies = []string{"etcd-lock", "reboot", "off", "best-effort"}
+
+// updateConfig is the update policy of a machine: what update_engine
+// fetches from where, and how locksmithd reboots into it.
+type updateConfig struct {
+	group          string
+	server         string
+	rebootStrategy string
+	locksmith      locksmithConfig
+}
+
+func (u *updateConfig) validate() error {
+	if u.rebootStrategy != "" && !oneOf(u.rebootStrategy, rebootStrategies) {
+		return fmt.Errorf("reboot_strategy must be one of %s", strings.Join(rebootStrategies, ", "))
+	}
+	if u.server != "" {
+		p, err := url.Parse(u.server)
+		if err != nil || (p.Scheme != "http" && p.Scheme != "https") || p.Host == "" {
+			return fmt.Errorf("server %q must be an http(s) URL", u.server)
+		}
+	}
+
+	l := &u.locksmith
+	if err := checkRebootWindow(l.windowStart, l.windowLength); err != nil {
+		return err
+	}
+	for _, e := range strings.Split(l.endpoints, ",") {
+		if e == "" {
+			continue
+		}
+		if p, err := url.Parse(e); err != nil || p.Scheme == "" || p.Host == "" {
+			return fmt.Errorf("etcd endpoint %q must be a URL", e)
+		}
+	}
+	if (l.certFile == "") != (l.keyFile == "") {
+		return fmt.Errorf("etcd_certfile and etcd_keyfile must be set together")
+	}
+
+	etcd := l.endpoints != "" || l.group != "" || l.caFile != "" || l.certFile != ""
+	switch u.rebootStrategy {
+	case "etcd-lock":
+		if l.endpoints == "" {
+			return fmt.Errorf("reboot_strategy etcd-lock needs etcd_endpoints to take the reboot lock from")
+		}
+	case "off":
+		if etcd || l.windowStart != "" {
+			return fmt.Errorf("locksmith settings have no effect with reboot_strategy off")
+		}
+	case "reboot":
+		if etcd {
+			return fmt.Errorf("etcd settings have no effect with reboot_strategy reboot")
+		}
+	}
+	return nil
+}
+
+// updateConf renders /etc/coreos/update.conf.
+func (u *updateConfig) updateConf() string {
+	var buf bytes.Buffer
+	if u.group != "" {
+		fmt.Fprintf(&buf, "GROUP=%s\n", u.group)
+	}
+	if u.server != "" {
+		fmt.Fprintf(&buf, "SERVER=%s\n", u.server)
+	}
+	if u.rebootStrategy != "" {
+		fmt.Fprintf(&buf, "REBOOT_STRATEGY=%s\n", u.rebootStrategy)
+	}
+	return buf.String()
+}
+
+// configs renders the policy as coreos.update and coreos.locksmith
+// cloud-config sections and as Ignition files and units.
+func (u *updateConfig) configs() (string, string) {
+	cc := &cloudConfig{}
+	update := map[string]interface{}{}
+	for k, v := range map[string]string{"group": u.group, "server": u.server, "reboot-strategy": u.rebootStrategy} {
+		if v != "" {
+			update[k] = v
+		}
+	}
+	if len(update) > 0 {
+		cc.CoreOS.Update = update
+	}
+
+	l := &u.locksmith
+	locksmith := map[string]interface{}{}
+	for k, v := range map[string]string{
+		"window_start":  l.windowStart,
+		"window_length": l.windowLength,
+		"group":         l.group,
+		"endpoint":      l.endpoints,
+		"etcd_cafile":   l.caFile,
+		"etcd_certfile": l.certFile,
+		"etcd_keyfile":  l.keyFile,
+	} {
+		if v != "" {
+			locksmith[k] = v
+		}
+	}
+	if len(locksmith) > 0 {
+		cc.CoreOS.Locksmith = locksmith
+	}
+
+	ign := newIgnitionConfig()
+	if conf := u.updateConf(); conf != "" {
+		ign.addFile("/etc/coreos/update.conf", conf, 0644)
+	}
+	if u.rebootStrategy == "off" {
+		ign.unit("locksmithd.service").Mask = true
+	}
+	if dropin := l.dropIn(); dropin != "" {
+		unit := ign.unit("locksmithd.service")
+		unit.Dropins = append(unit.Dropins, ignitionDropin{Name: "20-locksmithd.conf", Contents: dropin})
+	}
+	return cc.String(), ign.String()
+}
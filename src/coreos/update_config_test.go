@@ -0,0 +1,73 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUpdateConfigValidate(t *testing.T) {
+	cases := []struct {
+		u   updateConfig
+		err string
+	}{
+		{updateConfig{rebootStrategy: "sometimes"}, "must be one of"},
+		{updateConfig{rebootStrategy: "etcd-lock"}, "needs etcd_endpoints"},
+		{updateConfig{rebootStrategy: "off", locksmith: locksmithConfig{windowStart: "03:00", windowLength: "1h"}}, "no effect"},
+		{updateConfig{rebootStrategy: "reboot", locksmith: locksmithConfig{endpoints: "http://10.0.0.1:2379"}}, "no effect"},
+		{updateConfig{server: "update.example.com"}, "http(s) URL"},
+		{updateConfig{locksmith: locksmithConfig{endpoints: "10.0.0.1:2379"}}, "must be a URL"},
+		{updateConfig{locksmith: locksmithConfig{endpoints: "http://10.0.0.1:2379", certFile: "/etc/cert.pem"}}, "set together"},
+		{updateConfig{locksmith: locksmithConfig{windowStart: "25:00", windowLength: "1h"}}, "window"},
+		{updateConfig{rebootStrategy: "best-effort", locksmith: locksmithConfig{windowStart: "Sun 03:00", windowLength: "2h"}}, ""},
+	}
+	for i, c := range cases {
+		err := c.u.validate()
+		if c.err == "" {
+			if err != nil {
+				t.Errorf("%d: unexpected error: %s", i, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%d: expected %q, got %v", i, c.err, err)
+		}
+	}
+}
+
+func TestUpdateConfigRender(t *testing.T) {
+	u := &updateConfig{
+		group:          "stable",
+		server:         "https://update.example.com/v1/update/",
+		rebootStrategy: "etcd-lock",
+		locksmith: locksmithConfig{
+			windowStart:  "Sun 03:00",
+			windowLength: "2h",
+			endpoints:    "https://10.0.0.10:2379",
+		},
+	}
+	if err := u.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	want := "GROUP=stable\nSERVER=https://update.example.com/v1/update/\nREBOOT_STRATEGY=etcd-lock\n"
+	if got := u.updateConf(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	cc, ign := u.configs()
+	for _, s := range []string{"reboot-strategy: etcd-lock", "window_start: Sun 03:00", "endpoint: https://10.0.0.10:2379"} {
+		if !strings.Contains(cc, s) {
+			t.Errorf("cloud-config is missing %q:\n%s", s, cc)
+		}
+	}
+	for _, s := range []string{"/etc/coreos/update.conf", "locksmithd.service", "LOCKSMITHD_ENDPOINT="} {
+		if !strings.Contains(ign, s) {
+			t.Errorf("ignition is missing %q:\n%s", s, ign)
+		}
+	}
+
+	u = &updateConfig{rebootStrategy: "off"}
+	if _, ign := u.configs(); !strings.Contains(ign, `"mask":true`) {
+		t.Errorf("locksmithd is not masked:\n%s", ign)
+	}
+}
@@ -0,0 +1,453 @@
+package coreos
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// updatePayloadName is the file each version directory of an update
+// server's payload directory holds.
+const updatePayloadName = "update.gz"
+
+// UpdateServer answers update_engine's Omaha update checks from a local
+// directory of payloads laid out as <version>/update.gz, and serves the
+// payloads themselves under /payloads/. What each group is offered is
+// managed through a small JSON API under /admin/groups/.
+type UpdateServer struct {
+	dir     string
+	file    string
+	baseURL string
+	token   string
+
+	mu       sync.Mutex
+	groups   map[string]*updateGroup
+	payloads map[string]*updatePayload
+}
+
+// updateGroup pins the version offered to a group, the latest payload
+// when empty, and the share of the group's machines it is offered to.
+type updateGroup struct {
+	ID             string `json:"id"`
+	Version        string `json:"version,omitempty"`
+	RolloutPercent int    `json:"rollout_percent"`
+}
+
+type updatePayload struct {
+	version string
+	size    int64
+	modTime time.Time
+	// sha1 and sha256 are base64 encoded, as Omaha wants them.
+	sha1, sha256 string
+}
+
+// NewUpdateServer serves the payloads in dir and loads groups from file,
+// which is created on the first change. Payload URLs are built from
+// baseURL, or from the request's host when it is empty. When token is
+// set, the admin API requires it as a bearer token.
+func NewUpdateServer(dir, file, baseURL, token string) (*UpdateServer, error) {
+	s := &UpdateServer{
+		dir:      dir,
+		file:     file,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		token:    token,
+		groups:   make(map[string]*updateGroup),
+		payloads: make(map[string]*updatePayload),
+	}
+	if file == "" {
+		return s, nil
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &s.groups); err != nil {
+		return nil, fmt.Errorf("loading %s: %s", file, err)
+	}
+	return s, nil
+}
+
+func (s *UpdateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/update/" || r.URL.Path == "/v1/update":
+		s.serveOmaha(w, r)
+	case strings.HasPrefix(r.URL.Path, "/payloads/"):
+		http.StripPrefix("/payloads", http.FileServer(http.Dir(s.dir))).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/groups/"):
+		s.serveAdmin(w, r, strings.TrimPrefix(r.URL.Path, "/admin/groups/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *UpdateServer) serveOmaha(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req omahaRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	resp := &omahaResponse{
+		Protocol: "3.0",
+		Server:   "terraform-provider-coreos",
+		DayStart: &omahaDayStart{ElapsedSeconds: int(now.Sub(midnight).Seconds())},
+	}
+	for _, app := range req.Apps {
+		resp.Apps = append(resp.Apps, s.answer(r, app))
+	}
+
+	buf, err := xml.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	io.WriteString(w, xml.Header)
+	w.Write(buf)
+}
+
+func (s *UpdateServer) answer(r *http.Request, app omahaApp) omahaApp {
+	out := omahaApp{AppID: app.AppID, Status: "ok"}
+	if !strings.EqualFold(app.AppID, omahaAppID) {
+		out.Status = "error-unknownApplication"
+		return out
+	}
+
+	for _, e := range app.Events {
+		log.Printf("machine %s in group %s at %s: %s", app.MachineID, app.Track, app.Version, e)
+		out.Events = append(out.Events, omahaEvent{Status: "ok"})
+	}
+	if app.Ping != nil {
+		out.Ping = &omahaPing{Status: "ok"}
+	}
+	if app.UpdateCheck != nil {
+		out.UpdateCheck = s.updateCheck(r, app)
+	}
+	return out
+}
+
+func (s *UpdateServer) updateCheck(r *http.Request, app omahaApp) *omahaUpdateCheck {
+	noUpdate := &omahaUpdateCheck{Status: "noupdate"}
+
+	s.mu.Lock()
+	g, ok := s.groups[app.Track]
+	s.mu.Unlock()
+	if !ok {
+		log.Printf("machine %s asked for unknown group %q", app.MachineID, app.Track)
+		return noUpdate
+	}
+	if !g.includes(app.MachineID) {
+		return noUpdate
+	}
+
+	p, err := s.payload(g.Version)
+	if err != nil {
+		log.Printf("group %s: %s", g.ID, err)
+		return &omahaUpdateCheck{Status: "error-internal"}
+	}
+	if compareVersions(p.version, app.Version) <= 0 {
+		return noUpdate
+	}
+
+	base := s.baseURL
+	if base == "" {
+		base = "http://" + r.Host
+	}
+	log.Printf("offering %s to machine %s in group %s at %s", p.version, app.MachineID, g.ID, app.Version)
+	return &omahaUpdateCheck{
+		Status: "ok",
+		URLs:   []omahaURL{{Codebase: base + "/payloads/" + p.version + "/"}},
+		Manifest: &omahaManifest{
+			Version:  p.version,
+			Packages: []omahaPackage{{Hash: p.sha1, Name: updatePayloadName, Size: uint64(p.size)}},
+			Actions: []omahaAction{{
+				Event:                 "postinstall",
+				SHA256:                p.sha256,
+				DisablePayloadBackoff: true,
+			}},
+		},
+	}
+}
+
+// includes reports whether a machine is within the group's rollout. A
+// machine keeps its place as the percentage grows.
+func (g *updateGroup) includes(machineID string) bool {
+	if g.RolloutPercent >= 100 {
+		return true
+	}
+	sum := sha256.Sum256([]byte(g.ID + "/" + machineID))
+	return int(binary.BigEndian.Uint16(sum[:])%100) < g.RolloutPercent
+}
+
+// payload returns the payload of version, or the latest one when version
+// is empty. Digests are cached until the file changes.
+func (s *UpdateServer) payload(version string) (*updatePayload, error) {
+	if version == "" {
+		versions, err := s.versions()
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("no payloads in %s", s.dir)
+		}
+		version = versions[len(versions)-1]
+	}
+	if version != filepath.Base(version) || strings.HasPrefix(version, ".") {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+
+	file := filepath.Join(s.dir, version, updatePayloadName)
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	p, ok := s.payloads[version]
+	s.mu.Unlock()
+	if ok && p.size == fi.Size() && p.modTime.Equal(fi.ModTime()) {
+		return p, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h1, h256 := sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(h1, h256), f); err != nil {
+		return nil, err
+	}
+	p = &updatePayload{
+		version: version,
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+		sha1:    base64.StdEncoding.EncodeToString(h1.Sum(nil)),
+		sha256:  base64.StdEncoding.EncodeToString(h256.Sum(nil)),
+	}
+	s.mu.Lock()
+	s.payloads[version] = p
+	s.mu.Unlock()
+	return p, nil
+}
+
+// versions lists the versions with a payload, oldest first.
+func (s *UpdateServer) versions() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(s.dir, e.Name(), updatePayloadName)); err == nil {
+			versions = append(versions, e.Name())
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i], versions[j]) < 0 })
+	return versions, nil
+}
+
+func (s *UpdateServer) serveAdmin(w http.ResponseWriter, r *http.Request, id string) {
+	auth := []byte(r.Header.Get("Authorization"))
+	if s.token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+s.token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if id == "" {
+		if r.Method != "GET" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.mu.Lock()
+		groups := make([]*updateGroup, 0, len(s.groups))
+		for _, g := range s.groups {
+			groups = append(groups, g)
+		}
+		s.mu.Unlock()
+		sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+		writeJSON(w, http.StatusOK, groups)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.mu.Lock()
+		g, ok := s.groups[id]
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, g)
+
+	case "PUT":
+		var g updateGroup
+		if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g.ID = id
+		if g.RolloutPercent < 0 || g.RolloutPercent > 100 {
+			http.Error(w, "rollout_percent must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+		// a pin to a payload that isn't there would only fail on the machines
+		if g.Version != "" {
+			if _, err := s.payload(g.Version); err != nil {
+				http.Error(w, fmt.Sprintf("no payload for version %s: %s", g.Version, err), http.StatusBadRequest)
+				return
+			}
+		}
+		s.mu.Lock()
+		s.groups[id] = &g
+		err := s.save()
+		s.mu.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("group %s: version %q, rollout %d%%", id, g.Version, g.RolloutPercent)
+		writeJSON(w, http.StatusOK, &g)
+
+	case "DELETE":
+		s.mu.Lock()
+		_, ok := s.groups[id]
+		delete(s.groups, id)
+		var err error
+		if ok {
+			err = s.save()
+		}
+		s.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// save writes the groups to the server's file. It is called with mu held.
+func (s *UpdateServer) save() error {
+	if s.file == "" {
+		return nil
+	}
+	buf, err := json.Marshal(s.groups)
+	if err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// compareVersions orders dotted versions such as 1010.5.0 numerically,
+// falling back to string order for parts that aren't numbers.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errx := strconv.Atoi(x)
+		ny, erry := strconv.Atoi(y)
+		switch {
+		case errx == nil && erry == nil && nx != ny:
+			if nx < ny {
+				return -1
+			}
+			return 1
+		case (errx != nil || erry != nil) && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// update_engine's event types and results, for logging.
+var (
+	omahaEventTypes = map[int]string{
+		1:  "download complete",
+		2:  "install complete",
+		3:  "update complete",
+		13: "download started",
+		14: "download finished",
+		54: "rebooted after update",
+	}
+	omahaEventResults = map[int]string{
+		0: "error",
+		1: "success",
+		2: "success, reboot pending",
+		9: "deferred",
+	}
+)
+
+func (e omahaEvent) String() string {
+	t, ok := omahaEventTypes[e.Type]
+	if !ok {
+		t = fmt.Sprintf("event %d", e.Type)
+	}
+	r, ok := omahaEventResults[e.Result]
+	if !ok {
+		r = fmt.Sprintf("result %d", e.Result)
+	}
+	s := t + ": " + r
+	if e.ErrorCode != 0 {
+		s += fmt.Sprintf(" (error code %d)", e.ErrorCode)
+	}
+	if e.PreviousVersion != "" {
+		s += ", previously " + e.PreviousVersion
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+package coreos
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1010.5.0", "1010.5.0", 0},
+		{"899.17.0", "1010.5.0", -1},
+		{"1010.10.0", "1010.9.0", 1},
+		{"1010.5", "1010.5.0", -1},
+		{"0.0.0", "1.0.0", -1},
+	}
+	for _, c := range cases {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestUpdateServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "update-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	payloads := filepath.Join(dir, "payloads")
+	for _, v := range []string{"899.17.0", "1010.5.0"} {
+		if err := os.MkdirAll(filepath.Join(payloads, v), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(payloads, v, updatePayloadName), []byte("payload "+v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, "groups.json")
+
+	s, err := NewUpdateServer(payloads, file, "", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	omaha := ts.URL + "/v1/update/"
+
+	if _, err := updateGroupRequest("PUT", ts.URL, "wrong", "canary", &updateGroup{RolloutPercent: 100}, nil); err == nil {
+		t.Fatal("expected the admin API to refuse a wrong token")
+	}
+	if _, err := updateGroupRequest("PUT", ts.URL, "secret", "canary", &updateGroup{Version: "1.0.0", RolloutPercent: 100}, nil); err == nil || !strings.Contains(err.Error(), "no payload") {
+		t.Fatalf("expected a pin to a missing payload to fail, got %v", err)
+	}
+
+	// unpinned groups get the latest payload
+	if _, err := updateGroupRequest("PUT", ts.URL, "secret", "canary", &updateGroup{RolloutPercent: 100}, nil); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	u, err := omahaCheck(omaha, omahaApp{AppID: omahaAppID, Version: "766.3.0", Track: "canary", MachineID: "m1"})
+	if err != nil {
+		t.Fatalf("check: %s", err)
+	}
+	sum := sha256.Sum256([]byte("payload 1010.5.0"))
+	if u == nil || u.Version != "1010.5.0" || u.Size != 16 || u.Sha256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("got %+v", u)
+	}
+	resp, err := http.Get(u.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "payload 1010.5.0" {
+		t.Fatalf("payload: %q", body)
+	}
+
+	// machines on the offered version or newer get nothing
+	if u, err := omahaCheck(omaha, omahaApp{AppID: omahaAppID, Version: "1010.5.0", Track: "canary"}); err != nil || u != nil {
+		t.Fatalf("expected no update, got %+v, %v", u, err)
+	}
+	if u, err := omahaCheck(omaha, omahaApp{AppID: omahaAppID, Version: "766.3.0", Track: "unknown"}); err != nil || u != nil {
+		t.Fatalf("expected no update for an unknown group, got %+v, %v", u, err)
+	}
+
+	// a partial rollout reaches some machines and keeps them as it grows
+	if _, err := updateGroupRequest("PUT", ts.URL, "secret", "stable", &updateGroup{Version: "899.17.0", RolloutPercent: 30}, nil); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	in := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		id := fmt.Sprintf("machine-%d", i)
+		u, err := omahaCheck(omaha, omahaApp{AppID: omahaAppID, Version: "766.3.0", Track: "stable", MachineID: id})
+		if err != nil {
+			t.Fatalf("check: %s", err)
+		}
+		if u != nil {
+			if u.Version != "899.17.0" {
+				t.Fatalf("pinned group offered %s", u.Version)
+			}
+			in[id] = true
+		}
+	}
+	if len(in) < 30 || len(in) > 90 {
+		t.Fatalf("30%% rollout reached %d of 200 machines", len(in))
+	}
+	g := &updateGroup{ID: "stable", RolloutPercent: 60}
+	for id := range in {
+		if !g.includes(id) {
+			t.Fatalf("%s left the rollout when it grew", id)
+		}
+	}
+
+	// groups survive a restart
+	s, err = NewUpdateServer(payloads, file, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2 := httptest.NewServer(s)
+	defer ts2.Close()
+	var got updateGroup
+	found, err := updateGroupRequest("GET", ts2.URL, "", "stable", nil, &got)
+	if err != nil || !found || got.Version != "899.17.0" || got.RolloutPercent != 30 {
+		t.Fatalf("reloaded group: %+v, %v, %v", got, found, err)
+	}
+	if found, err := updateGroupRequest("DELETE", ts2.URL, "", "stable", nil, nil); err != nil || !found {
+		t.Fatalf("delete: %v, %v", found, err)
+	}
+	if found, err := updateGroupRequest("GET", ts2.URL, "", "stable", nil, nil); err != nil || found {
+		t.Fatalf("deleted group still found: %v, %v", found, err)
+	}
+}
+
+func TestUpdateServerEvents(t *testing.T) {
+	s, err := NewUpdateServer("", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	req := `<?xml version="1.0" encoding="UTF-8"?>
+<request protocol="3.0">
+ <app appid="` + omahaAppID + `" version="1010.5.0" track="stable" machineid="m1">
+  <event eventtype="3" eventresult="2" previousversion="899.17.0"></event>
+ </app>
+</request>`
+	resp, err := http.Post(ts.URL+"/v1/update/", "text/xml", strings.NewReader(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `<event status="ok"></event>`) {
+		t.Fatalf("got %s: %s", resp.Status, body)
+	}
+
+	e := omahaEvent{Type: 3, Result: 2, PreviousVersion: "899.17.0"}
+	if got := e.String(); got != "update complete: success, reboot pending, previously 899.17.0" {
+		t.Fatalf("got %q", got)
+	}
+}
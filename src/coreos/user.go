@@ -0,0 +1,194 @@
+package coreos
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// userNameRe is what useradd accepts by default.
+var userNameRe = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// minRSAKeyBits is the smallest RSA key OpenSSH still accepts.
+const minRSAKeyBits = 2048
+
+var sshKeyCurves = map[string]elliptic.Curve{
+	"nistp256": elliptic.P256(),
+	"nistp384": elliptic.P384(),
+	"nistp521": elliptic.P521(),
+}
+
+// sshKey is an authorized_keys entry.
+type sshKey struct {
+	typ         string
+	bits        int
+	fingerprint string
+}
+
+// parseSSHKey checks an authorized_keys line: its key type, that the
+// base64 blob decodes to a key of that type, and that the key is big
+// enough. Options in front of the key are skipped.
+func parseSSHKey(line string) (*sshKey, error) {
+	fields := strings.Fields(line)
+	i := 0
+	for i < len(fields) && !strings.HasPrefix(fields[i], "ssh-") && !strings.HasPrefix(fields[i], "ecdsa-") && !strings.HasPrefix(fields[i], "sk-") {
+		i++
+	}
+	if i+1 >= len(fields) {
+		return nil, fmt.Errorf("%q is not an authorized_keys line", abbreviate(line))
+	}
+	typ := fields[i]
+	blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+	if err != nil {
+		return nil, fmt.Errorf("%s key is not valid base64: %s", typ, err)
+	}
+
+	r := sshReader(blob)
+	if t := r.string(); t != typ {
+		return nil, fmt.Errorf("%s key contains a %q key", typ, t)
+	}
+	k := &sshKey{typ: typ}
+	switch typ {
+	case "ssh-rsa":
+		e, n := r.mpint(), r.mpint()
+		if r.short() || e.Sign() <= 0 || n.Sign() <= 0 {
+			return nil, fmt.Errorf("ssh-rsa key is truncated")
+		}
+		k.bits = n.BitLen()
+		if k.bits < minRSAKeyBits {
+			return nil, fmt.Errorf("ssh-rsa key has %d bits, OpenSSH needs at least %d", k.bits, minRSAKeyBits)
+		}
+	case "ssh-ed25519", "sk-ssh-ed25519@openssh.com":
+		if pub := r.string(); len(pub) != 32 {
+			return nil, fmt.Errorf("%s key is %d bytes, not 32", typ, len(pub))
+		}
+		k.bits = 256
+	case "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521", "sk-ecdsa-sha2-nistp256@openssh.com":
+		name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(typ, "sk-"), "ecdsa-sha2-"), "@openssh.com")
+		if c := r.string(); c != name {
+			return nil, fmt.Errorf("%s key is on curve %q", typ, c)
+		}
+		curve := sshKeyCurves[name]
+		if x, _ := elliptic.Unmarshal(curve, []byte(r.string())); x == nil {
+			return nil, fmt.Errorf("%s key is not a point on %s", typ, name)
+		}
+		k.bits = curve.Params().BitSize
+	case "ssh-dss":
+		return nil, fmt.Errorf("ssh-dss keys are limited to 1024 bits and disabled in OpenSSH, use ssh-ed25519")
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", typ)
+	}
+	if strings.HasPrefix(typ, "sk-") {
+		// the application, usually ssh:
+		r.string()
+	}
+	if r.short() || len(r) != 0 {
+		return nil, fmt.Errorf("%s key is malformed", typ)
+	}
+
+	sum := sha256.Sum256(blob)
+	k.fingerprint = "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+	return k, nil
+}
+
+// sshReader reads the length prefixed fields of the SSH wire format. A
+// read past the end leaves it nil, which short reports.
+type sshReader []byte
+
+func (r *sshReader) string() string {
+	if *r == nil || len(*r) < 4 {
+		*r = nil
+		return ""
+	}
+	n := binary.BigEndian.Uint32(*r)
+	if uint64(n) > uint64(len(*r)-4) {
+		*r = nil
+		return ""
+	}
+	s := string((*r)[4 : 4+n])
+	*r = (*r)[4+n:]
+	return s
+}
+
+func (r *sshReader) mpint() *big.Int {
+	return new(big.Int).SetBytes([]byte(r.string()))
+}
+
+func (r sshReader) short() bool {
+	return r == nil
+}
+
+// abbreviate shortens s for error messages.
+func abbreviate(s string) string {
+	if len(s) > 40 {
+		return s[:37] + "..."
+	}
+	return s
+}
+
+// userConfig is a login account. The password is only ever handled as a
+// hash.
+type userConfig struct {
+	name         string
+	groups       []string
+	shell        string
+	sshKeys      []string
+	passwordHash string
+}
+
+func (c *userConfig) validate() error {
+	if !userNameRe.MatchString(c.name) {
+		return fmt.Errorf("name %q must be lower case letters, digits, _ or -, start with a letter or _ and be at most 32 characters", c.name)
+	}
+	for _, g := range c.groups {
+		if !userNameRe.MatchString(g) {
+			return fmt.Errorf("groups: %q is not a valid group name", g)
+		}
+	}
+	if c.shell != "" && !path.IsAbs(c.shell) {
+		return fmt.Errorf("shell %q must be an absolute path", c.shell)
+	}
+	for i, l := range c.sshKeys {
+		if _, err := parseSSHKey(l); err != nil {
+			return fmt.Errorf("ssh_authorized_keys.%d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// fingerprints returns the SHA256 fingerprints of the keys, as ssh-keygen
+// -l prints them. The keys must have been validated.
+func (c *userConfig) fingerprints() []string {
+	var out []string
+	for _, l := range c.sshKeys {
+		k, _ := parseSSHKey(l)
+		out = append(out, k.fingerprint)
+	}
+	return out
+}
+
+// configs returns the user as a cloud-config users entry and an Ignition
+// passwd entry.
+func (c *userConfig) configs() (string, string) {
+	cc := &cloudConfig{Users: []cloudConfigUser{{
+		Name:              c.name,
+		PasswordHash:      c.passwordHash,
+		SSHAuthorizedKeys: c.sshKeys,
+		Groups:            c.groups,
+		Shell:             c.shell,
+	}}}
+
+	ign := newIgnitionConfig()
+	u := ign.user(c.name)
+	u.PasswordHash = c.passwordHash
+	u.SSHAuthorizedKeys = c.sshKeys
+	u.Groups = c.groups
+	u.Shell = c.shell
+	return cc.String(), ign.String()
+}
@@ -0,0 +1,186 @@
+package coreos
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+const (
+	testEd25519Key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIJ+Ueg3icUwSwKFOHm15w3F/rcNVYqg4RIAJLmhWTezh u@ed25519"
+	testECDSAKey   = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBDnt3d9+Cb4qKnNJxzmcdNnjk3lSCQh1UtQg0FumccgaEw1el5lBqYR1YrGNJju5xVSGQvgyw3AiGvdewKFeZoQ= u@ecdsab256"
+	testRSAKey     = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQCvELHCJarJtkeWo/X/sduJ89gcFKP96Ng3mJx9vt2iAy4R56SzH3hkzDuWqL/uavatghblPQ/6CAIvKZyZE++izITDfxcAB8jGGS6ys5VUcGco8MZGgYxNnie3gM3msUFI7Jgwtl6+4tR4sz6g/1rbZCvpZ6YGQ5YkbY2ln9ZIHvLAE5sCEdaWYAqhxvD7sG3B/kQp2cTRn4C+WeS78kq7xoFMEogbgQej8+LP+tCCJPtxzOHjKIv14CR+E0TH7s/mo6tQ/y/Oo6dPqeL1nOg+HpVg7k6U+69UgVlkHR/OJFMtpBehQYbv9+DB0ZGSCAtU5CnulPhIxtRDIoNG0LMB u@rsab2048"
+	testRSA1024Key = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDOSupymcasCXdtuQSnUOQm2kzYAr5czHiu9TTXETINhAzuKn5O/rlB4QC7th9HUkhGUXm1xkX4Cm2jzr7R0G2RMIh/9nXZeyNlMasqUHhw8VoUTnfv8ztNjyyQtZMiycc5LTEBZlYIyAaNdaSg/oCjXKPvKaGcWfHr091Wy8a+Cw== u@rsab1024"
+)
+
+func TestSHA512Crypt(t *testing.T) {
+	// from https://www.akkadia.org/drepper/SHA-crypt.txt
+	cases := []struct {
+		password, salt string
+		rounds         int
+		want           string
+	}{
+		{"Hello world!", "saltstring", 5000, "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"Hello world!", "saltstringsaltstring", 10000, "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+		{"the minimum number is still observed", "roundstoolow", 10, "$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX."},
+	}
+	for i, tc := range cases {
+		if got := sha512Crypt(tc.password, tc.salt, tc.rounds); got != tc.want {
+			t.Errorf("%d: got %s, want %s", i, got, tc.want)
+		}
+	}
+
+	a, _ := newCryptSalt()
+	b, _ := newCryptSalt()
+	if len(a) != 16 || a == b {
+		t.Errorf("salts %q and %q", a, b)
+	}
+}
+
+func TestParseSSHKey(t *testing.T) {
+	cases := []struct {
+		line        string
+		bits        int
+		fingerprint string
+	}{
+		{testEd25519Key, 256, "SHA256:apB7qwoPyay6hBgOxAVBHM8cSetWpD7NqvXfuUJDcuk"},
+		{testECDSAKey, 256, "SHA256:RJki9pFrOYwFZkEN5JSaaBRywuts0AXzdBpsfmN8ZZE"},
+		{testRSAKey, 2048, "SHA256:W/eDT/YB6zHFIyywlcyijmBGOFDUXhIrWNRgU4i0WHw"},
+		{`from="10.0.0.0/8",no-pty ` + testEd25519Key, 256, "SHA256:apB7qwoPyay6hBgOxAVBHM8cSetWpD7NqvXfuUJDcuk"},
+	}
+	for i, tc := range cases {
+		k, err := parseSSHKey(tc.line)
+		if err != nil {
+			t.Errorf("%d: %s", i, err)
+			continue
+		}
+		if k.bits != tc.bits || k.fingerprint != tc.fingerprint {
+			t.Errorf("%d: got %d %s", i, k.bits, k.fingerprint)
+		}
+	}
+
+	ed25519 := strings.Fields(testEd25519Key)[1]
+	bad := []struct {
+		line, err string
+	}{
+		{"AAAAC3NzaC1lZDI1NTE5", "not an authorized_keys line"},
+		{"ssh-ed25519 not*base64", "not valid base64"},
+		{"ssh-rsa " + ed25519, `contains a "ssh-ed25519" key`},
+		{"ssh-ed25519 " + ed25519[:40], "is 0 bytes"},
+		{testRSA1024Key, "has 1024 bits"},
+		{"ssh-dss AAAAB3NzaC1kc3MAAACBAPV1", "ssh-dss"},
+		{"ssh-foo AAAAB3NzaC1mb28=", "unsupported key type"},
+	}
+	for i, tc := range bad {
+		if _, err := parseSSHKey(tc.line); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: expected %q, got %v", i, tc.err, err)
+		}
+	}
+}
+
+func TestUserConfig(t *testing.T) {
+	c := &userConfig{
+		name:         "ops",
+		groups:       []string{"sudo", "docker"},
+		shell:        "/bin/bash",
+		sshKeys:      []string{testEd25519Key, testRSAKey},
+		passwordHash: sha512Crypt("secret", "saltstring", sha512CryptRounds),
+	}
+	if err := c.validate(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if f := c.fingerprints(); len(f) != 2 || f[1] != "SHA256:W/eDT/YB6zHFIyywlcyijmBGOFDUXhIrWNRgU4i0WHw" {
+		t.Errorf("fingerprints: %v", f)
+	}
+
+	cc, ign := c.configs()
+	for _, s := range []string{"- name: ops", "passwd: $6$saltstring$", "- docker", "shell: /bin/bash"} {
+		if !strings.Contains(cc, s) {
+			t.Errorf("cloud-config is missing %q:\n%s", s, cc)
+		}
+	}
+	for _, s := range []string{`"name":"ops","passwordHash":"$6$saltstring$`, `"groups":["sudo","docker"]`, `"shell":"/bin/bash"`} {
+		if !strings.Contains(ign, s) {
+			t.Errorf("ignition is missing %q:\n%s", s, ign)
+		}
+	}
+
+	cases := []struct {
+		c   userConfig
+		err string
+	}{
+		{userConfig{name: "Ops"}, "name"},
+		{userConfig{name: "1ops"}, "name"},
+		{userConfig{name: "ops", groups: []string{"wheel users"}}, "groups"},
+		{userConfig{name: "ops", shell: "bash"}, "absolute path"},
+		{userConfig{name: "ops", sshKeys: []string{testEd25519Key, testRSA1024Key}}, "ssh_authorized_keys.1"},
+	}
+	for i, tc := range cases {
+		if err := tc.c.validate(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%d: expected %q, got %v", i, tc.err, err)
+		}
+	}
+}
+
+// resetPasswordStateKey forgets the key providers configured.
+func resetPasswordStateKey() {
+	passwordStateKey.Lock()
+	defer passwordStateKey.Unlock()
+	passwordStateKey.key = ""
+}
+
+func TestUserPasswordState(t *testing.T) {
+	defer resetPasswordStateKey()
+
+	resetPasswordStateKey()
+	if s := userPasswordState("secret"); s != "no-password-key" {
+		t.Fatalf("stored %q without a key", s)
+	}
+
+	setPasswordStateKey("key-a")
+	a := userPasswordState("secret")
+	if !strings.HasPrefix(a, "hmac-sha256:") || strings.Contains(a, "secret") {
+		t.Fatalf("got %q", a)
+	}
+	if userPasswordState("secret") != a || userPasswordState("other") == a || userPasswordState("") != "" {
+		t.Fatal("stand-ins must only depend on the password and the key")
+	}
+
+	resetPasswordStateKey()
+	setPasswordStateKey("key-b")
+	if userPasswordState("secret") == a {
+		t.Fatal("stand-ins must differ between keys")
+	}
+}
+
+func TestPasswordKeyAcrossProviders(t *testing.T) {
+	defer resetPasswordStateKey()
+	resetPasswordStateKey()
+
+	configure := func(key string) error {
+		raw, err := config.NewRawConfig(map[string]interface{}{"password_key": key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Provider().Configure(terraform.NewResourceConfig(raw))
+	}
+
+	if err := configure("key-a"); err != nil {
+		t.Fatalf("first provider: %s", err)
+	}
+	a := userPasswordState("secret")
+	if err := configure("key-a"); err != nil {
+		t.Fatalf("provider with the same key: %s", err)
+	}
+	if err := configure(""); err != nil {
+		t.Fatalf("provider without a key: %s", err)
+	}
+	if err := configure("key-b"); err == nil || !strings.Contains(err.Error(), "password_key differs") {
+		t.Fatalf("expected a password_key error, got %v", err)
+	}
+	if userPasswordState("secret") != a {
+		t.Fatal("another provider's key replaced the first one's")
+	}
+}
@@ -0,0 +1,186 @@
+package coreos
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// yamlError is an error tied to a position in a YAML document.
+type yamlError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+type yamlPos struct {
+	line   int
+	column int
+}
+
+// yamlIndex records where each key and sequence item of a block-style YAML
+// document starts, keyed by paths such as "storage.files[0].mode".
+type yamlIndex struct {
+	lines []string
+	paths map[string]yamlPos
+}
+
+func newYAMLIndex(src string) *yamlIndex {
+	idx := &yamlIndex{
+		lines: strings.Split(src, "\n"),
+		paths: make(map[string]yamlPos),
+	}
+
+	type frame struct {
+		indent int
+		path   string
+		item   bool
+	}
+	var (
+		stack  []frame
+		counts = make(map[string]int)
+		// lines indented deeper than this belong to a block scalar
+		scalar = -1
+	)
+
+	parent := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		return stack[len(stack)-1].path
+	}
+	pushKey := func(indent int, key string, line int) {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		path := key
+		if p := parent(); p != "" {
+			path = p + "." + key
+		}
+		idx.paths[path] = yamlPos{line, indent + 1}
+		stack = append(stack, frame{indent, path, false})
+	}
+
+	for n, line := range idx.lines {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if scalar >= 0 {
+			if indent > scalar {
+				continue
+			}
+			scalar = -1
+		}
+
+		for strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			for len(stack) > 0 {
+				top := stack[len(stack)-1]
+				if top.indent < indent || (top.indent == indent && !top.item) {
+					break
+				}
+				stack = stack[:len(stack)-1]
+			}
+			p := parent()
+			path := fmt.Sprintf("%s[%d]", p, counts[p])
+			counts[p]++
+			idx.paths[path] = yamlPos{n + 1, indent + 1}
+			stack = append(stack, frame{indent, path, true})
+
+			rest := strings.TrimLeft(strings.TrimPrefix(trimmed, "-"), " ")
+			indent += len(trimmed) - len(rest)
+			trimmed = rest
+		}
+
+		if key, value, ok := splitYAMLKey(trimmed); ok {
+			pushKey(indent, key, n+1)
+			if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+				scalar = indent
+			}
+		}
+	}
+
+	return idx
+}
+
+var yamlKeyRe = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"{\[][^:#]*?)\s*:(\s+(.*))?$`)
+
+func splitYAMLKey(s string) (string, string, bool) {
+	m := yamlKeyRe.FindStringSubmatch(s)
+	if m == nil {
+		return "", "", false
+	}
+	return strings.Trim(m[1], `"'`), m[3], true
+}
+
+// errorf returns a yamlError positioned at path, or at the closest enclosing
+// path the index knows about.
+func (idx *yamlIndex) errorf(path string, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	for p := path; p != ""; p = parentYAMLPath(p) {
+		if pos, ok := idx.paths[p]; ok {
+			return &yamlError{pos.line, pos.column, msg}
+		}
+	}
+	return &yamlError{1, 1, msg}
+}
+
+// find returns the first position of s in the document.
+func (idx *yamlIndex) find(s string) (yamlPos, bool) {
+	for n, line := range idx.lines {
+		if i := strings.Index(line, s); i >= 0 {
+			return yamlPos{n + 1, i + 1}, true
+		}
+	}
+	return yamlPos{}, false
+}
+
+func parentYAMLPath(p string) string {
+	i := strings.LastIndexAny(p, ".[")
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
+
+var yamlLineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// wrapError converts the "line N: ..." errors produced by the yaml package
+// into yamlErrors, pointing at the first non-blank column of the line.
+func (idx *yamlIndex) wrapError(err error) error {
+	msgs := []string{err.Error()}
+	if te, ok := err.(*yaml.TypeError); ok {
+		msgs = te.Errors
+	}
+
+	errs := make([]string, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = msg
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			err = idx.errorAt(line, m[2])
+			errs[i] = err.Error()
+		}
+	}
+	if len(errs) == 1 {
+		return err
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "\n"))
+}
+
+func (idx *yamlIndex) errorAt(line int, msg string) error {
+	col := 1
+	if line > 0 && line <= len(idx.lines) {
+		l := idx.lines[line-1]
+		col = len(l) - len(strings.TrimLeft(l, " ")) + 1
+	}
+	return &yamlError{line, col, msg}
+}